@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// emailFilter reads the filter parameters shared by the list endpoints
+func emailFilter(r *http.Request) (models.EmailFilter, error) {
+	q := r.URL.Query()
+	filter := models.EmailFilter{
+		Sender: q.Get("sender"),
+		Domain: q.Get("domain"),
+		Label:  q.Get("label"),
+		Query:  q.Get("q"),
+	}
+	trash, err := boolParam(r, "trash")
+	if err != nil {
+		return filter, err
+	}
+	filter.Trash = trash
+	return filter, nil
+}
+
+// listEmails returns one page of emails. The next page is fetched by
+// passing next_cursor back as cursor with the same filter and sort.
+func (s *Server) listEmails(w http.ResponseWriter, r *http.Request) {
+	filter, err := emailFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursor, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = s.cfg.UI.DefaultSort
+	} else if !config.ValidSort(sortBy) {
+		writeError(w, http.StatusBadRequest, "unknown sort order "+sortBy)
+		return
+	}
+
+	emails, next, err := s.db.ListEmails(r.Context(), filter, sortBy, cursor, limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	page := struct {
+		Emails     []emailJSON `json:"emails"`
+		NextCursor string      `json:"next_cursor,omitempty"`
+	}{Emails: make([]emailJSON, 0, len(emails)), NextCursor: encodeCursor(next)}
+	for _, email := range emails {
+		page.Emails = append(page.Emails, toEmailJSON(email, false))
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) getEmail(w http.ResponseWriter, r *http.Request) {
+	email, err := s.db.GetEmail(r.Context(), r.PathValue("id"))
+	if errors.Is(err, models.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "email not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toEmailJSON(email, true))
+}
+
+// htmlBodyPolicy lets an HTML body show inline styles and embedded
+// images but load nothing remote, so opening it reveals nothing to the
+// sender
+const htmlBodyPolicy = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox"
+
+// getEmailHTML serves the HTML body of an email as a page of its own for
+// the reading pane to frame
+func (s *Server) getEmailHTML(w http.ResponseWriter, r *http.Request) {
+	email, err := s.db.GetEmail(r.Context(), r.PathValue("id"))
+	if errors.Is(err, models.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "email not found")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if email.HTMLBody == "" {
+		writeError(w, http.StatusNotFound, "email has no HTML body")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", htmlBodyPolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(email.HTMLBody))
+}
+
+// trashRequest is the body of POST /emails/trash
+type trashRequest struct {
+	IDs []string `json:"ids"`
+	// Gmail queues moving the emails to the Gmail trash as well
+	Gmail bool `json:"gmail"`
+}
+
+type trashResponse struct {
+	Trashed []string `json:"trashed"`
+	Gmail   []string `json:"gmail"`
+}
+
+// trashEmail moves one email to the trash, and queues moving it to the
+// Gmail trash too if the gmail parameter is set
+func (s *Server) trashEmail(w http.ResponseWriter, r *http.Request) {
+	remote, err := boolParam(r, "gmail")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.trash(w, r, []string{r.PathValue("id")}, remote)
+}
+
+func (s *Server) trashEmails(w http.ResponseWriter, r *http.Request) {
+	var req trashRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeError(w, http.StatusBadRequest, "ids must not be empty")
+		return
+	}
+	s.trash(w, r, req.IDs, req.Gmail)
+}
+
+func (s *Server) trash(w http.ResponseWriter, r *http.Request, ids []string, remote bool) {
+	batch, err := handlers.TrashEmails(r.Context(), s.db, ids, remote)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	resp := trashResponse{Trashed: batch.IDs, Gmail: batch.Remote}
+	if resp.Gmail == nil {
+		resp.Gmail = []string{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// purgeEmail permanently deletes an email that is in the trash. Emails
+// not in the trash are left alone.
+func (s *Server) purgeEmail(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.db.PurgeEmails(r.Context(), []string{r.PathValue("id")}); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
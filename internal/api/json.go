@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+const (
+	// defaultPageSize is the page size when the limit parameter is absent
+	defaultPageSize = 50
+	// maxPageSize is the largest page a client may ask for
+	maxPageSize = 500
+	// maxBodySize caps request bodies
+	maxBodySize = 1 << 20
+)
+
+// emailJSON is an email as the API returns it. The body and headers are
+// only filled in when a single email is fetched.
+type emailJSON struct {
+	ID           string     `json:"id"`
+	ThreadID     string     `json:"thread_id"`
+	From         string     `json:"from"`
+	Subject      string     `json:"subject"`
+	Date         string     `json:"date"`
+	ReceivedAt   *time.Time `json:"received_at,omitempty"`
+	Labels       []string   `json:"labels"`
+	SizeEstimate int64      `json:"size_estimate"`
+	Attachments  []string   `json:"attachments"`
+	Body         string     `json:"body,omitempty"`
+	HTMLBody     string     `json:"html_body,omitempty"`
+	Headers      string     `json:"headers,omitempty"`
+}
+
+func toEmailJSON(email models.Email, full bool) emailJSON {
+	e := emailJSON{
+		ID:           email.ID,
+		ThreadID:     email.ThreadID,
+		From:         email.From,
+		Subject:      email.Subject,
+		Date:         email.Date,
+		Labels:       email.Labels,
+		SizeEstimate: email.SizeEstimate,
+		Attachments:  email.Attachments,
+	}
+	if !email.ReceivedAt.IsZero() {
+		receivedAt := email.ReceivedAt.UTC()
+		e.ReceivedAt = &receivedAt
+	}
+	if e.Labels == nil {
+		e.Labels = []string{}
+	}
+	if e.Attachments == nil {
+		e.Attachments = []string{}
+	}
+	if full {
+		e.Body = email.Body
+		e.HTMLBody = email.HTMLBody
+		e.Headers = email.Headers
+	}
+	return e
+}
+
+type senderJSON struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+	Size    int64  `json:"size"`
+}
+
+func toSendersJSON(senders []models.SenderCount) []senderJSON {
+	out := make([]senderJSON, 0, len(senders))
+	for _, s := range senders {
+		out = append(out, senderJSON{Address: s.Address, Count: s.Count, Size: s.Size})
+	}
+	return out
+}
+
+type sizeGroupJSON struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+func toSizeGroupsJSON(groups []models.SizeGroup) []sizeGroupJSON {
+	out := make([]sizeGroupJSON, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, sizeGroupJSON{Name: g.Name, Count: g.Count, Size: g.Size})
+	}
+	return out
+}
+
+// encodeCursor makes an email cursor opaque to clients
+func encodeCursor(cursor *models.EmailCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	b, _ := json.Marshal([2]string{cursor.Key, cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*models.EmailCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var parts [2]string
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &models.EmailCursor{Key: parts[0], ID: parts[1]}, nil
+}
+
+// pageLimit reads the limit parameter, defaulting to defaultPageSize
+func pageLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultPageSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return n, nil
+}
+
+// boolParam reads a true/false query parameter, false if absent
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// readJSON decodes a request body into v, rejecting unknown fields
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeInternalError logs err and reports a generic error to the client,
+// since err may reveal details of the store
+func writeInternalError(w http.ResponseWriter, err error) {
+	logger.Error("API error", "err", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// healthCheckTimeout bounds each health check, so a hung database or
+// token refresh fails the probe instead of stalling it
+const healthCheckTimeout = 5 * time.Second
+
+// ops serves the endpoints monitoring scrapes and probes. They need no
+// token.
+type ops struct {
+	db          store.Store
+	gmailClient *http.Client
+}
+
+// registerOps adds /metrics, /healthz and /readyz to mux
+func registerOps(mux *http.ServeMux, db store.Store, gmailClient *http.Client) {
+	o := &ops{db: db, gmailClient: gmailClient}
+	mux.HandleFunc("GET /metrics", o.metrics)
+	mux.HandleFunc("GET /healthz", o.healthz)
+	mux.HandleFunc("GET /readyz", o.readyz)
+}
+
+// ServeOps serves only /metrics, /healthz and /readyz on addr until ctx is
+// cancelled, for commands without the API such as the daemon
+func ServeOps(ctx context.Context, addr string, db store.Store, gmailClient *http.Client) error {
+	mux := http.NewServeMux()
+	registerOps(mux, db, gmailClient)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	logger.Info("Serving metrics", "url", "http://"+addr+"/metrics")
+	return serveUntilDone(ctx, srv)
+}
+
+// metrics writes every metric in the Prometheus text format, with the
+// table sizes read at scrape time
+func (o *ops) metrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	counts, err := o.db.CountRows(ctx)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteAll(w)
+	if err != nil {
+		logger.Error("Unable to count rows for metrics", "err", err)
+		return
+	}
+	rows := make(map[string]float64, len(counts))
+	for table, n := range counts {
+		rows[table] = float64(n)
+	}
+	metrics.WriteGauge(w, "gmailscraper_table_rows", "Rows in each database table", "table", rows)
+}
+
+// healthz reports whether the process can reach its database
+func (o *ops) healthz(w http.ResponseWriter, r *http.Request) {
+	o.report(w, r, map[string]func(context.Context) error{
+		"database": o.db.Ping,
+	})
+}
+
+// readyz reports whether the process can do its work: the database
+// answers and the Gmail token is usable
+func (o *ops) readyz(w http.ResponseWriter, r *http.Request) {
+	o.report(w, r, map[string]func(context.Context) error{
+		"database": o.db.Ping,
+		"gmail_token": func(context.Context) error {
+			return gmail.CheckToken(o.gmailClient)
+		},
+	})
+}
+
+// report runs the checks and answers 200 if all pass and 503 otherwise,
+// with the outcome of each
+func (o *ops) report(w http.ResponseWriter, r *http.Request, checks map[string]func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+	for name, check := range checks {
+		if err := runCheck(ctx, check); err != nil {
+			results[name] = err.Error()
+			status = http.StatusServiceUnavailable
+		} else {
+			results[name] = "ok"
+		}
+	}
+	writeJSON(w, status, results)
+}
+
+// runCheck gives up on a check that ignores ctx once ctx is done
+func runCheck(ctx context.Context, check func(context.Context) error) error {
+	errc := make(chan error, 1)
+	go func() { errc <- check(ctx) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
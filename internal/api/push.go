@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+	"google.golang.org/api/idtoken"
+)
+
+const (
+	// watchRenewInterval is how often the Gmail watch is renewed. Gmail
+	// recommends daily, and drops a watch after a week.
+	watchRenewInterval = 24 * time.Hour
+	// pushRetryDelay is how long a push sync waits when another sync
+	// holds the lock, since that sync may have started before the change
+	pushRetryDelay = time.Minute
+)
+
+// pushEnvelope is what a Pub/Sub push subscription posts. The data is
+// base64 in the JSON, which encoding/json decodes for a []byte.
+type pushEnvelope struct {
+	Message struct {
+		Data      []byte `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// gmailNotification is the message Gmail publishes when a mailbox changes
+type gmailNotification struct {
+	EmailAddress string `json:"emailAddress"`
+	HistoryID    uint64 `json:"historyId"`
+}
+
+// pushSyncer turns push notifications into incremental syncs.
+// Notifications that arrive while a sync runs are folded into one more
+// sync after it.
+type pushSyncer struct {
+	// account is the mailbox notifications are accepted for
+	account string
+	// latest is the highest history ID notified so far
+	latest atomic.Uint64
+	wake   chan struct{}
+}
+
+func (p *pushSyncer) notify(historyID uint64) {
+	for {
+		cur := p.latest.Load()
+		if historyID <= cur || p.latest.CompareAndSwap(cur, historyID) {
+			break
+		}
+	}
+	p.wakeUp()
+}
+
+func (p *pushSyncer) wakeUp() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// startPush looks up the signed in account, starts the Gmail watch and
+// syncs whenever a notification arrives, until ctx is cancelled
+func (s *Server) startPush(ctx context.Context) error {
+	account, err := gmail.EmailAddress(ctx, s.gmailClient)
+	if err != nil {
+		return fmt.Errorf("failed to look up the Gmail account: %v", err)
+	}
+	s.push.account = account
+
+	go handlers.RunWatchRenewal(ctx, s.gmailClient, s.cfg.Watch.Topic, watchRenewInterval)
+	go s.runPushSyncs(ctx)
+	return nil
+}
+
+func (s *Server) runPushSyncs(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.push.wake:
+		}
+
+		// Pub/Sub redelivers, and one sync covers many notifications
+		synced, err := s.db.GetHistoryID(ctx)
+		if err == nil && synced >= s.push.latest.Load() {
+			continue
+		}
+
+		run, err := handlers.RunSync(ctx, s.gmailClient, s.db, s.cfg.Gmail, models.SyncTriggerPush, s.cfg.Daemon.Rules, nil)
+		switch {
+		case errors.Is(err, handlers.ErrSyncRunning):
+			time.AfterFunc(pushRetryDelay, s.push.wakeUp)
+		case err != nil && ctx.Err() == nil:
+			logger.Error("Push sync failed", "run", run.ID, "err", err)
+		case err == nil:
+			logger.Info("Push sync finished", "run", run.ID, "saved", run.Saved)
+		}
+	}
+}
+
+// receivePush accepts a Gmail notification from a Pub/Sub push
+// subscription. Without skip_verify the request must carry a Google
+// signed token for the configured audience. With it, a notification can
+// be sent by hand:
+//
+//	data=$(printf '{"emailAddress":"me@gmail.com","historyId":1}' | base64)
+//	curl -d '{"message":{"data":"'$data'"}}' http://127.0.0.1:8080/push/gmail
+func (s *Server) receivePush(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.Watch.SkipVerify {
+		if err := s.verifyPushToken(r); err != nil {
+			logger.Warn("Rejected push", "err", err)
+			writeError(w, http.StatusUnauthorized, "missing or invalid push token")
+			return
+		}
+	}
+
+	// Pub/Sub adds fields over time, so unknown ones are allowed
+	var envelope pushEnvelope
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&envelope); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid push envelope: %v", err))
+		return
+	}
+	var n gmailNotification
+	if err := json.Unmarshal(envelope.Message.Data, &n); err != nil || n.EmailAddress == "" || n.HistoryID == 0 {
+		writeError(w, http.StatusBadRequest, "message data must be a Gmail notification with emailAddress and historyId")
+		return
+	}
+
+	// Anything but a 2xx makes Pub/Sub redeliver, so notifications for
+	// another mailbox are acknowledged and dropped
+	if !strings.EqualFold(n.EmailAddress, s.push.account) {
+		logger.Info("Ignoring push for another mailbox", "email", n.EmailAddress)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.push.notify(n.HistoryID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyPushToken checks the OIDC token Pub/Sub sends with each push
+func (s *Server) verifyPushToken(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return errors.New("no bearer token")
+	}
+	payload, err := idtoken.Validate(r.Context(), token, s.cfg.Watch.Audience)
+	if err != nil {
+		return err
+	}
+	if want := s.cfg.Watch.ServiceAccount; want != "" {
+		email, _ := payload.Claims["email"].(string)
+		verified, _ := payload.Claims["email_verified"].(bool)
+		if !verified || email != want {
+			return fmt.Errorf("token is for %q, not %q", email, want)
+		}
+	}
+	return nil
+}
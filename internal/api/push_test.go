@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+)
+
+const pushAccount = "me@gmail.com"
+
+// newPushServer returns a server accepting pushes for pushAccount
+func newPushServer(t *testing.T, watch config.WatchConfig) *Server {
+	t.Helper()
+	watch.Topic = "projects/test/topics/gmail"
+	s := New(context.Background(), nil, nil, &config.Config{Watch: watch})
+	s.push.account = pushAccount
+	return s
+}
+
+// envelope wraps a Gmail notification the way a Pub/Sub push does
+func envelope(t *testing.T, notification string) string {
+	t.Helper()
+	data := base64.StdEncoding.EncodeToString([]byte(notification))
+	body, err := json.Marshal(map[string]any{
+		"message":      map[string]any{"data": data, "messageId": "1"},
+		"subscription": "projects/test/subscriptions/gmail-push",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// unsignedToken builds a JWT with the given header and claims and a
+// signature no key produced
+func unsignedToken(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	part := func(v map[string]any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	return part(header) + "." + part(claims) + "." + base64.RawURLEncoding.EncodeToString([]byte("signature"))
+}
+
+func postPush(s *Server, body, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/push/gmail", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReceivePushSkipVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		// wantLatest is the history ID the syncer should be woken for, 0
+		// for none
+		wantLatest uint64
+	}{
+		{
+			name:       "notification",
+			body:       envelope(t, `{"emailAddress":"me@gmail.com","historyId":4242}`),
+			wantStatus: http.StatusNoContent,
+			wantLatest: 4242,
+		},
+		{
+			name:       "address in another case",
+			body:       envelope(t, `{"emailAddress":"Me@Gmail.com","historyId":7}`),
+			wantStatus: http.StatusNoContent,
+			wantLatest: 7,
+		},
+		{
+			name:       "another mailbox",
+			body:       envelope(t, `{"emailAddress":"someone@gmail.com","historyId":4242}`),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "missing history ID",
+			body:       envelope(t, `{"emailAddress":"me@gmail.com"}`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "data is not a notification",
+			body:       envelope(t, `not json`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed envelope",
+			body:       `{"message":`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPushServer(t, config.WatchConfig{SkipVerify: true})
+			rec := postPush(s, tt.body, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := s.push.latest.Load(); got != tt.wantLatest {
+				t.Errorf("latest history ID = %d, want %d", got, tt.wantLatest)
+			}
+			woken := len(s.push.wake) > 0
+			if woken != (tt.wantLatest != 0) {
+				t.Errorf("syncer woken = %v, want %v", woken, tt.wantLatest != 0)
+			}
+		})
+	}
+}
+
+func TestReceivePushRejectsBadToken(t *testing.T) {
+	const audience = "https://mail.example.com/push/gmail"
+	future := time.Now().Add(time.Hour).Unix()
+	rs256 := map[string]any{"alg": "RS256", "kid": "test", "typ": "JWT"}
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{name: "missing", authorization: ""},
+		{name: "not bearer", authorization: "Basic dXNlcjpwYXNz"},
+		{name: "not a JWT", authorization: "Bearer not-a-token"},
+		{
+			name:          "wrong audience",
+			authorization: "Bearer " + unsignedToken(t, rs256, map[string]any{"aud": "https://elsewhere.example.com", "exp": future}),
+		},
+		{
+			name:          "expired",
+			authorization: "Bearer " + unsignedToken(t, rs256, map[string]any{"aud": audience, "exp": time.Now().Add(-time.Hour).Unix()}),
+		},
+		{
+			name: "unsigned",
+			authorization: "Bearer " + unsignedToken(t, map[string]any{"alg": "none", "typ": "JWT"},
+				map[string]any{"aud": audience, "exp": future}),
+		},
+	}
+	body := envelope(t, `{"emailAddress":"me@gmail.com","historyId":4242}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPushServer(t, config.WatchConfig{Audience: audience})
+			rec := postPush(s, body, tt.authorization)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusUnauthorized, rec.Body)
+			}
+			if got := s.push.latest.Load(); got != 0 {
+				t.Errorf("latest history ID = %d after a rejected push, want 0", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import "net/http"
+
+// defaultStatsLimit is how many senders the stats endpoint ranks when the
+// limit parameter is absent
+const defaultStatsLimit = 10
+
+// listSenders returns one page of senders with their email counts, ordered
+// by address. The next page starts after next_after.
+func (s *Server) listSenders(w http.ResponseWriter, r *http.Request) {
+	filter, err := emailFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	descending, err := boolParam(r, "desc")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	senders, err := s.db.ListSenders(r.Context(), filter, descending, r.URL.Query().Get("after"), limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	page := struct {
+		Senders   []senderJSON `json:"senders"`
+		NextAfter string       `json:"next_after,omitempty"`
+	}{Senders: toSendersJSON(senders)}
+	if len(senders) == limit {
+		page.NextAfter = senders[len(senders)-1].Address
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// stats returns the mailbox totals, the senders with the most and the
+// largest emails, and the size per label and year
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	filter, err := emailFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultStatsLimit
+	if r.URL.Query().Has("limit") {
+		if limit, err = pageLimit(r); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	ctx := r.Context()
+	total, err := s.db.SummarizeEmails(ctx, filter)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	top, err := s.db.TopSenders(ctx, filter, limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	largest, err := s.db.LargestSenders(ctx, filter, limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	labels, err := s.db.SizeByLabel(ctx)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	years, err := s.db.SizeByYear(ctx)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Count          int             `json:"count"`
+		Size           int64           `json:"size"`
+		TopSenders     []senderJSON    `json:"top_senders"`
+		LargestSenders []senderJSON    `json:"largest_senders"`
+		Labels         []sizeGroupJSON `json:"labels"`
+		Years          []sizeGroupJSON `json:"years"`
+	}{
+		Count:          total.Count,
+		Size:           total.Size,
+		TopSenders:     toSendersJSON(top),
+		LargestSenders: toSendersJSON(largest),
+		Labels:         toSizeGroupsJSON(labels),
+		Years:          toSizeGroupsJSON(years),
+	})
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+	"github.com/HoustonMiles/gmailScraper/internal/web"
+)
+
+var logger = logging.For("api")
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+const (
+	// shutdownTimeout is how long in-flight requests get to finish once
+	// the server is stopped
+	shutdownTimeout = 10 * time.Second
+	// readHeaderTimeout guards against clients that never finish their
+	// request headers
+	readHeaderTimeout = 10 * time.Second
+)
+
+// Server is the JSON API over the mail store and the browser UI built on
+// it, started by the serve command
+type Server struct {
+	// ctx bounds work that outlives a request, such as a sync
+	ctx         context.Context
+	db          store.Store
+	gmailClient *http.Client
+	cfg         *config.Config
+	sync        syncRunner
+	push        pushSyncer
+}
+
+// New creates a server. Syncs it starts stop when ctx is cancelled.
+func New(ctx context.Context, db store.Store, gmailClient *http.Client, cfg *config.Config) *Server {
+	return &Server{
+		ctx:         ctx,
+		db:          db,
+		gmailClient: gmailClient,
+		cfg:         cfg,
+		push:        pushSyncer{wake: make(chan struct{}, 1)},
+	}
+}
+
+// Handler returns the API and browser UI routes. Everything under /api/v1/
+// except the OpenAPI spec needs the bearer token or a browser session.
+// With a watch topic configured, Pub/Sub pushes go to /push/gmail.
+// /metrics, /healthz and /readyz are open for monitoring.
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/emails", s.listEmails)
+	api.HandleFunc("GET /api/v1/emails/{id}", s.getEmail)
+	api.HandleFunc("GET /api/v1/emails/{id}/html", s.getEmailHTML)
+	api.HandleFunc("DELETE /api/v1/emails/{id}", s.trashEmail)
+	api.HandleFunc("POST /api/v1/emails/trash", s.trashEmails)
+	api.HandleFunc("DELETE /api/v1/trash/{id}", s.purgeEmail)
+	api.HandleFunc("GET /api/v1/senders", s.listSenders)
+	api.HandleFunc("GET /api/v1/stats", s.stats)
+	api.HandleFunc("GET /api/v1/sync", s.syncStatus)
+	api.HandleFunc("POST /api/v1/sync", s.startSync)
+	api.HandleFunc("GET /api/v1/sync/events", s.syncEvents)
+	api.HandleFunc("GET /api/v1/sync/runs", s.listSyncRuns)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(openAPISpec)
+	})
+	mux.Handle("/api/v1/", requireToken(s.cfg.Server.Token, api))
+
+	// Browser UI
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("POST /login", s.login)
+	mux.HandleFunc("POST /logout", s.logout)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", web.Static))
+
+	registerOps(mux, s.db, s.gmailClient)
+
+	if s.cfg.Watch.Topic != "" {
+		mux.HandleFunc("POST /push/gmail", s.receivePush)
+	}
+	return mux
+}
+
+// ListenAndServe serves the API on the configured address until ctx is
+// cancelled, then waits for in-flight requests to finish. Without a token
+// every request is rejected. With a watch topic it also keeps the Gmail
+// watch alive and syncs on each push.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s.cfg.Watch.Topic != "" {
+		if err := s.startPush(ctx); err != nil {
+			return err
+		}
+	}
+
+	srv := &http.Server{
+		Addr:              s.cfg.Server.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	logger.Info("Serving web UI and API", "url", "http://"+s.cfg.Server.Addr+"/")
+	return serveUntilDone(ctx, srv)
+}
+
+// serveUntilDone runs srv until ctx is cancelled and then waits for
+// in-flight requests to finish
+func serveUntilDone(ctx context.Context, srv *http.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve on %s: %v", srv.Addr, err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop server on %s: %v", srv.Addr, err)
+	}
+	return nil
+}
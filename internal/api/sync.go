@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// syncStatusJSON is the state of the last sync started through the API
+type syncStatusJSON struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Total      int64      `json:"total"`
+	Listed     int64      `json:"listed"`
+	Fetched    int64      `json:"fetched"`
+	Saved      int64      `json:"saved"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// syncRunJSON is one entry of the sync history
+type syncRunJSON struct {
+	ID         int64      `json:"id"`
+	Trigger    string     `json:"trigger"`
+	Mode       string     `json:"mode,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Fetched    int64      `json:"fetched"`
+	Saved      int64      `json:"saved"`
+	Deleted    int64      `json:"deleted"`
+	Ruled      int64      `json:"ruled"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func newSyncRunJSON(run models.SyncRun) syncRunJSON {
+	out := syncRunJSON{
+		ID:        run.ID,
+		Trigger:   run.Trigger,
+		Mode:      run.Mode,
+		StartedAt: run.StartedAt.UTC(),
+		Fetched:   run.Fetched,
+		Saved:     run.Saved,
+		Deleted:   run.Deleted,
+		Ruled:     run.Ruled,
+		Error:     run.Error,
+	}
+	if !run.FinishedAt.IsZero() {
+		finished := run.FinishedAt.UTC()
+		out.FinishedAt = &finished
+	}
+	return out
+}
+
+// syncEventInterval is the least time between two progress events sent
+// to one client
+const syncEventInterval = 250 * time.Millisecond
+
+// syncRunner runs at most one sync at a time and remembers how the last
+// one went
+type syncRunner struct {
+	mu     sync.Mutex
+	status syncStatusJSON
+	// changed is closed and replaced whenever status changes
+	changed chan struct{}
+}
+
+// start begins a sync in the background and reports false if one is
+// already running
+func (r *syncRunner) start(ctx context.Context, run func(ctx context.Context, onProgress func(handlers.SyncProgress)) error) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Running {
+		return false
+	}
+	now := time.Now().UTC()
+	r.status = syncStatusJSON{Running: true, StartedAt: &now}
+	r.notify()
+
+	go func() {
+		err := run(ctx, r.progress)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		finished := time.Now().UTC()
+		r.status.Running = false
+		r.status.FinishedAt = &finished
+		if err != nil {
+			r.status.Error = err.Error()
+		}
+		r.notify()
+	}()
+	return true
+}
+
+func (r *syncRunner) progress(p handlers.SyncProgress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Total = p.Total
+	r.status.Listed = p.Listed
+	r.status.Fetched = p.Fetched
+	r.status.Saved = p.Saved
+	r.notify()
+}
+
+// notify wakes everyone watching. The caller holds r.mu.
+func (r *syncRunner) notify() {
+	if r.changed != nil {
+		close(r.changed)
+	}
+	r.changed = make(chan struct{})
+}
+
+func (r *syncRunner) current() syncStatusJSON {
+	status, _ := r.watch()
+	return status
+}
+
+// watch returns the status and a channel closed on its next change
+func (r *syncRunner) watch() (syncStatusJSON, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.changed == nil {
+		r.changed = make(chan struct{})
+	}
+	return r.status, r.changed
+}
+
+func (s *Server) syncStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.sync.current())
+}
+
+// startSync starts a sync from Gmail: incremental when possible and full
+// otherwise
+func (s *Server) startSync(w http.ResponseWriter, r *http.Request) {
+	// The sync outlives the request, so it runs under the server's context
+	started := s.sync.start(s.ctx, func(ctx context.Context, onProgress func(handlers.SyncProgress)) error {
+		_, err := handlers.RunSync(ctx, s.gmailClient, s.db, s.cfg.Gmail, models.SyncTriggerAPI, nil, onProgress)
+		return err
+	})
+	if !started {
+		writeError(w, http.StatusConflict, "a sync is already running")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.sync.current())
+}
+
+// listSyncRuns returns the most recent syncs from every source, newest
+// first
+func (s *Server) listSyncRuns(w http.ResponseWriter, r *http.Request) {
+	limit, err := pageLimit(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	runs, err := s.db.ListSyncRuns(r.Context(), limit)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	out := make([]syncRunJSON, len(runs))
+	for i, run := range runs {
+		out[i] = newSyncRunJSON(run)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"runs": out})
+}
+
+// syncEvents streams the sync status as server-sent events: the current
+// status at once, then every change until the sync finishes or the client
+// goes away
+func (s *Server) syncEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		status, changed := s.sync.watch()
+		data, err := json.Marshal(status)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+		if !status.Running {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		// Progress changes with every message, so batch them up
+		select {
+		case <-time.After(syncEventInterval):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
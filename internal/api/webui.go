@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/HoustonMiles/gmailScraper/internal/web"
+)
+
+// index serves the browser UI, or the login form without a session
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if authorized(r, s.cfg.Server.Token) {
+		err = web.RenderIndex(w, web.IndexPage{DefaultSort: s.cfg.UI.DefaultSort})
+	} else {
+		err = web.RenderLogin(w, http.StatusOK, web.LoginPage{})
+	}
+	if err != nil {
+		logger.Error("Unable to render page", "err", err)
+	}
+}
+
+// login starts a browser session if the submitted token is right
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	token := r.PostFormValue("token")
+	if s.cfg.Server.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Server.Token)) != 1 {
+		if err := web.RenderLogin(w, http.StatusUnauthorized, web.LoginPage{Error: "Invalid token"}); err != nil {
+			logger.Error("Unable to render page", "err", err)
+		}
+		return
+	}
+	setSession(w, r, token)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	setSession(w, r, "")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv blanks the variables the tests set, which Load treats as unset
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"GMAILSCRAPER_CONFIG", "GMAILSCRAPER_PROFILE", "DATABASE_URL",
+		"GMAILSCRAPER_PAGE_SIZE", "GMAILSCRAPER_DEFAULT_SORT", "GMAILSCRAPER_TRASH_RETENTION_DAYS",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to a temporary directory under the name file,
+		// and passed with -config unless it is empty
+		file, content string
+		args          []string
+		env           map[string]string
+		// check inspects the result, or wantErr is part of the error
+		check   func(t *testing.T, cfg *Config, dir string)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Gmail.PageSize != MaxPageSize || cfg.Trash.RetentionDays != 30 || cfg.UI.OneClickUnsubscribe {
+					t.Errorf("got %+v, want the defaults", cfg)
+				}
+			},
+		},
+		{
+			name: "TOML base settings",
+			file: "config.toml",
+			content: `
+[gmail]
+page_size = 250
+credentials_file = "secrets/credentials.json"
+
+[ui]
+default_sort = "sender_asc"
+one_click_unsubscribe = true
+
+[trash]
+retention_days = 0
+`,
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Gmail.PageSize != 250 {
+					t.Errorf("page size = %d, want 250", cfg.Gmail.PageSize)
+				}
+				if want := filepath.Join(dir, "secrets", "credentials.json"); cfg.Gmail.CredentialsFile != want {
+					t.Errorf("credentials file = %q, want %q relative to the config", cfg.Gmail.CredentialsFile, want)
+				}
+				if cfg.UI.DefaultSort != SortSenderAsc || !cfg.UI.OneClickUnsubscribe {
+					t.Errorf("ui = %+v, want sender_asc with one-click unsubscribe", cfg.UI)
+				}
+				if cfg.Trash.RetentionDays != 0 {
+					t.Errorf("retention = %d, want an explicit 0", cfg.Trash.RetentionDays)
+				}
+			},
+		},
+		{
+			name: "YAML base settings",
+			file: "config.yaml",
+			content: `
+gmail:
+  page_size: 250
+ui:
+  default_sort: sender_desc
+`,
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Gmail.PageSize != 250 || cfg.UI.DefaultSort != SortSenderDesc {
+					t.Errorf("got page size %d and sort %q, want 250 and sender_desc", cfg.Gmail.PageSize, cfg.UI.DefaultSort)
+				}
+			},
+		},
+		{
+			name:    "empty YAML",
+			file:    "config.yml",
+			content: "",
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Gmail.PageSize != MaxPageSize {
+					t.Errorf("page size = %d, want the default", cfg.Gmail.PageSize)
+				}
+			},
+		},
+		{
+			name: "profile named in the file",
+			file: "config.toml",
+			content: `
+profile = "work"
+
+[gmail]
+page_size = 100
+
+[profiles.work.gmail]
+page_size = 200
+
+[profiles.home.gmail]
+page_size = 300
+`,
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Profile != "work" || cfg.Gmail.PageSize != 200 {
+					t.Errorf("got profile %q with page size %d, want work with 200", cfg.Profile, cfg.Gmail.PageSize)
+				}
+			},
+		},
+		{
+			name: "profile flag over the file",
+			file: "config.yaml",
+			content: `
+profile: work
+profiles:
+  work:
+    gmail:
+      page_size: 200
+  home:
+    gmail:
+      page_size: 300
+`,
+			args: []string{"-profile", "home"},
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Profile != "home" || cfg.Gmail.PageSize != 300 {
+					t.Errorf("got profile %q with page size %d, want home with 300", cfg.Profile, cfg.Gmail.PageSize)
+				}
+			},
+		},
+		{
+			name:    "unknown profile",
+			file:    "config.toml",
+			content: "[profiles.work.gmail]\npage_size = 200\n",
+			env:     map[string]string{"GMAILSCRAPER_PROFILE": "play"},
+			wantErr: `profile "play" is not defined`,
+		},
+		{
+			name: "environment over the file",
+			file: "config.toml",
+			content: `
+[database]
+url = "postgres://file/db"
+
+[gmail]
+page_size = 100
+`,
+			env: map[string]string{"DATABASE_URL": "postgres://env/db", "GMAILSCRAPER_PAGE_SIZE": "42"},
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Database.URL != "postgres://env/db" || cfg.Gmail.PageSize != 42 {
+					t.Errorf("got %q and page size %d, want the environment's", cfg.Database.URL, cfg.Gmail.PageSize)
+				}
+			},
+		},
+		{
+			name: "flags over the environment",
+			env:  map[string]string{"DATABASE_URL": "postgres://env/db"},
+			args: []string{"-database-url", "postgres://flag/db", "serve"},
+			check: func(t *testing.T, cfg *Config, dir string) {
+				if cfg.Database.URL != "postgres://flag/db" {
+					t.Errorf("database URL = %q, want the flag's", cfg.Database.URL)
+				}
+				if len(cfg.Args) != 1 || cfg.Args[0] != "serve" {
+					t.Errorf("args = %q, want [serve]", cfg.Args)
+				}
+			},
+		},
+		{
+			name:    "invalid number in the environment",
+			env:     map[string]string{"GMAILSCRAPER_PAGE_SIZE": "many"},
+			wantErr: "GMAILSCRAPER_PAGE_SIZE must be a number",
+		},
+		{
+			name:    "unknown TOML section",
+			file:    "config.toml",
+			content: "[gmial]\npage_size = 100\n",
+			wantErr: "unknown keys",
+		},
+		{
+			name:    "unknown TOML key in a known section",
+			file:    "config.toml",
+			content: "[gmail]\npage_sise = 100\n",
+			wantErr: "gmail.page_sise",
+		},
+		{
+			name:    "unknown TOML key in a profile",
+			file:    "config.toml",
+			content: "[profiles.work.gmail]\ntoken_fle = \"t.json\"\n",
+			wantErr: "profiles.work.gmail.token_fle",
+		},
+		{
+			name:    "unknown YAML key",
+			file:    "config.yaml",
+			content: "gmail:\n  page_sise: 100\n",
+			wantErr: "page_sise",
+		},
+		{
+			name:    "unknown YAML key in a profile",
+			file:    "config.yaml",
+			content: "profiles:\n  work:\n    uii:\n      default_sort: sender_asc\n",
+			wantErr: "uii",
+		},
+		{
+			name:    "unsupported file type",
+			file:    "config.json",
+			content: "{}",
+			wantErr: "unsupported config file type",
+		},
+		{
+			name:    "invalid setting",
+			file:    "config.toml",
+			content: "[ui]\ndefault_sort = \"random\"\n",
+			wantErr: "random",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			dir := t.TempDir()
+			// Keep the search for a config file out of the user's home
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			t.Setenv("HOME", dir)
+
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(dir, tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"-config", path}, args...)
+			}
+
+			cfg, err := Load(args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load(): %v", err)
+			}
+			tt.check(t, cfg, dir)
+		})
+	}
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	clearEnv(t)
+	if _, err := Load([]string{"-config", filepath.Join("..", "..", "config.example.toml")}); err != nil {
+		t.Fatalf("config.example.toml does not load: %v", err)
+	}
+}
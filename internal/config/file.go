@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors the on-disk layout. Top level sections are the base
+// settings and each entry under profiles overrides them when selected:
+//
+//	profile = "work"
+//
+//	[database]
+//	url = "postgres://localhost/gmailscraper"
+//
+//	[profiles.work.gmail]
+//	token_file = "work-token.json"
+type fileConfig struct {
+	Profile  string                 `toml:"profile" yaml:"profile"`
+	Profiles map[string]fileSection `toml:"profiles" yaml:"profiles"`
+	base     fileSection
+}
+
+// yamlFile is fileConfig with the base section inlined
+type yamlFile struct {
+	Profile  string                 `yaml:"profile"`
+	Profiles map[string]fileSection `yaml:"profiles"`
+	Base     fileSection            `yaml:",inline"`
+}
+
+type fileSection struct {
+	Database DatabaseConfig `toml:"database" yaml:"database"`
+	Gmail    GmailConfig    `toml:"gmail" yaml:"gmail"`
+	UI       UIConfig       `toml:"ui" yaml:"ui"`
+	Trash    trashSection   `toml:"trash" yaml:"trash"`
+	Cleanup  CleanupConfig  `toml:"cleanup" yaml:"cleanup"`
+	Server   ServerConfig   `toml:"server" yaml:"server"`
+	Daemon   DaemonConfig   `toml:"daemon" yaml:"daemon"`
+	Watch    WatchConfig    `toml:"watch" yaml:"watch"`
+	Log      logSection     `toml:"log" yaml:"log"`
+	Trace    traceSection   `toml:"trace" yaml:"trace"`
+}
+
+// trashSection can set retention_days to 0, so unset is told apart by nil
+type trashSection struct {
+	RetentionDays *int `toml:"retention_days" yaml:"retention_days"`
+}
+
+// logSection can set file to "" to turn the log file off, so unset is
+// told apart by nil
+type logSection struct {
+	Level      string  `toml:"level" yaml:"level"`
+	Format     string  `toml:"format" yaml:"format"`
+	File       *string `toml:"file" yaml:"file"`
+	MaxSizeMB  int     `toml:"max_size_mb" yaml:"max_size_mb"`
+	MaxBackups *int    `toml:"max_backups" yaml:"max_backups"`
+}
+
+// traceSection can set sample_ratio to 0 to record nothing, so unset is
+// told apart by nil
+type traceSection struct {
+	Exporter    string   `toml:"exporter" yaml:"exporter"`
+	Endpoint    string   `toml:"endpoint" yaml:"endpoint"`
+	File        string   `toml:"file" yaml:"file"`
+	SampleRatio *float64 `toml:"sample_ratio" yaml:"sample_ratio"`
+}
+
+func readFile(path string) (*fileConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: unable to read %s: %w", path, err)
+	}
+
+	// The base section shares the document with profile and profiles, so
+	// decode it separately rather than embedding it
+	var f fileConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		md, err := toml.Decode(string(b), &f)
+		if err != nil {
+			return nil, fmt.Errorf("config: unable to parse %s: %v", path, err)
+		}
+		baseMD, err := toml.Decode(string(b), &f.base)
+		if err != nil {
+			return nil, fmt.Errorf("config: unable to parse %s: %v", path, err)
+		}
+		if undecoded := unknownKeys(md.Undecoded(), baseMD.Undecoded()); len(undecoded) > 0 {
+			return nil, fmt.Errorf("config: unknown keys in %s: %s", path, strings.Join(undecoded, ", "))
+		}
+	case ".yaml", ".yml":
+		// YAML can inline the base section, so a single strict decode
+		// rejects unknown keys
+		var doc yamlFile
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config: unable to parse %s: %v", path, err)
+		}
+		f = fileConfig{Profile: doc.Profile, Profiles: doc.Profiles, base: doc.Base}
+	default:
+		return nil, fmt.Errorf("config: unsupported config file type %q (want .toml, .yaml or .yml)", ext)
+	}
+
+	return &f, nil
+}
+
+// unknownKeys returns the keys left undecoded by both passes: the first
+// decodes profile and profiles, the second the base section
+func unknownKeys(first, second []toml.Key) []string {
+	inSecond := make(map[string]bool, len(second))
+	for _, key := range second {
+		inSecond[key.String()] = true
+	}
+	var unknown []string
+	for _, key := range first {
+		if inSecond[key.String()] {
+			unknown = append(unknown, key.String())
+		}
+	}
+	return unknown
+}
+
+func (f *fileConfig) profileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	return names
+}
+
+// applyTo copies every field that is set in the section onto cfg.
+// Relative file paths are resolved against dir, the config file's directory.
+func (s fileSection) applyTo(cfg *Config, dir string) {
+	if s.Database.URL != "" {
+		cfg.Database.URL = s.Database.URL
+	}
+	if s.Gmail.CredentialsFile != "" {
+		cfg.Gmail.CredentialsFile = resolvePath(dir, s.Gmail.CredentialsFile)
+	}
+	if s.Gmail.TokenFile != "" {
+		cfg.Gmail.TokenFile = resolvePath(dir, s.Gmail.TokenFile)
+	}
+	if s.Gmail.PageSize != 0 {
+		cfg.Gmail.PageSize = s.Gmail.PageSize
+	}
+	if s.UI.DefaultSort != "" {
+		cfg.UI.DefaultSort = s.UI.DefaultSort
+	}
+	for action, key := range s.UI.Shortcuts {
+		if cfg.UI.Shortcuts == nil {
+			cfg.UI.Shortcuts = make(map[string]string)
+		}
+		cfg.UI.Shortcuts[action] = key
+	}
+	if len(s.UI.SavedSearches) > 0 {
+		cfg.UI.SavedSearches = s.UI.SavedSearches
+	}
+	if s.UI.OneClickUnsubscribe {
+		cfg.UI.OneClickUnsubscribe = true
+	}
+	if s.Trash.RetentionDays != nil {
+		cfg.Trash.RetentionDays = *s.Trash.RetentionDays
+	}
+	if s.Cleanup.OlderThanMonths != 0 {
+		cfg.Cleanup.OlderThanMonths = s.Cleanup.OlderThanMonths
+	}
+	if s.Cleanup.LargeMessageMB != 0 {
+		cfg.Cleanup.LargeMessageMB = s.Cleanup.LargeMessageMB
+	}
+	if s.Server.Addr != "" {
+		cfg.Server.Addr = s.Server.Addr
+	}
+	if s.Server.Token != "" {
+		cfg.Server.Token = s.Server.Token
+	}
+	if s.Daemon.Schedule != "" {
+		cfg.Daemon.Schedule = s.Daemon.Schedule
+	}
+	if len(s.Daemon.Rules) > 0 {
+		cfg.Daemon.Rules = s.Daemon.Rules
+	}
+	if s.Daemon.MetricsAddr != "" {
+		cfg.Daemon.MetricsAddr = s.Daemon.MetricsAddr
+	}
+	if s.Log.Level != "" {
+		cfg.Log.Level = s.Log.Level
+	}
+	if s.Log.Format != "" {
+		cfg.Log.Format = s.Log.Format
+	}
+	if s.Log.File != nil {
+		cfg.Log.File = ""
+		if *s.Log.File != "" {
+			cfg.Log.File = resolvePath(dir, *s.Log.File)
+		}
+	}
+	if s.Log.MaxSizeMB != 0 {
+		cfg.Log.MaxSizeMB = s.Log.MaxSizeMB
+	}
+	if s.Log.MaxBackups != nil {
+		cfg.Log.MaxBackups = *s.Log.MaxBackups
+	}
+	if s.Trace.Exporter != "" {
+		cfg.Trace.Exporter = s.Trace.Exporter
+	}
+	if s.Trace.Endpoint != "" {
+		cfg.Trace.Endpoint = s.Trace.Endpoint
+	}
+	if s.Trace.File != "" {
+		cfg.Trace.File = resolvePath(dir, s.Trace.File)
+	}
+	if s.Trace.SampleRatio != nil {
+		cfg.Trace.SampleRatio = *s.Trace.SampleRatio
+	}
+	if s.Watch.Topic != "" {
+		cfg.Watch.Topic = s.Watch.Topic
+	}
+	if s.Watch.Audience != "" {
+		cfg.Watch.Audience = s.Watch.Audience
+	}
+	if s.Watch.ServiceAccount != "" {
+		cfg.Watch.ServiceAccount = s.Watch.ServiceAccount
+	}
+	if s.Watch.SkipVerify {
+		cfg.Watch.SkipVerify = true
+	}
+}
+
+func resolvePath(dir, path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,58 @@
+package config
+
+import "flag"
+
+// flagValues holds command line overrides. Empty or zero values mean the
+// flag was not given.
+type flagValues struct {
+	configPath      string
+	profile         string
+	databaseURL     string
+	credentialsFile string
+	tokenFile       string
+	pageSize        int64
+	defaultSort     string
+	addr            string
+	logLevel        string
+	traceExporter   string
+}
+
+func (f *flagValues) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.configPath, "config", "", "path to a TOML or YAML config file")
+	fs.StringVar(&f.profile, "profile", "", "named profile from the config file")
+	fs.StringVar(&f.databaseURL, "database-url", "", "database connection URL")
+	fs.StringVar(&f.credentialsFile, "credentials", "", "path to the OAuth client credentials.json")
+	fs.StringVar(&f.tokenFile, "token", "", "path to the cached OAuth token")
+	fs.Int64Var(&f.pageSize, "page-size", 0, "messages requested per Gmail list call (1-500)")
+	fs.StringVar(&f.defaultSort, "sort", "", "initial sort order")
+	fs.StringVar(&f.addr, "addr", "", "host:port the serve command listens on")
+	fs.StringVar(&f.logLevel, "log-level", "", "least severe level logged: debug, info, warn or error")
+	fs.StringVar(&f.traceExporter, "trace", "", "where traces go: none, otlp or file")
+}
+
+func (f *flagValues) applyTo(cfg *Config) {
+	if f.databaseURL != "" {
+		cfg.Database.URL = f.databaseURL
+	}
+	if f.credentialsFile != "" {
+		cfg.Gmail.CredentialsFile = f.credentialsFile
+	}
+	if f.tokenFile != "" {
+		cfg.Gmail.TokenFile = f.tokenFile
+	}
+	if f.pageSize != 0 {
+		cfg.Gmail.PageSize = f.pageSize
+	}
+	if f.defaultSort != "" {
+		cfg.UI.DefaultSort = f.defaultSort
+	}
+	if f.addr != "" {
+		cfg.Server.Addr = f.addr
+	}
+	if f.logLevel != "" {
+		cfg.Log.Level = f.logLevel
+	}
+	if f.traceExporter != "" {
+		cfg.Trace.Exporter = f.traceExporter
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Actions that can be bound to a key
+const (
+	ActionNext     = "next"
+	ActionPrevious = "previous"
+	ActionSelect   = "select"
+	ActionDelete   = "delete"
+	ActionArchive  = "archive"
+	ActionRead     = "read"
+	ActionUnread   = "unread"
+	ActionStar     = "star"
+	ActionLabel    = "label"
+	ActionSearch   = "search"
+	ActionRefresh  = "refresh"
+	ActionSync     = "sync"
+	ActionPalette  = "palette"
+)
+
+// ShortcutActions lists every action that can be bound
+var ShortcutActions = []string{
+	ActionNext, ActionPrevious, ActionSelect, ActionDelete, ActionArchive,
+	ActionRead, ActionUnread, ActionStar, ActionLabel, ActionSearch,
+	ActionRefresh, ActionSync, ActionPalette,
+}
+
+// DefaultShortcuts returns the Gmail-like default key bindings
+func DefaultShortcuts() map[string]string {
+	return map[string]string{
+		ActionNext:     "j",
+		ActionPrevious: "k",
+		ActionSelect:   "x",
+		ActionDelete:   "#",
+		ActionArchive:  "e",
+		ActionRead:     "I",
+		ActionUnread:   "U",
+		ActionStar:     "*",
+		ActionLabel:    "l",
+		ActionSearch:   "/",
+		ActionRefresh:  "r",
+		ActionSync:     "s",
+		ActionPalette:  "ctrl+k",
+	}
+}
+
+// Shortcut is a parsed key binding
+type Shortcut struct {
+	Ctrl, Alt, Shift, Super bool
+	// Key is a single character, typed as is, or a key name such as
+	// "Delete" or "F5"
+	Key string
+}
+
+// Typed reports whether the shortcut is a plain character, matched on the
+// text typed rather than the key pressed, so "#" works on any layout
+func (s Shortcut) Typed() bool {
+	return !s.Ctrl && !s.Alt && !s.Shift && !s.Super && utf8.RuneCountInString(s.Key) == 1
+}
+
+// ParseShortcut parses a key binding such as "j", "#", "ctrl+k" or
+// "shift+Delete". Modifiers are case insensitive.
+func ParseShortcut(spec string) (Shortcut, error) {
+	var s Shortcut
+	mods, key := "", spec
+	// The key itself may be "+", as in "ctrl++"
+	if len(spec) > 1 {
+		if i := strings.LastIndex(spec[:len(spec)-1], "+"); i >= 0 {
+			mods, key = spec[:i], spec[i+1:]
+		}
+	}
+
+	if mods != "" {
+		for _, mod := range strings.Split(mods, "+") {
+			switch strings.ToLower(strings.TrimSpace(mod)) {
+			case "ctrl", "control":
+				s.Ctrl = true
+			case "alt":
+				s.Alt = true
+			case "shift":
+				s.Shift = true
+			case "super", "cmd":
+				s.Super = true
+			default:
+				return Shortcut{}, fmt.Errorf("unknown modifier %q in %q", mod, spec)
+			}
+		}
+	}
+
+	s.Key = strings.TrimSpace(key)
+	if s.Key == "" || (s.Key != "+" && strings.HasSuffix(s.Key, "+")) {
+		return Shortcut{}, fmt.Errorf("no key in %q", spec)
+	}
+	return s, nil
+}
+
+// String returns the shortcut in canonical form, e.g. "ctrl+shift+K"
+func (s Shortcut) String() string {
+	var parts []string
+	for _, mod := range []struct {
+		set  bool
+		name string
+	}{{s.Ctrl, "ctrl"}, {s.Alt, "alt"}, {s.Shift, "shift"}, {s.Super, "super"}} {
+		if mod.set {
+			parts = append(parts, mod.name)
+		}
+	}
+	key := s.Key
+	if key != "" && !s.Typed() {
+		key = strings.ToUpper(key[:1]) + key[1:]
+	}
+	return strings.Join(append(parts, key), "+")
+}
+
+// validateShortcuts checks that every bound action exists, every key
+// parses and no key is bound twice
+func validateShortcuts(shortcuts map[string]string) []error {
+	actions := make([]string, 0, len(shortcuts))
+	for action := range shortcuts {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	var errs []error
+	boundTo := make(map[string]string)
+	for _, action := range actions {
+		spec := shortcuts[action]
+		if !validAction(action) {
+			errs = append(errs, fmt.Errorf("ui.shortcuts has unknown action %q (want one of %s)",
+				action, strings.Join(ShortcutActions, ", ")))
+			continue
+		}
+		if spec == "" {
+			continue
+		}
+		shortcut, err := ParseShortcut(spec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ui.shortcuts.%s: %v", action, err))
+			continue
+		}
+		key := shortcut.String()
+		if other, ok := boundTo[key]; ok {
+			errs = append(errs, fmt.Errorf("ui.shortcuts: %q is bound to both %s and %s", spec, other, action))
+			continue
+		}
+		boundTo[key] = action
+	}
+	return errs
+}
+
+func validAction(action string) bool {
+	for _, a := range ShortcutActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+// Package daemon keeps the store in sync with Gmail without the UI: it
+// syncs on the configured schedule and applies the sync rules to new mail.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/api"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/schedule"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+var logger = logging.For("daemon")
+
+const (
+	// trashPurgeInterval is how often expired trash is purged
+	trashPurgeInterval = time.Hour
+	// outboxInterval is how often the outbox is checked for actions due
+	// to be sent
+	outboxInterval = time.Minute
+)
+
+// Run syncs once at start and then on the schedule until ctx is cancelled,
+// serving metrics and health checks if configured. A sync in progress when
+// ctx is cancelled stops after saving what it fetched, and is recorded as
+// failed.
+func Run(ctx context.Context, db store.Store, gmailClient *http.Client, cfg *config.Config) error {
+	sched, err := schedule.Parse(cfg.Daemon.Schedule)
+	if err != nil {
+		return err
+	}
+
+	go handlers.RunTrashRetention(ctx, db, cfg.Trash.RetentionDays, trashPurgeInterval)
+	go handlers.RunOutbox(ctx, gmailClient, db, outboxInterval)
+	if cfg.Daemon.MetricsAddr != "" {
+		go func() {
+			if err := api.ServeOps(ctx, cfg.Daemon.MetricsAddr, db, gmailClient); err != nil {
+				logger.Error("Metrics server stopped", "err", err)
+			}
+		}()
+	}
+
+	logger.Info("Daemon started", "schedule", cfg.Daemon.Schedule, "rules", len(cfg.Daemon.Rules))
+	for {
+		syncOnce(ctx, db, gmailClient, cfg)
+
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			return errors.New("schedule never runs again")
+		}
+		logger.Info("Next sync scheduled", "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("Daemon stopped")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func syncOnce(ctx context.Context, db store.Store, gmailClient *http.Client, cfg *config.Config) {
+	run, err := handlers.RunSync(ctx, gmailClient, db, cfg.Gmail, models.SyncTriggerSchedule, cfg.Daemon.Rules, nil)
+	switch {
+	case errors.Is(err, handlers.ErrSyncRunning):
+		logger.Warn("Skipping scheduled sync", "err", err)
+	case err != nil && ctx.Err() != nil:
+		logger.Info("Sync cancelled", "run", run.ID, "saved", run.Saved)
+	case err != nil:
+		logger.Error("Sync failed", "run", run.ID, "err", err)
+	}
+}
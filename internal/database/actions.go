@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QueueAction adds a pending action to the outbox, due now, and sets its
+// ID and status
+func QueueAction(ctx context.Context, pool *pgxpool.Pool, action *models.PendingAction) error {
+	query := `
+	INSERT INTO pending_actions (kind, email_ids, add_labels, remove_labels, url, status, created_at, next_attempt_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	RETURNING id
+	`
+	action.Status = models.ActionPending
+	err := pool.QueryRow(ctx, query, action.Kind, strings.Join(action.EmailIDs, "\n"),
+		strings.Join(action.AddLabels, "\n"), strings.Join(action.RemoveLabels, "\n"), action.URL,
+		action.Status, unixMilli(action.CreatedAt)).Scan(&action.ID)
+	if err != nil {
+		return fmt.Errorf("error queueing action: %v", err)
+	}
+	return nil
+}
+
+// ListActions returns the actions in status, or all of them if status is
+// empty, oldest first
+func ListActions(ctx context.Context, pool *pgxpool.Pool, status string) ([]models.PendingAction, error) {
+	query := `
+	SELECT id, kind, email_ids, add_labels, remove_labels, url, status,
+		created_at, next_attempt_at, sent_at, attempts, last_error
+	FROM pending_actions
+	WHERE $1 = '' OR status = $1
+	ORDER BY id
+	`
+
+	rows, err := pool.Query(ctx, query, status)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending actions: %v", err)
+	}
+	defer rows.Close()
+
+	var actions []models.PendingAction
+	for rows.Next() {
+		var action models.PendingAction
+		var ids, add, remove string
+		var createdAt, nextAttemptAt, sentAt int64
+		err := rows.Scan(&action.ID, &action.Kind, &ids, &add, &remove, &action.URL, &action.Status,
+			&createdAt, &nextAttemptAt, &sentAt, &action.Attempts, &action.LastError)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning pending action: %v", err)
+		}
+		action.EmailIDs = splitLines(ids)
+		action.AddLabels = splitLines(add)
+		action.RemoveLabels = splitLines(remove)
+		action.CreatedAt = fromUnixMilli(createdAt)
+		action.NextAttemptAt = fromUnixMilli(nextAttemptAt)
+		action.SentAt = fromUnixMilli(sentAt)
+		actions = append(actions, action)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading pending actions: %v", err)
+	}
+
+	return actions, nil
+}
+
+// ClaimAction leases a pending action to the caller until ttl from now,
+// so only one process sends it. It reports false if another holds it.
+func ClaimAction(ctx context.Context, pool *pgxpool.Pool, id int64, ttl time.Duration) (bool, error) {
+	query := `
+	UPDATE pending_actions SET locked_until = $2
+	WHERE id = $1 AND status = $3 AND locked_until < $4
+	`
+	now := time.Now()
+	tag, err := pool.Exec(ctx, query, id, now.Add(ttl).UnixMilli(), models.ActionPending, now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error claiming action: %v", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RecordActionFailure counts a failed attempt to send an action and
+// releases it to be retried at retryAt
+func RecordActionFailure(ctx context.Context, pool *pgxpool.Pool, id int64, reason string, retryAt time.Time) error {
+	query := `
+	UPDATE pending_actions
+	SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3, locked_until = 0
+	WHERE id = $1
+	`
+	_, err := pool.Exec(ctx, query, id, reason, unixMilli(retryAt))
+	if err != nil {
+		return fmt.Errorf("error recording failed action: %v", err)
+	}
+	return nil
+}
+
+// MarkActionSent records that Gmail accepted an action at sentAt
+func MarkActionSent(ctx context.Context, pool *pgxpool.Pool, id int64, sentAt time.Time) error {
+	query := `
+	UPDATE pending_actions SET status = $2, sent_at = $3, last_error = '', locked_until = 0
+	WHERE id = $1
+	`
+	_, err := pool.Exec(ctx, query, id, models.ActionSent, unixMilli(sentAt))
+	if err != nil {
+		return fmt.Errorf("error marking action sent: %v", err)
+	}
+	return nil
+}
+
+// SetActionStatus moves an action to status with reason as its last
+// error, and releases it
+func SetActionStatus(ctx context.Context, pool *pgxpool.Pool, id int64, status, reason string) error {
+	query := `
+	UPDATE pending_actions SET status = $2, last_error = $3, locked_until = 0
+	WHERE id = $1
+	`
+	_, err := pool.Exec(ctx, query, id, status, reason)
+	if err != nil {
+		return fmt.Errorf("error updating action: %v", err)
+	}
+	return nil
+}
+
+// RetryAction makes a failed or conflicting action pending again, due now
+func RetryAction(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+	query := `
+	UPDATE pending_actions
+	SET status = $2, attempts = 0, last_error = '', next_attempt_at = $3, sent_at = 0, locked_until = 0
+	WHERE id = $1 AND status IN ($4, $5)
+	`
+	_, err := pool.Exec(ctx, query, id, models.ActionPending, time.Now().UnixMilli(),
+		models.ActionFailed, models.ActionConflict)
+	if err != nil {
+		return fmt.Errorf("error retrying action: %v", err)
+	}
+	return nil
+}
+
+// DeleteAction removes an action from the outbox
+func DeleteAction(ctx context.Context, pool *pgxpool.Pool, id int64) error {
+	_, err := pool.Exec(ctx, `DELETE FROM pending_actions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error removing action: %v", err)
+	}
+	return nil
+}
+
+// CountActions returns how many actions are in each state
+func CountActions(ctx context.Context, pool *pgxpool.Pool) (models.ActionCounts, error) {
+	rows, err := pool.Query(ctx, `SELECT status, COUNT(*) FROM pending_actions GROUP BY status`)
+	if err != nil {
+		return models.ActionCounts{}, fmt.Errorf("error counting actions: %v", err)
+	}
+	defer rows.Close()
+
+	var counts models.ActionCounts
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return models.ActionCounts{}, fmt.Errorf("error scanning action count: %v", err)
+		}
+		switch status {
+		case models.ActionPending:
+			counts.Pending = n
+		case models.ActionSent:
+			counts.Sent = n
+		case models.ActionFailed:
+			counts.Failed = n
+		case models.ActionConflict:
+			counts.Conflicts = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.ActionCounts{}, fmt.Errorf("error reading action counts: %v", err)
+	}
+	return counts, nil
+}
+
+// splitLines reverses strings.Join(values, "\n")
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
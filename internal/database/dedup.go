@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// fingerprintBatchSize is how many emails are fingerprinted per round
+// when backfilling
+const fingerprintBatchSize = 500
+
+// backfillFingerprints fingerprints emails saved before fingerprints were
+// recorded, a batch at a time since it needs whole bodies
+func backfillFingerprints(ctx context.Context, pool *pgxpool.Pool) error {
+	query := fmt.Sprintf(`SELECT %s FROM emails WHERE fingerprint = '' LIMIT $1`, emailColumns)
+	for {
+		rows, err := pool.Query(ctx, query, fingerprintBatchSize)
+		if err != nil {
+			return fmt.Errorf("error querying emails to fingerprint: %v", err)
+		}
+		emails, err := scanEmails(rows)
+		if err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+
+		batch := &pgx.Batch{}
+		for _, email := range emails {
+			batch.Queue(`UPDATE emails SET fingerprint = $1 WHERE id = $2`, email.Fingerprint(), email.ID)
+		}
+		err = pool.SendBatch(ctx, batch).Close()
+		if err != nil {
+			return fmt.Errorf("error saving fingerprints: %v", err)
+		}
+	}
+}
+
+// ListDuplicateClusters returns up to limit sets of emails sharing a
+// fingerprint, most copies first. Trashed emails are left out.
+func ListDuplicateClusters(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models.DuplicateCluster, error) {
+	rows, err := pool.Query(ctx, `
+	SELECT fingerprint, COALESCE(MIN(subject), ''), MIN(from_address), COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	WHERE deleted_at = 0 AND fingerprint <> ''
+	GROUP BY fingerprint
+	HAVING COUNT(*) > 1
+	ORDER BY COUNT(*) DESC, fingerprint
+	LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying duplicates: %v", err)
+	}
+	defer rows.Close()
+
+	var clusters []models.DuplicateCluster
+	for rows.Next() {
+		var c models.DuplicateCluster
+		err := rows.Scan(&c.Fingerprint, &c.Subject, &c.From, &c.Count, &c.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning duplicates: %v", err)
+		}
+		clusters = append(clusters, c)
+	}
+
+	return clusters, rows.Err()
+}
+
+// GetDuplicates returns the emails with a fingerprint, oldest first
+func GetDuplicates(ctx context.Context, pool *pgxpool.Pool, fingerprint string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE fingerprint = $1 AND deleted_at = 0
+	ORDER BY received_at, id
+	`, emailColumns)
+
+	rows, err := pool.Query(ctx, query, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("error querying duplicates: %v", err)
+	}
+
+	return scanEmails(rows)
+}
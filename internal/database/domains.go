@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// backfillSenderDomains fills in sender_domain for emails saved before it
+// was recorded. The public suffix list lives in Go, so it cannot be done
+// in SQL.
+func backfillSenderDomains(ctx context.Context, pool *pgxpool.Pool) error {
+	rows, err := pool.Query(ctx, `SELECT id, from_address FROM emails WHERE sender_domain = '' AND from_address <> ''`)
+	if err != nil {
+		return fmt.Errorf("error querying sender domains: %v", err)
+	}
+
+	batch := &pgx.Batch{}
+	for rows.Next() {
+		var id, from string
+		err := rows.Scan(&id, &from)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning sender: %v", err)
+		}
+		batch.Queue(`UPDATE emails SET sender_domain = $1 WHERE id = $2`, models.SenderDomain(from), id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error querying sender domains: %v", err)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	err = pool.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("error saving sender domains: %v", err)
+	}
+	return nil
+}
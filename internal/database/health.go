@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tables lists every table CreateTables makes
+var tables = []string{"emails", "labels", "email_labels", "sync_state", "sync_locks", "sync_runs", "pending_actions"}
+
+// Ping checks that a connection from the pool answers
+func Ping(ctx context.Context, pool *pgxpool.Pool) error {
+	if err := pool.Ping(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %v", err)
+	}
+	return nil
+}
+
+// CountRows returns the number of rows in each table
+func CountRows(ctx context.Context, pool *pgxpool.Pool) (map[string]int64, error) {
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var n int64
+		// Table names come from the list above, never from input
+		if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+			return nil, fmt.Errorf("error counting %s rows: %v", table, err)
+		}
+		counts[table] = n
+	}
+	return counts, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SaveLabels upserts the label definitions fetched from Gmail
+func SaveLabels(ctx context.Context, pool *pgxpool.Pool, labels []models.Label) error {
+	batch := &pgx.Batch{}
+	for _, label := range labels {
+		batch.Queue(`
+		INSERT INTO labels (id, name, type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			type = EXCLUDED.type
+		`, label.ID, label.Name, label.Type)
+	}
+
+	err := pool.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("error saving labels: %v", err)
+	}
+	return nil
+}
+
+// GetAllLabels gets every known label, system labels first
+func GetAllLabels(ctx context.Context, pool *pgxpool.Pool) ([]models.Label, error) {
+	query := `
+	SELECT id, name, type
+	FROM labels
+	ORDER BY type = 'user', name
+	`
+
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying labels: %v", err)
+	}
+	defer rows.Close()
+
+	var labels []models.Label
+	for rows.Next() {
+		var label models.Label
+		err := rows.Scan(&label.ID, &label.Name, &label.Type)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, rows.Err()
+}
+
+// GetEmailsByLabel gets all emails carrying a label with sorting
+func GetEmailsByLabel(ctx context.Context, pool *pgxpool.Pool, labelID string, sortBy string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE id IN (SELECT email_id FROM email_labels WHERE label_id = $1) AND deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
+
+	rows, err := pool.Query(ctx, query, labelID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// AddEmailLabel records that emails now carry a label
+func AddEmailLabel(ctx context.Context, pool *pgxpool.Pool, emailIDs []string, labelID string) error {
+	_, err := pool.Exec(ctx, `
+	INSERT INTO email_labels (email_id, label_id)
+	SELECT unnest($1::text[]), $2
+	ON CONFLICT DO NOTHING
+	`, emailIDs, labelID)
+	if err != nil {
+		return fmt.Errorf("error labeling emails: %v", err)
+	}
+	return nil
+}
+
+// RemoveEmailLabel records that emails no longer carry a label
+func RemoveEmailLabel(ctx context.Context, pool *pgxpool.Pool, emailIDs []string, labelID string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM email_labels WHERE email_id = ANY($1) AND label_id = $2`, emailIDs, labelID)
+	if err != nil {
+		return fmt.Errorf("error removing label: %v", err)
+	}
+	return nil
+}
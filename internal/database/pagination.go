@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// keysetColumn returns the sort column and direction used for keyset
+// pagination. Unknown orders fall back to newest first, since created_at
+// is not unique enough to page on.
+func keysetColumn(sortBy string) (column string, descending bool) {
+	switch sortBy {
+	case "date_oldest":
+		return "date_received", false
+	case "sender_asc":
+		return "from_address", false
+	case "sender_desc":
+		return "from_address", true
+	default:
+		return "date_received", true
+	}
+}
+
+// filterClause appends the conditions for filter to conditions and args
+func filterClause(filter models.EmailFilter, conditions []string, args []any) ([]string, []any) {
+	if filter.Trash {
+		conditions = append(conditions, "deleted_at > 0")
+	} else {
+		conditions = append(conditions, "deleted_at = 0")
+	}
+	if filter.Sender != "" {
+		args = append(args, filter.Sender)
+		conditions = append(conditions, fmt.Sprintf("from_address = $%d", len(args)))
+	}
+	if filter.SenderContains != "" {
+		args = append(args, filter.SenderContains)
+		conditions = append(conditions, fmt.Sprintf("strpos(lower(from_address), lower($%d)) > 0", len(args)))
+	}
+	if filter.Domain != "" {
+		args = append(args, filter.Domain)
+		conditions = append(conditions, fmt.Sprintf("sender_domain = $%d", len(args)))
+	}
+	if filter.Label != "" {
+		args = append(args, filter.Label)
+		conditions = append(conditions, fmt.Sprintf("id IN (SELECT email_id FROM email_labels WHERE label_id = $%d)", len(args)))
+	}
+	if !filter.Before.IsZero() {
+		args = append(args, filter.Before.UnixMilli())
+		conditions = append(conditions, fmt.Sprintf("received_at > 0 AND received_at < $%d", len(args)))
+	}
+	if filter.MinSize > 0 {
+		args = append(args, filter.MinSize)
+		conditions = append(conditions, fmt.Sprintf("size_estimate >= $%d", len(args)))
+	}
+	if filter.Unsubscribable {
+		conditions = append(conditions, "headers ILIKE '%list-unsubscribe:%'")
+	}
+	if filter.IDs != nil {
+		args = append(args, filter.IDs)
+		conditions = append(conditions, fmt.Sprintf("id = ANY($%d)", len(args)))
+	}
+	if strings.TrimSpace(filter.Query) != "" {
+		args = append(args, filter.Query)
+		conditions = append(conditions, fmt.Sprintf("%s @@ websearch_to_tsquery('simple', $%d)", searchDocument, len(args)))
+	}
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// ListEmails returns one page of emails matching filter. Pass the cursor
+// returned by the previous call as after, nil for the first page. The
+// returned cursor is nil when there are no more pages.
+func ListEmails(ctx context.Context, pool *pgxpool.Pool, filter models.EmailFilter, sortBy string, after *models.EmailCursor, limit int) ([]models.Email, *models.EmailCursor, error) {
+	column, descending := keysetColumn(sortBy)
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != nil {
+		args = append(args, after.Key, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, compare, len(args)-1, len(args)))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT $%d
+	`, emailColumns, whereClause(conditions), column, direction, direction, len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	emails, err := scanEmails(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(emails) < limit {
+		return emails, nil, nil
+	}
+
+	last := emails[len(emails)-1]
+	next := &models.EmailCursor{ID: last.ID, Key: last.Date}
+	if column == "from_address" {
+		next.Key = last.From
+	}
+	return emails, next, nil
+}
+
+// ListEmailIDs returns the IDs of every email matching filter, so a
+// selection can cover rows that were never loaded
+func ListEmailIDs(ctx context.Context, pool *pgxpool.Pool, filter models.EmailFilter) ([]string, error) {
+	conditions, args := filterClause(filter, nil, nil)
+	query := fmt.Sprintf(`SELECT id FROM emails %s`, whereClause(conditions))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying email IDs: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email ID: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListSenders returns one page of senders matching filter with their
+// email counts, ordered by address. Pass the last address of the previous
+// page as after, "" for the first page.
+func ListSenders(ctx context.Context, pool *pgxpool.Pool, filter models.EmailFilter, descending bool, after string, limit int) ([]models.SenderCount, error) {
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != "" {
+		args = append(args, after)
+		conditions = append(conditions, fmt.Sprintf("from_address %s $%d", compare, len(args)))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT from_address, COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	%s
+	GROUP BY from_address
+	ORDER BY from_address %s
+	LIMIT $%d
+	`, whereClause(conditions), direction, len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying senders: %v", err)
+	}
+	defer rows.Close()
+
+	var senders []models.SenderCount
+	for rows.Next() {
+		var sender models.SenderCount
+		err := rows.Scan(&sender.Address, &sender.Count, &sender.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sender: %v", err)
+		}
+		senders = append(senders, sender)
+	}
+
+	return senders, rows.Err()
+}
+
+// ListDomains returns one page of sender domains matching filter with
+// their email and sender counts, ordered by domain. Pass the last domain
+// of the previous page as after, "" for the first page.
+func ListDomains(ctx context.Context, pool *pgxpool.Pool, filter models.EmailFilter, descending bool, after string, limit int) ([]models.DomainCount, error) {
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != "" {
+		args = append(args, after)
+		conditions = append(conditions, fmt.Sprintf("sender_domain %s $%d", compare, len(args)))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT sender_domain, COUNT(*), COUNT(DISTINCT from_address)
+	FROM emails
+	%s
+	GROUP BY sender_domain
+	ORDER BY sender_domain %s
+	LIMIT $%d
+	`, whereClause(conditions), direction, len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying domains: %v", err)
+	}
+	defer rows.Close()
+
+	var domains []models.DomainCount
+	for rows.Next() {
+		var domain models.DomainCount
+		err := rows.Scan(&domain.Domain, &domain.Count, &domain.Senders)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning domain: %v", err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
@@ -3,143 +3,209 @@ package database
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// SaveEmails saves emails to database
-func SaveEmails(pool *pgxpool.Pool, emails []models.Email) error {
-	ctx := context.Background()
+const upsertEmailQuery = `
+	INSERT INTO emails (id, from_address, subject, body, date_received, body_html, headers, thread_id, received_at, sender_domain, size_estimate, attachments, fingerprint)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (id) DO UPDATE SET
+		from_address = EXCLUDED.from_address,
+		subject = EXCLUDED.subject,
+		body = EXCLUDED.body,
+		date_received = EXCLUDED.date_received,
+		body_html = EXCLUDED.body_html,
+		headers = EXCLUDED.headers,
+		thread_id = EXCLUDED.thread_id,
+		received_at = EXCLUDED.received_at,
+		sender_domain = EXCLUDED.sender_domain,
+		size_estimate = EXCLUDED.size_estimate,
+		attachments = EXCLUDED.attachments,
+		fingerprint = EXCLUDED.fingerprint
+	`
+
+// queueEmail adds the statements that upsert an email and replace its
+// labels to batch
+func queueEmail(batch *pgx.Batch, email models.Email) {
+	batch.Queue(upsertEmailQuery, email.ID, email.From, email.Subject, email.Body, email.Date, email.HTMLBody, email.Headers,
+		email.ThreadKey(), unixMilli(email.ReceivedAt), email.SenderDomain(), email.SizeEstimate,
+		strings.Join(email.Attachments, "\n"), email.Fingerprint())
+	batch.Queue(`DELETE FROM email_labels WHERE email_id = $1`, email.ID)
+	if len(email.Labels) > 0 {
+		batch.Queue(`
+		INSERT INTO email_labels (email_id, label_id)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING
+		`, email.ID, email.Labels)
+	}
+}
+
+// unixMilli stores a time as Unix milliseconds, 0 for the zero time
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
 
+// SaveEmails saves emails to database
+func SaveEmails(ctx context.Context, pool *pgxpool.Pool, emails []models.Email) error {
+	batch := &pgx.Batch{}
 	for _, email := range emails {
-		query := `
-		INSERT INTO emails (id, from_address, subject, body, date_received)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (id) DO UPDATE SET
-			from_address = EXCLUDED.from_address,
-			subject = EXCLUDED.subject,
-			body = EXCLUDED.body,
-			date_received = EXCLUDED.date_received
-		`
-
-		_, err := pool.Exec(ctx, query, email.ID, email.From, email.Subject, email.Body, email.Date)
-		if err != nil {
-			return fmt.Errorf("error saving email %s: %v", email.ID, err)
-		}
+		queueEmail(batch, email)
+	}
+
+	err := pool.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("error saving emails: %v", err)
 	}
 
-	fmt.Printf("Successfully saved %d emails\n", len(emails))
+	logger.Debug("Saved emails", "count", len(emails))
 	return nil
 }
 
-// GetAllEmails gets all emails with optional sorting
-func GetAllEmails(pool *pgxpool.Pool, sortBy string) ([]models.Email, error) {
-	ctx := context.Background()
+// emailColumns is the select list scanned by scanEmails
+const emailColumns = `
+	id, from_address, subject, body, date_received, body_html, headers, thread_id, received_at, size_estimate, attachments,
+	ARRAY(SELECT label_id FROM email_labels WHERE email_id = emails.id ORDER BY label_id)
+	`
 
-	// Determine sort order
-	var orderClause string
+// orderBy returns the ORDER BY clause for a sort order
+func orderBy(sortBy string) string {
 	switch sortBy {
 	case "date_newest":
-		orderClause = "ORDER BY date_received DESC"
+		return "ORDER BY date_received DESC"
 	case "date_oldest":
-		orderClause = "ORDER BY date_received ASC"
+		return "ORDER BY date_received ASC"
 	case "sender_asc":
-		orderClause = "ORDER BY from_address ASC"
+		return "ORDER BY from_address ASC"
 	case "sender_desc":
-		orderClause = "ORDER BY from_address DESC"
+		return "ORDER BY from_address DESC"
 	default:
-		orderClause = "ORDER BY created_at DESC"
+		return "ORDER BY created_at DESC"
 	}
+}
 
-	query := fmt.Sprintf(`
-	SELECT id, from_address, subject, body, date_received
-	FROM emails
-	%s
-	`, orderClause)
-
-	rows, err := pool.Query(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("error querying emails: %v", err)
-	}
+// scanEmails reads every row selected with emailColumns
+func scanEmails(rows pgx.Rows) ([]models.Email, error) {
 	defer rows.Close()
 
 	var emails []models.Email
 	for rows.Next() {
 		var email models.Email
-		err := rows.Scan(&email.ID, &email.From, &email.Subject, &email.Body, &email.Date)
+		var receivedAt int64
+		var attachments string
+		err := rows.Scan(
+			&email.ID,
+			&email.From,
+			&email.Subject,
+			&email.Body,
+			&email.Date,
+			&email.HTMLBody,
+			&email.Headers,
+			&email.ThreadID,
+			&receivedAt,
+			&email.SizeEstimate,
+			&attachments,
+			&email.Labels,
+		)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning email: %v", err)
 		}
+		email.ReceivedAt = fromUnixMilli(receivedAt)
+		if attachments != "" {
+			email.Attachments = strings.Split(attachments, "\n")
+		}
 		emails = append(emails, email)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading emails: %v", err)
+	}
 
 	return emails, nil
 }
 
-// GetEmailsByFrom retrieves emails from a specific sender with sorting
-func GetEmailsByFrom(pool *pgxpool.Pool, fromAddress string, sortBy string) ([]models.Email, error) {
-	ctx := context.Background()
+// GetAllEmails gets all emails with optional sorting
+func GetAllEmails(ctx context.Context, pool *pgxpool.Pool, sortBy string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
 
-	// Determine sort order
-	var orderClause string
-	switch sortBy {
-	case "date_newest":
-		orderClause = "ORDER BY date_received DESC"
-	case "date_oldest":
-		orderClause = "ORDER BY date_received ASC"
-	case "sender_asc":
-		orderClause = "ORDER BY from_address ASC"
-	case "sender_desc":
-		orderClause = "ORDER BY from_address DESC"
-	default:
-		orderClause = "ORDER BY created_at DESC"
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
 	}
 
+	return scanEmails(rows)
+}
+
+// GetEmail gets a single email by ID, whether or not it is in the trash.
+// It returns models.ErrNotFound if there is no such email.
+func GetEmail(ctx context.Context, pool *pgxpool.Pool, emailID string) (models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE id = $1
+	`, emailColumns)
+
+	rows, err := pool.Query(ctx, query, emailID)
+	if err != nil {
+		return models.Email{}, fmt.Errorf("error querying email: %v", err)
+	}
+
+	emails, err := scanEmails(rows)
+	if err != nil {
+		return models.Email{}, err
+	}
+	if len(emails) == 0 {
+		return models.Email{}, models.ErrNotFound
+	}
+	return emails[0], nil
+}
+
+// GetEmailsByFrom retrieves emails from a specific sender with sorting
+func GetEmailsByFrom(ctx context.Context, pool *pgxpool.Pool, fromAddress string, sortBy string) ([]models.Email, error) {
 	query := fmt.Sprintf(`
-	SELECT id, from_address, subject, body, date_received
+	SELECT %s
 	FROM emails
-	WHERE from_address LIKE $1
+	WHERE from_address LIKE $1 AND deleted_at = 0
 	%s
-	`, orderClause)
+	`, emailColumns, orderBy(sortBy))
 
 	rows, err := pool.Query(ctx, query, "%"+fromAddress+"%")
 	if err != nil {
 		return nil, fmt.Errorf("error querying emails: %v", err)
 	}
-	defer rows.Close()
 
-	var emails []models.Email
-	for rows.Next() {
-		var email models.Email
-		err := rows.Scan(
-			&email.ID,
-			&email.From,
-			&email.Subject,
-			&email.Body,
-			&email.Date,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error scanning email: %v", err)
-		}
-		emails = append(emails, email)
-	}
-
-	return emails, nil
+	return scanEmails(rows)
 }
 
 // GetEmailsBySender gets all emails from a specific sender with sorting
-func GetEmailsBySender(pool *pgxpool.Pool, sender string, sortBy string) ([]models.Email, error) {
-	return GetEmailsByFrom(pool, sender, sortBy)
+func GetEmailsBySender(ctx context.Context, pool *pgxpool.Pool, sender string, sortBy string) ([]models.Email, error) {
+	return GetEmailsByFrom(ctx, pool, sender, sortBy)
 }
 
 // GetAllSenders gets a list of unique senders
-func GetAllSenders(pool *pgxpool.Pool) ([]string, error) {
-	ctx := context.Background()
-
+func GetAllSenders(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
 	query := `
 	SELECT DISTINCT from_address
 	FROM emails
+	WHERE deleted_at = 0
 	ORDER BY from_address
 	`
 
@@ -162,13 +228,13 @@ func GetAllSenders(pool *pgxpool.Pool) ([]string, error) {
 	return senders, nil
 }
 
-// DeleteEmail deletes a single email by ID
-func DeleteEmail(pool *pgxpool.Pool, emailID string) error {
-	ctx := context.Background()
+// trashEmailQuery moves an email to the trash. $1 is the time in Unix
+// milliseconds.
+const trashEmailQuery = `UPDATE emails SET deleted_at = $1 WHERE id = $2 AND deleted_at = 0`
 
-	query := `DELETE FROM emails WHERE id = $1`
-	
-	result, err := pool.Exec(ctx, query, emailID)
+// DeleteEmail moves a single email by ID to the trash
+func DeleteEmail(ctx context.Context, pool *pgxpool.Pool, emailID string) error {
+	result, err := pool.Exec(ctx, trashEmailQuery, time.Now().UnixMilli(), emailID)
 	if err != nil {
 		return fmt.Errorf("error deleting email: %v", err)
 	}
@@ -178,38 +244,35 @@ func DeleteEmail(pool *pgxpool.Pool, emailID string) error {
 		return fmt.Errorf("email not found")
 	}
 
-	fmt.Printf("Deleted email: %s\n", emailID)
+	logger.Debug("Deleted email", "id", emailID)
 	return nil
 }
 
-// DeleteEmails deletes multiple emails by their IDs
-func DeleteEmails(pool *pgxpool.Pool, emailIDs []string) error {
-	ctx := context.Background()
-
+// DeleteEmails moves multiple emails by their IDs to the trash. They all
+// get the same deletion time.
+func DeleteEmails(ctx context.Context, pool *pgxpool.Pool, emailIDs []string) error {
+	now := time.Now().UnixMilli()
 	for _, id := range emailIDs {
-		query := `DELETE FROM emails WHERE id = $1`
-		_, err := pool.Exec(ctx, query, id)
+		_, err := pool.Exec(ctx, trashEmailQuery, now, id)
 		if err != nil {
 			return fmt.Errorf("error deleting email %s: %v", id, err)
 		}
 	}
 
-	fmt.Printf("Deleted %d emails\n", len(emailIDs))
+	logger.Info("Deleted emails", "count", len(emailIDs))
 	return nil
 }
 
-// DeleteEmailsBySender deletes all emails from a specific sender
-func DeleteEmailsBySender(pool *pgxpool.Pool, sender string) error {
-	ctx := context.Background()
+// DeleteEmailsBySender moves all emails from a specific sender to the trash
+func DeleteEmailsBySender(ctx context.Context, pool *pgxpool.Pool, sender string) error {
+	query := `UPDATE emails SET deleted_at = $1 WHERE from_address LIKE $2 AND deleted_at = 0`
 
-	query := `DELETE FROM emails WHERE from_address LIKE $1`
-	
-	result, err := pool.Exec(ctx, query, "%"+sender+"%")
+	result, err := pool.Exec(ctx, query, time.Now().UnixMilli(), "%"+sender+"%")
 	if err != nil {
 		return fmt.Errorf("error deleting emails: %v", err)
 	}
 
 	rowsAffected := result.RowsAffected()
-	fmt.Printf("Deleted %d emails from %s\n", rowsAffected, sender)
+	logger.Info("Deleted emails from sender", "count", rowsAffected, "sender", sender)
 	return nil
 }
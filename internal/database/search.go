@@ -0,0 +1,31 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// searchDocument must match the expression of idx_emails_search so the
+// index is used
+const searchDocument = `to_tsvector('simple', coalesce(subject, '') || ' ' || from_address || ' ' || coalesce(body, ''))`
+
+// SearchEmails runs a full text search over sender, subject and body.
+// query accepts web search syntax: quoted phrases, OR and -excluded words.
+func SearchEmails(ctx context.Context, pool *pgxpool.Pool, query string, sortBy string) ([]models.Email, error) {
+	sql := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE %s @@ websearch_to_tsquery('simple', $1) AND deleted_at = 0
+	%s
+	`, emailColumns, searchDocument, orderBy(sortBy))
+
+	rows, err := pool.Query(ctx, sql, query)
+	if err != nil {
+		return nil, fmt.Errorf("error searching emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// QueueAction adds a pending action to the outbox, due now, and sets its
+// ID and status
+func (s *Store) QueueAction(ctx context.Context, action *models.PendingAction) error {
+	query := `
+	INSERT INTO pending_actions (kind, email_ids, add_labels, remove_labels, url, status, created_at, next_attempt_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	action.Status = models.ActionPending
+	result, err := s.db.ExecContext(ctx, query, action.Kind, strings.Join(action.EmailIDs, "\n"),
+		strings.Join(action.AddLabels, "\n"), strings.Join(action.RemoveLabels, "\n"), action.URL,
+		action.Status, unixMilli(action.CreatedAt), unixMilli(action.CreatedAt))
+	if err != nil {
+		return fmt.Errorf("error queueing action: %v", err)
+	}
+	action.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error queueing action: %v", err)
+	}
+	return nil
+}
+
+// ListActions returns the actions in status, or all of them if status is
+// empty, oldest first
+func (s *Store) ListActions(ctx context.Context, status string) ([]models.PendingAction, error) {
+	query := `
+	SELECT id, kind, email_ids, add_labels, remove_labels, url, status,
+		created_at, next_attempt_at, sent_at, attempts, last_error
+	FROM pending_actions
+	WHERE ? = '' OR status = ?
+	ORDER BY id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, status, status)
+	if err != nil {
+		return nil, fmt.Errorf("error querying pending actions: %v", err)
+	}
+	defer rows.Close()
+
+	var actions []models.PendingAction
+	for rows.Next() {
+		var action models.PendingAction
+		var ids, add, remove string
+		var createdAt, nextAttemptAt, sentAt int64
+		err := rows.Scan(&action.ID, &action.Kind, &ids, &add, &remove, &action.URL, &action.Status,
+			&createdAt, &nextAttemptAt, &sentAt, &action.Attempts, &action.LastError)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning pending action: %v", err)
+		}
+		action.EmailIDs = splitLines(ids)
+		action.AddLabels = splitLines(add)
+		action.RemoveLabels = splitLines(remove)
+		action.CreatedAt = fromUnixMilli(createdAt)
+		action.NextAttemptAt = fromUnixMilli(nextAttemptAt)
+		action.SentAt = fromUnixMilli(sentAt)
+		actions = append(actions, action)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading pending actions: %v", err)
+	}
+
+	return actions, nil
+}
+
+// ClaimAction leases a pending action to the caller until ttl from now,
+// so only one process sends it. It reports false if another holds it.
+func (s *Store) ClaimAction(ctx context.Context, id int64, ttl time.Duration) (bool, error) {
+	query := `
+	UPDATE pending_actions SET locked_until = ?
+	WHERE id = ? AND status = ? AND locked_until < ?
+	`
+	now := time.Now()
+	result, err := s.db.ExecContext(ctx, query, now.Add(ttl).UnixMilli(), id, models.ActionPending, now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error claiming action: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error claiming action: %v", err)
+	}
+	return n == 1, nil
+}
+
+// RecordActionFailure counts a failed attempt to send an action and
+// releases it to be retried at retryAt
+func (s *Store) RecordActionFailure(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	query := `
+	UPDATE pending_actions
+	SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?, locked_until = 0
+	WHERE id = ?
+	`
+	_, err := s.db.ExecContext(ctx, query, reason, unixMilli(retryAt), id)
+	if err != nil {
+		return fmt.Errorf("error recording failed action: %v", err)
+	}
+	return nil
+}
+
+// MarkActionSent records that Gmail accepted an action at sentAt
+func (s *Store) MarkActionSent(ctx context.Context, id int64, sentAt time.Time) error {
+	query := `
+	UPDATE pending_actions SET status = ?, sent_at = ?, last_error = '', locked_until = 0
+	WHERE id = ?
+	`
+	_, err := s.db.ExecContext(ctx, query, models.ActionSent, unixMilli(sentAt), id)
+	if err != nil {
+		return fmt.Errorf("error marking action sent: %v", err)
+	}
+	return nil
+}
+
+// SetActionStatus moves an action to status with reason as its last
+// error, and releases it
+func (s *Store) SetActionStatus(ctx context.Context, id int64, status, reason string) error {
+	query := `
+	UPDATE pending_actions SET status = ?, last_error = ?, locked_until = 0
+	WHERE id = ?
+	`
+	_, err := s.db.ExecContext(ctx, query, status, reason, id)
+	if err != nil {
+		return fmt.Errorf("error updating action: %v", err)
+	}
+	return nil
+}
+
+// RetryAction makes a failed or conflicting action pending again, due now
+func (s *Store) RetryAction(ctx context.Context, id int64) error {
+	query := `
+	UPDATE pending_actions
+	SET status = ?, attempts = 0, last_error = '', next_attempt_at = ?, sent_at = 0, locked_until = 0
+	WHERE id = ? AND status IN (?, ?)
+	`
+	_, err := s.db.ExecContext(ctx, query, models.ActionPending, time.Now().UnixMilli(), id,
+		models.ActionFailed, models.ActionConflict)
+	if err != nil {
+		return fmt.Errorf("error retrying action: %v", err)
+	}
+	return nil
+}
+
+// DeleteAction removes an action from the outbox
+func (s *Store) DeleteAction(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM pending_actions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error removing action: %v", err)
+	}
+	return nil
+}
+
+// CountActions returns how many actions are in each state
+func (s *Store) CountActions(ctx context.Context) (models.ActionCounts, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT status, COUNT(*) FROM pending_actions GROUP BY status`)
+	if err != nil {
+		return models.ActionCounts{}, fmt.Errorf("error counting actions: %v", err)
+	}
+	defer rows.Close()
+
+	var counts models.ActionCounts
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return models.ActionCounts{}, fmt.Errorf("error scanning action count: %v", err)
+		}
+		switch status {
+		case models.ActionPending:
+			counts.Pending = n
+		case models.ActionSent:
+			counts.Sent = n
+		case models.ActionFailed:
+			counts.Failed = n
+		case models.ActionConflict:
+			counts.Conflicts = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.ActionCounts{}, fmt.Errorf("error reading action counts: %v", err)
+	}
+	return counts, nil
+}
+
+// splitLines reverses strings.Join(values, "\n")
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
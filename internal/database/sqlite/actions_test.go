@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	label := models.PendingAction{Kind: models.ActionLabels, EmailIDs: []string{"m1", "m2"},
+		AddLabels: []string{"STARRED"}, RemoveLabels: []string{"UNREAD", "INBOX"}, CreatedAt: time.Now()}
+	trash := models.PendingAction{Kind: models.ActionTrash, EmailIDs: []string{"m3"}, CreatedAt: time.Now()}
+	for _, action := range []*models.PendingAction{&label, &trash} {
+		if err := s.QueueAction(ctx, action); err != nil {
+			t.Fatalf("QueueAction: %v", err)
+		}
+		if action.ID == 0 || action.Status != models.ActionPending {
+			t.Fatalf("queued action = %+v, want an ID and pending", action)
+		}
+	}
+
+	actions, err := s.ListActions(ctx, models.ActionPending)
+	if err != nil {
+		t.Fatalf("ListActions: %v", err)
+	}
+	if len(actions) != 2 || actions[0].ID != label.ID || actions[1].ID != trash.ID {
+		t.Fatalf("pending = %+v, want both oldest first", actions)
+	}
+	got := actions[0]
+	if !slices.Equal(got.EmailIDs, label.EmailIDs) || !slices.Equal(got.AddLabels, label.AddLabels) ||
+		!slices.Equal(got.RemoveLabels, label.RemoveLabels) {
+		t.Errorf("listed %+v, want the IDs and labels queued", got)
+	}
+	if actions[1].AddLabels != nil {
+		t.Errorf("trash action labels = %q, want none", actions[1].AddLabels)
+	}
+
+	claim := func(id int64) bool {
+		t.Helper()
+		claimed, err := s.ClaimAction(ctx, id, time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimAction: %v", err)
+		}
+		return claimed
+	}
+	if !claim(label.ID) {
+		t.Fatal("could not claim a pending action")
+	}
+	if claim(label.ID) {
+		t.Error("claimed an action another worker holds")
+	}
+
+	// A failure releases the action and counts the attempt
+	retryAt := time.Now().Add(time.Hour)
+	if err := s.RecordActionFailure(ctx, label.ID, "unreachable", retryAt); err != nil {
+		t.Fatal(err)
+	}
+	actions, _ = s.ListActions(ctx, models.ActionPending)
+	if actions[0].Attempts != 1 || actions[0].LastError != "unreachable" || actions[0].NextAttemptAt.UnixMilli() != retryAt.UnixMilli() {
+		t.Errorf("after a failure = %+v", actions[0])
+	}
+	if !claim(label.ID) {
+		t.Error("could not claim an action released after a failure")
+	}
+
+	sentAt := time.Now()
+	if err := s.MarkActionSent(ctx, label.ID, sentAt); err != nil {
+		t.Fatal(err)
+	}
+	if claim(label.ID) {
+		t.Error("claimed an action that was sent")
+	}
+	if err := s.SetActionStatus(ctx, trash.ID, models.ActionConflict, "outside the trash"); err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := s.CountActions(ctx)
+	if err != nil {
+		t.Fatalf("CountActions: %v", err)
+	}
+	if counts != (models.ActionCounts{Sent: 1, Conflicts: 1}) {
+		t.Errorf("counts = %+v, want one sent and one conflict", counts)
+	}
+
+	// Retrying only applies to failed and conflicting actions
+	if err := s.RetryAction(ctx, label.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RetryAction(ctx, trash.ID); err != nil {
+		t.Fatal(err)
+	}
+	actions, _ = s.ListActions(ctx, "")
+	if actions[0].Status != models.ActionSent || actions[0].SentAt.UnixMilli() != sentAt.UnixMilli() {
+		t.Errorf("retried sent action = %+v, want it left sent", actions[0])
+	}
+	if actions[1].Status != models.ActionPending || actions[1].LastError != "" {
+		t.Errorf("retried conflict = %+v, want it pending again", actions[1])
+	}
+
+	if err := s.DeleteAction(ctx, label.ID); err != nil {
+		t.Fatal(err)
+	}
+	actions, _ = s.ListActions(ctx, "")
+	if len(actions) != 1 || actions[0].ID != trash.ID {
+		t.Errorf("after deleting = %+v, want only the trash action", actions)
+	}
+}
@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// fingerprintBatchSize is how many emails are fingerprinted per round
+// when backfilling
+const fingerprintBatchSize = 500
+
+// backfillFingerprints fingerprints emails saved before fingerprints were
+// recorded, a batch at a time since it needs whole bodies
+func (s *Store) backfillFingerprints(ctx context.Context) error {
+	query := fmt.Sprintf(`SELECT %s FROM emails WHERE fingerprint = '' LIMIT ?`, emailColumns)
+	for {
+		rows, err := s.db.QueryContext(ctx, query, fingerprintBatchSize)
+		if err != nil {
+			return fmt.Errorf("error querying emails to fingerprint: %v", err)
+		}
+		emails, err := scanEmails(rows)
+		if err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error starting transaction: %v", err)
+		}
+		for _, email := range emails {
+			_, err := tx.ExecContext(ctx, `UPDATE emails SET fingerprint = ? WHERE id = ?`, email.Fingerprint(), email.ID)
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("error saving fingerprint of email %s: %v", email.ID, err)
+			}
+		}
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("error saving fingerprints: %v", err)
+		}
+	}
+}
+
+// ListDuplicateClusters returns up to limit sets of emails sharing a
+// fingerprint, most copies first. Trashed emails are left out.
+func (s *Store) ListDuplicateClusters(ctx context.Context, limit int) ([]models.DuplicateCluster, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT fingerprint, COALESCE(MIN(subject), ''), MIN(from_address), COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	WHERE deleted_at = 0 AND fingerprint <> ''
+	GROUP BY fingerprint
+	HAVING COUNT(*) > 1
+	ORDER BY COUNT(*) DESC, fingerprint
+	LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying duplicates: %v", err)
+	}
+	defer rows.Close()
+
+	var clusters []models.DuplicateCluster
+	for rows.Next() {
+		var c models.DuplicateCluster
+		err := rows.Scan(&c.Fingerprint, &c.Subject, &c.From, &c.Count, &c.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning duplicates: %v", err)
+		}
+		clusters = append(clusters, c)
+	}
+
+	return clusters, rows.Err()
+}
+
+// GetDuplicates returns the emails with a fingerprint, oldest first
+func (s *Store) GetDuplicates(ctx context.Context, fingerprint string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE fingerprint = ? AND deleted_at = 0
+	ORDER BY received_at, id
+	`, emailColumns)
+
+	rows, err := s.db.QueryContext(ctx, query, fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("error querying duplicates: %v", err)
+	}
+
+	return scanEmails(rows)
+}
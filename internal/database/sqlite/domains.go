@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// backfillSenderDomains fills in sender_domain for emails saved before it
+// was recorded. The public suffix list lives in Go, so it cannot be done
+// in SQL.
+func (s *Store) backfillSenderDomains(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, from_address FROM emails WHERE sender_domain = '' AND from_address <> ''`)
+	if err != nil {
+		return fmt.Errorf("error querying sender domains: %v", err)
+	}
+
+	domains := make(map[string]string)
+	for rows.Next() {
+		var id, from string
+		err := rows.Scan(&id, &from)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning sender: %v", err)
+		}
+		domains[id] = models.SenderDomain(from)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error querying sender domains: %v", err)
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for id, domain := range domains {
+		_, err := tx.ExecContext(ctx, `UPDATE emails SET sender_domain = ? WHERE id = ?`, domain, id)
+		if err != nil {
+			return fmt.Errorf("error saving sender domain of email %s: %v", id, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error saving sender domains: %v", err)
+	}
+	return nil
+}
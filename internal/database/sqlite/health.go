@@ -0,0 +1,31 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// tables lists every table CreateTables makes, except the search index
+var tables = []string{"emails", "labels", "email_labels", "sync_state", "sync_locks", "sync_runs", "pending_actions"}
+
+// Ping checks that the database answers
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %v", err)
+	}
+	return nil
+}
+
+// CountRows returns the number of rows in each table
+func (s *Store) CountRows(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var n int64
+		// Table names come from the list above, never from input
+		if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+			return nil, fmt.Errorf("error counting %s rows: %v", table, err)
+		}
+		counts[table] = n
+	}
+	return counts, nil
+}
@@ -0,0 +1,249 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// keysetColumn returns the sort column and direction used for keyset
+// pagination. Unknown orders fall back to newest first, since created_at
+// is not unique enough to page on.
+func keysetColumn(sortBy string) (column string, descending bool) {
+	switch sortBy {
+	case "date_oldest":
+		return "date_received", false
+	case "sender_asc":
+		return "from_address", false
+	case "sender_desc":
+		return "from_address", true
+	default:
+		return "date_received", true
+	}
+}
+
+// filterClause appends the conditions for filter to conditions and args
+func filterClause(filter models.EmailFilter, conditions []string, args []any) ([]string, []any) {
+	if filter.Trash {
+		conditions = append(conditions, "deleted_at > 0")
+	} else {
+		conditions = append(conditions, "deleted_at = 0")
+	}
+	if filter.Sender != "" {
+		conditions = append(conditions, "from_address = ?")
+		args = append(args, filter.Sender)
+	}
+	if filter.SenderContains != "" {
+		conditions = append(conditions, "instr(lower(from_address), lower(?)) > 0")
+		args = append(args, filter.SenderContains)
+	}
+	if filter.Domain != "" {
+		conditions = append(conditions, "sender_domain = ?")
+		args = append(args, filter.Domain)
+	}
+	if filter.Label != "" {
+		conditions = append(conditions, "id IN (SELECT email_id FROM email_labels WHERE label_id = ?)")
+		args = append(args, filter.Label)
+	}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, "received_at > 0 AND received_at < ?")
+		args = append(args, filter.Before.UnixMilli())
+	}
+	if filter.MinSize > 0 {
+		conditions = append(conditions, "size_estimate >= ?")
+		args = append(args, filter.MinSize)
+	}
+	if filter.Unsubscribable {
+		// LIKE ignores ASCII case in SQLite
+		conditions = append(conditions, "headers LIKE '%List-Unsubscribe:%'")
+	}
+	if filter.IDs != nil {
+		if len(filter.IDs) == 0 {
+			conditions = append(conditions, "0")
+		} else {
+			conditions = append(conditions, "id IN (?"+strings.Repeat(", ?", len(filter.IDs)-1)+")")
+			for _, id := range filter.IDs {
+				args = append(args, id)
+			}
+		}
+	}
+	if strings.TrimSpace(filter.Query) != "" {
+		match := ftsQuery(filter.Query)
+		if match == "" {
+			// A query of only exclusions has no FTS5 form and matches nothing
+			conditions = append(conditions, "0")
+		} else {
+			conditions = append(conditions, "rowid IN (SELECT rowid FROM emails_fts WHERE emails_fts MATCH ?)")
+			args = append(args, match)
+		}
+	}
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// ListEmails returns one page of emails matching filter. Pass the cursor
+// returned by the previous call as after, nil for the first page. The
+// returned cursor is nil when there are no more pages.
+func (s *Store) ListEmails(ctx context.Context, filter models.EmailFilter, sortBy string, after *models.EmailCursor, limit int) ([]models.Email, *models.EmailCursor, error) {
+	column, descending := keysetColumn(sortBy)
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (?, ?)", column, compare))
+		args = append(args, after.Key, after.ID)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT ?
+	`, emailColumns, whereClause(conditions), column, direction, direction)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	emails, err := scanEmails(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(emails) < limit {
+		return emails, nil, nil
+	}
+
+	last := emails[len(emails)-1]
+	next := &models.EmailCursor{ID: last.ID, Key: last.Date}
+	if column == "from_address" {
+		next.Key = last.From
+	}
+	return emails, next, nil
+}
+
+// ListEmailIDs returns the IDs of every email matching filter, so a
+// selection can cover rows that were never loaded
+func (s *Store) ListEmailIDs(ctx context.Context, filter models.EmailFilter) ([]string, error) {
+	conditions, args := filterClause(filter, nil, nil)
+	query := fmt.Sprintf(`SELECT id FROM emails %s`, whereClause(conditions))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying email IDs: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email ID: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListSenders returns one page of senders matching filter with their
+// email counts, ordered by address. Pass the last address of the previous
+// page as after, "" for the first page.
+func (s *Store) ListSenders(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.SenderCount, error) {
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != "" {
+		conditions = append(conditions, fmt.Sprintf("from_address %s ?", compare))
+		args = append(args, after)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT from_address, COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	%s
+	GROUP BY from_address
+	ORDER BY from_address %s
+	LIMIT ?
+	`, whereClause(conditions), direction)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying senders: %v", err)
+	}
+	defer rows.Close()
+
+	var senders []models.SenderCount
+	for rows.Next() {
+		var sender models.SenderCount
+		err := rows.Scan(&sender.Address, &sender.Count, &sender.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sender: %v", err)
+		}
+		senders = append(senders, sender)
+	}
+
+	return senders, rows.Err()
+}
+
+// ListDomains returns one page of sender domains matching filter with
+// their email and sender counts, ordered by domain. Pass the last domain
+// of the previous page as after, "" for the first page.
+func (s *Store) ListDomains(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.DomainCount, error) {
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions, args := filterClause(filter, nil, nil)
+	if after != "" {
+		conditions = append(conditions, fmt.Sprintf("sender_domain %s ?", compare))
+		args = append(args, after)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT sender_domain, COUNT(*), COUNT(DISTINCT from_address)
+	FROM emails
+	%s
+	GROUP BY sender_domain
+	ORDER BY sender_domain %s
+	LIMIT ?
+	`, whereClause(conditions), direction)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying domains: %v", err)
+	}
+	defer rows.Close()
+
+	var domains []models.DomainCount
+	for rows.Next() {
+		var domain models.DomainCount
+		err := rows.Scan(&domain.Domain, &domain.Count, &domain.Senders)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning domain: %v", err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
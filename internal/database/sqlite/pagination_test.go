@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+func TestListEmailsKeysetPaging(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	// Ties on the sort column are broken by ID
+	saveTestEmails(t, s,
+		models.Email{ID: "m1", From: "carol@example.com", Date: "2025-01-03"},
+		models.Email{ID: "m2", From: "alice@example.com", Date: "2025-01-01"},
+		models.Email{ID: "m3", From: "bob@example.com", Date: "2025-01-02"},
+		models.Email{ID: "m4", From: "alice@example.com", Date: "2025-01-02"},
+		models.Email{ID: "m5", From: "bob@example.com", Date: "2025-01-05"},
+	)
+	if err := s.DeleteEmails(ctx, []string{"m5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{"date_newest", []string{"m1", "m4", "m3", "m2"}},
+		{"date_oldest", []string{"m2", "m3", "m4", "m1"}},
+		{"sender_asc", []string{"m2", "m4", "m3", "m1"}},
+		{"sender_desc", []string{"m1", "m3", "m4", "m2"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 4, 10} {
+			var got []string
+			var after *models.EmailCursor
+			for pages := 0; ; pages++ {
+				if pages > len(tt.want) {
+					t.Fatalf("%s by %d never ends", tt.sortBy, limit)
+				}
+				emails, next, err := s.ListEmails(ctx, models.EmailFilter{}, tt.sortBy, after, limit)
+				if err != nil {
+					t.Fatalf("ListEmails: %v", err)
+				}
+				for _, email := range emails {
+					got = append(got, email.ID)
+				}
+				if next == nil {
+					break
+				}
+				after = next
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s by %d = %q, want %q", tt.sortBy, limit, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestListSendersPaging(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	saveTestEmails(t, s,
+		models.Email{ID: "m1", From: "Carol@Example.com"},
+		models.Email{ID: "m2", From: "alice@example.com"},
+		models.Email{ID: "m3", From: "alice@example.com"},
+		models.Email{ID: "m4", From: "bob@other.org"},
+	)
+
+	tests := []struct {
+		name       string
+		filter     models.EmailFilter
+		descending bool
+		after      string
+		want       []string
+	}{
+		{name: "first page", want: []string{"Carol@Example.com", "alice@example.com"}},
+		{name: "next page", after: "alice@example.com", want: []string{"bob@other.org"}},
+		{name: "descending", descending: true, want: []string{"bob@other.org", "alice@example.com"}},
+		{name: "contains ignores case", filter: models.EmailFilter{SenderContains: "EXAMPLE"},
+			want: []string{"Carol@Example.com", "alice@example.com"}},
+		{name: "contains takes % literally", filter: models.EmailFilter{SenderContains: "%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			senders, err := s.ListSenders(ctx, tt.filter, tt.descending, tt.after, 2)
+			if err != nil {
+				t.Fatalf("ListSenders: %v", err)
+			}
+			var got []string
+			for _, sender := range senders {
+				got = append(got, sender.Address)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("senders = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
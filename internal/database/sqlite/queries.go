@@ -0,0 +1,520 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+const upsertEmailQuery = `
+	INSERT INTO emails (id, from_address, subject, body, date_received, body_html, headers, thread_id, received_at, sender_domain, size_estimate, attachments, fingerprint)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		from_address = excluded.from_address,
+		subject = excluded.subject,
+		body = excluded.body,
+		date_received = excluded.date_received,
+		body_html = excluded.body_html,
+		headers = excluded.headers,
+		thread_id = excluded.thread_id,
+		received_at = excluded.received_at,
+		sender_domain = excluded.sender_domain,
+		size_estimate = excluded.size_estimate,
+		attachments = excluded.attachments,
+		fingerprint = excluded.fingerprint
+	`
+
+// emailColumns is the select list scanned by scanEmails
+const emailColumns = `
+	id, from_address, subject, body, date_received, body_html, headers, thread_id, received_at, size_estimate, attachments,
+	(SELECT json_group_array(label_id) FROM
+		(SELECT label_id FROM email_labels WHERE email_id = emails.id ORDER BY label_id))
+	`
+
+// orderBy returns the ORDER BY clause for a sort order
+func orderBy(sortBy string) string {
+	switch sortBy {
+	case "date_newest":
+		return "ORDER BY date_received DESC"
+	case "date_oldest":
+		return "ORDER BY date_received ASC"
+	case "sender_asc":
+		return "ORDER BY from_address ASC"
+	case "sender_desc":
+		return "ORDER BY from_address DESC"
+	default:
+		return "ORDER BY created_at DESC"
+	}
+}
+
+// scanEmails reads every row selected with emailColumns
+func scanEmails(rows *sql.Rows) ([]models.Email, error) {
+	defer rows.Close()
+
+	var emails []models.Email
+	for rows.Next() {
+		var email models.Email
+		var labels string
+		var receivedAt int64
+		var attachments string
+		err := rows.Scan(
+			&email.ID,
+			&email.From,
+			&email.Subject,
+			&email.Body,
+			&email.Date,
+			&email.HTMLBody,
+			&email.Headers,
+			&email.ThreadID,
+			&receivedAt,
+			&email.SizeEstimate,
+			&attachments,
+			&labels,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email: %v", err)
+		}
+		email.ReceivedAt = fromUnixMilli(receivedAt)
+		if attachments != "" {
+			email.Attachments = strings.Split(attachments, "\n")
+		}
+		err = json.Unmarshal([]byte(labels), &email.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding labels of email %s: %v", email.ID, err)
+		}
+		emails = append(emails, email)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading emails: %v", err)
+	}
+
+	return emails, nil
+}
+
+// unixMilli stores a time as Unix milliseconds, 0 for the zero time
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// saveEmails upserts emails and replaces their labels inside tx
+func saveEmails(ctx context.Context, tx *sql.Tx, emails []models.Email) error {
+	for _, email := range emails {
+		_, err := tx.ExecContext(ctx, upsertEmailQuery, email.ID, email.From, email.Subject, email.Body, email.Date, email.HTMLBody, email.Headers,
+			email.ThreadKey(), unixMilli(email.ReceivedAt), email.SenderDomain(), email.SizeEstimate,
+			strings.Join(email.Attachments, "\n"), email.Fingerprint())
+		if err != nil {
+			return fmt.Errorf("error saving email %s: %v", email.ID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM email_labels WHERE email_id = ?`, email.ID)
+		if err != nil {
+			return fmt.Errorf("error saving labels of email %s: %v", email.ID, err)
+		}
+		for _, label := range email.Labels {
+			_, err = tx.ExecContext(ctx, `
+			INSERT INTO email_labels (email_id, label_id)
+			VALUES (?, ?)
+			ON CONFLICT DO NOTHING
+			`, email.ID, label)
+			if err != nil {
+				return fmt.Errorf("error saving labels of email %s: %v", email.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SaveEmails saves emails to database
+func (s *Store) SaveEmails(ctx context.Context, emails []models.Email) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveEmails(ctx, tx, emails); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing emails: %v", err)
+	}
+
+	logger.Debug("Saved emails", "count", len(emails))
+	return nil
+}
+
+// GetAllEmails gets all emails with optional sorting
+func (s *Store) GetAllEmails(ctx context.Context, sortBy string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// GetEmail gets a single email by ID, whether or not it is in the trash.
+// It returns models.ErrNotFound if there is no such email.
+func (s *Store) GetEmail(ctx context.Context, emailID string) (models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE id = ?
+	`, emailColumns)
+
+	rows, err := s.db.QueryContext(ctx, query, emailID)
+	if err != nil {
+		return models.Email{}, fmt.Errorf("error querying email: %v", err)
+	}
+
+	emails, err := scanEmails(rows)
+	if err != nil {
+		return models.Email{}, err
+	}
+	if len(emails) == 0 {
+		return models.Email{}, models.ErrNotFound
+	}
+	return emails[0], nil
+}
+
+// GetEmailsBySender gets all emails from a specific sender with sorting
+func (s *Store) GetEmailsBySender(ctx context.Context, sender string, sortBy string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE from_address LIKE ? AND deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
+
+	rows, err := s.db.QueryContext(ctx, query, "%"+sender+"%")
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// GetAllSenders gets a list of unique senders
+func (s *Store) GetAllSenders(ctx context.Context) ([]string, error) {
+	query := `
+	SELECT DISTINCT from_address
+	FROM emails
+	WHERE deleted_at = 0
+	ORDER BY from_address
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying senders: %v", err)
+	}
+	defer rows.Close()
+
+	var senders []string
+	for rows.Next() {
+		var sender string
+		err := rows.Scan(&sender)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sender: %v", err)
+		}
+		senders = append(senders, sender)
+	}
+
+	return senders, rows.Err()
+}
+
+// trashEmailQuery moves an email to the trash. The first argument is the
+// time in Unix milliseconds.
+const trashEmailQuery = `UPDATE emails SET deleted_at = ? WHERE id = ? AND deleted_at = 0`
+
+// DeleteEmail moves a single email by ID to the trash
+func (s *Store) DeleteEmail(ctx context.Context, emailID string) error {
+	result, err := s.db.ExecContext(ctx, trashEmailQuery, time.Now().UnixMilli(), emailID)
+	if err != nil {
+		return fmt.Errorf("error deleting email: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting email: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("email not found")
+	}
+
+	logger.Debug("Deleted email", "id", emailID)
+	return nil
+}
+
+// DeleteEmails moves multiple emails by their IDs to the trash. They all
+// get the same deletion time.
+func (s *Store) DeleteEmails(ctx context.Context, emailIDs []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	for _, id := range emailIDs {
+		_, err := tx.ExecContext(ctx, trashEmailQuery, now, id)
+		if err != nil {
+			return fmt.Errorf("error deleting email %s: %v", id, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing deletes: %v", err)
+	}
+
+	logger.Info("Deleted emails", "count", len(emailIDs))
+	return nil
+}
+
+// DeleteEmailsBySender moves all emails from a specific sender to the trash
+func (s *Store) DeleteEmailsBySender(ctx context.Context, sender string) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE emails SET deleted_at = ? WHERE from_address LIKE ? AND deleted_at = 0`,
+		time.Now().UnixMilli(), "%"+sender+"%")
+	if err != nil {
+		return fmt.Errorf("error deleting emails: %v", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	logger.Info("Deleted emails from sender", "count", rowsAffected, "sender", sender)
+	return nil
+}
+
+// SaveLabels upserts the label definitions fetched from Gmail
+func (s *Store) SaveLabels(ctx context.Context, labels []models.Label) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, label := range labels {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO labels (id, name, type)
+		VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			type = excluded.type
+		`, label.ID, label.Name, label.Type)
+		if err != nil {
+			return fmt.Errorf("error saving label %s: %v", label.ID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error saving labels: %v", err)
+	}
+	return nil
+}
+
+// GetAllLabels gets every known label, system labels first
+func (s *Store) GetAllLabels(ctx context.Context) ([]models.Label, error) {
+	query := `
+	SELECT id, name, type
+	FROM labels
+	ORDER BY type = 'user', name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying labels: %v", err)
+	}
+	defer rows.Close()
+
+	var labels []models.Label
+	for rows.Next() {
+		var label models.Label
+		err := rows.Scan(&label.ID, &label.Name, &label.Type)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, rows.Err()
+}
+
+// GetEmailsByLabel gets all emails carrying a label with sorting
+func (s *Store) GetEmailsByLabel(ctx context.Context, labelID string, sortBy string) ([]models.Email, error) {
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE id IN (SELECT email_id FROM email_labels WHERE label_id = ?) AND deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
+
+	rows, err := s.db.QueryContext(ctx, query, labelID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// AddEmailLabel records that emails now carry a label
+func (s *Store) AddEmailLabel(ctx context.Context, emailIDs []string, labelID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range emailIDs {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO email_labels (email_id, label_id)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+		`, id, labelID)
+		if err != nil {
+			return fmt.Errorf("error labeling email %s: %v", id, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error labeling emails: %v", err)
+	}
+	return nil
+}
+
+// RemoveEmailLabel records that emails no longer carry a label
+func (s *Store) RemoveEmailLabel(ctx context.Context, emailIDs []string, labelID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range emailIDs {
+		_, err := tx.ExecContext(ctx, `DELETE FROM email_labels WHERE email_id = ? AND label_id = ?`, id, labelID)
+		if err != nil {
+			return fmt.Errorf("error removing label from email %s: %v", id, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error removing label: %v", err)
+	}
+	return nil
+}
+
+// SearchEmails runs an FTS5 search over sender, subject and body. query
+// accepts the same syntax as the Postgres backend: quoted phrases, OR and
+// -excluded words.
+func (s *Store) SearchEmails(ctx context.Context, query string, sortBy string) ([]models.Email, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	sql := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE rowid IN (SELECT rowid FROM emails_fts WHERE emails_fts MATCH ?) AND deleted_at = 0
+	%s
+	`, emailColumns, orderBy(sortBy))
+
+	rows, err := s.db.QueryContext(ctx, sql, match)
+	if err != nil {
+		return nil, fmt.Errorf("error searching emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// ftsQuery translates web search syntax into an FTS5 expression. Every
+// term is quoted so punctuation such as @ in addresses is not parsed as
+// FTS5 syntax. FTS5 has no unary NOT, so a query of only exclusions
+// matches nothing.
+func ftsQuery(query string) string {
+	var include, exclude []string
+	for _, term := range splitTerms(query) {
+		switch {
+		case term == "OR":
+			// A leading or repeated OR has nothing to join
+			if len(include) > 0 && include[len(include)-1] != "OR" {
+				include = append(include, "OR")
+			}
+		case strings.HasPrefix(term, "-") && len(term) > 1:
+			exclude = append(exclude, quoteTerm(term[1:]))
+		default:
+			include = append(include, quoteTerm(term))
+		}
+	}
+	if len(include) > 0 && include[len(include)-1] == "OR" {
+		include = include[:len(include)-1]
+	}
+	if len(include) == 0 {
+		return ""
+	}
+
+	// NOT binds tighter than OR, so the rest is grouped for the
+	// exclusions to apply to all of it
+	match := strings.Join(include, " ")
+	if len(exclude) > 0 {
+		match = "(" + match + ")"
+	}
+	for _, term := range exclude {
+		match += " NOT " + term
+	}
+	return match
+}
+
+// splitTerms splits on whitespace, keeping "quoted phrases" together
+func splitTerms(query string) []string {
+	var terms []string
+	var current strings.Builder
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms
+}
+
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"", ""},
+		{"invoice", `"invoice"`},
+		{"alice@example.com", `"alice@example.com"`},
+		{`"quarterly report" pdf`, `"quarterly report" "pdf"`},
+		{"a OR b", `"a" OR "b"`},
+		{"a OR OR b", `"a" OR "b"`},
+		{"OR a", `"a"`},
+		{"a OR", `"a"`},
+		{"a OR -x OR b", `("a" OR "b") NOT "x"`},
+		{"a -x -y", `("a") NOT "x" NOT "y"`},
+		{"-x", ""},
+		{"OR -x", ""},
+		{`say "hi"`, `"say" "hi"`},
+		{"-", `"-"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := ftsQuery(tt.query); got != tt.want {
+				t.Errorf("ftsQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFollowsChanges(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	saveTestEmails(t, s,
+		models.Email{ID: "m1", From: "alice@example.com", Subject: "Invoice for March", Body: "Amount due"},
+		models.Email{ID: "m2", From: "bob@example.com", Subject: "Lunch", Body: "Pizza or sushi?"},
+	)
+
+	search := func(query string) []string {
+		t.Helper()
+		emails, _, err := s.ListEmails(ctx, models.EmailFilter{Query: query}, "sender_asc", nil, 10)
+		if err != nil {
+			t.Fatalf("ListEmails(%q): %v", query, err)
+		}
+		var ids []string
+		for _, email := range emails {
+			ids = append(ids, email.ID)
+		}
+		return ids
+	}
+	check := func(query string, want ...string) {
+		t.Helper()
+		if got := search(query); !slices.Equal(got, want) {
+			t.Errorf("search %q = %q, want %q", query, got, want)
+		}
+	}
+
+	check("invoice", "m1")
+	check("alice@example.com", "m1")
+	check("invoice OR OR pizza", "m1", "m2")
+	check("invoice OR -alice OR pizza", "m2")
+	check("example -lunch", "m1")
+	check("-lunch")
+
+	// Saving an email again replaces what is indexed for it
+	saveTestEmails(t, s, models.Email{ID: "m1", From: "alice@example.com", Subject: "Receipt", Body: "Paid"})
+	check("invoice")
+	check("receipt", "m1")
+
+	// A purged email is dropped from the index
+	if err := s.DeleteEmails(ctx, []string{"m2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PurgeEmails(ctx, []string{"m2"}); err != nil {
+		t.Fatal(err)
+	}
+	var indexed int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM emails_fts WHERE emails_fts MATCH '"pizza"'`).Scan(&indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 0 {
+		t.Errorf("purged email is still indexed")
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+	saveTestEmails(t, s,
+		models.Email{ID: "m1", From: "alice@example.com", Subject: "One", Labels: []string{"INBOX"}},
+		models.Email{ID: "m2", From: "alice@example.com", Subject: "Two"},
+	)
+
+	ids := func(filter models.EmailFilter) []string {
+		t.Helper()
+		ids, err := s.ListEmailIDs(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListEmailIDs: %v", err)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	if err := s.DeleteEmails(ctx, []string{"m1"}); err != nil {
+		t.Fatalf("DeleteEmails: %v", err)
+	}
+	if got := ids(models.EmailFilter{}); !slices.Equal(got, []string{"m2"}) {
+		t.Errorf("outside the trash = %q, want [m2]", got)
+	}
+	if got := ids(models.EmailFilter{Trash: true}); !slices.Equal(got, []string{"m1"}) {
+		t.Errorf("in the trash = %q, want [m1]", got)
+	}
+	email, err := s.GetEmail(ctx, "m1")
+	if err != nil || !slices.Equal(email.Labels, []string{"INBOX"}) {
+		t.Errorf("GetEmail of a trashed email = %+v, %v; want it with its labels", email, err)
+	}
+
+	// Purging only removes emails in the trash
+	purged, err := s.PurgeEmails(ctx, []string{"m1", "m2"})
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeEmails = %d, %v; want 1", purged, err)
+	}
+	if _, err := s.GetEmail(ctx, "m1"); err != models.ErrNotFound {
+		t.Errorf("GetEmail of a purged email: %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteEmails(ctx, []string{"m2"}); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.RestoreEmails(ctx, []string{"m2", "missing"})
+	if err != nil || restored != 1 {
+		t.Fatalf("RestoreEmails = %d, %v; want 1", restored, err)
+	}
+	if got := ids(models.EmailFilter{}); !slices.Equal(got, []string{"m2"}) {
+		t.Errorf("after restoring = %q, want [m2]", got)
+	}
+
+	// The retention purge only takes what was trashed before the cutoff
+	if err := s.DeleteEmails(ctx, []string{"m2"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeTrashBefore(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("PurgeTrashBefore an hour ago = %d, %v; want 0", n, err)
+	}
+	if n, err := s.PurgeTrashBefore(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("PurgeTrashBefore now = %d, %v; want 1", n, err)
+	}
+}
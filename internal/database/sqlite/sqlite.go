@@ -0,0 +1,264 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	_ "modernc.org/sqlite"
+)
+
+var logger = logging.For("database")
+
+// Store implements store.Store on an embedded SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// PathFromURL extracts the file path from a sqlite:// URL. Both
+// sqlite:///abs/path.db and sqlite://relative/path.db are accepted, as is
+// sqlite://:memory: for a throwaway database.
+func PathFromURL(rawURL string) (string, error) {
+	path, ok := strings.CutPrefix(rawURL, "sqlite://")
+	if !ok {
+		return "", fmt.Errorf("not a sqlite:// URL: %s", rawURL)
+	}
+	path, _, _ = strings.Cut(path, "?")
+	if path == "" {
+		return "", fmt.Errorf("sqlite URL has no path: %s", rawURL)
+	}
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("unable to resolve ~ in %s: %v", rawURL, err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	return path, nil
+}
+
+// Open opens or creates the database file at path
+func Open(ctx context.Context, path string) (*Store, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create database directory: %v", err)
+		}
+	}
+
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %v", err)
+	}
+	if path == ":memory:" {
+		// Every connection to :memory: is a separate database
+		db.SetMaxOpenConns(1)
+	}
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to open database %s: %v", path, err)
+	}
+
+	logger.Info("Opened database", "path", path)
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() {
+	s.db.Close()
+}
+
+// CreateTables creates the schema if it doesn't exist
+func (s *Store) CreateTables(ctx context.Context) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS emails (
+		id TEXT PRIMARY KEY,
+		from_address TEXT NOT NULL,
+		subject TEXT,
+		body TEXT,
+		date_received TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_emails_from ON emails(from_address);
+	CREATE INDEX IF NOT EXISTS idx_emails_date ON emails(date_received);
+	CREATE INDEX IF NOT EXISTS idx_emails_date_id ON emails(date_received, id);
+	CREATE INDEX IF NOT EXISTS idx_emails_from_id ON emails(from_address, id);
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS emails_fts USING fts5(
+		from_address, subject, body,
+		content='emails', content_rowid='rowid'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS emails_fts_insert AFTER INSERT ON emails BEGIN
+		INSERT INTO emails_fts(rowid, from_address, subject, body)
+		VALUES (new.rowid, new.from_address, new.subject, new.body);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS emails_fts_delete AFTER DELETE ON emails BEGIN
+		INSERT INTO emails_fts(emails_fts, rowid, from_address, subject, body)
+		VALUES ('delete', old.rowid, old.from_address, old.subject, old.body);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS emails_fts_update AFTER UPDATE ON emails BEGIN
+		INSERT INTO emails_fts(emails_fts, rowid, from_address, subject, body)
+		VALUES ('delete', old.rowid, old.from_address, old.subject, old.body);
+		INSERT INTO emails_fts(rowid, from_address, subject, body)
+		VALUES (new.rowid, new.from_address, new.subject, new.body);
+	END;
+
+	CREATE TABLE IF NOT EXISTS labels (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		type TEXT NOT NULL DEFAULT 'user'
+	);
+
+	CREATE TABLE IF NOT EXISTS email_labels (
+		email_id TEXT NOT NULL REFERENCES emails(id) ON DELETE CASCADE,
+		label_id TEXT NOT NULL,
+		PRIMARY KEY (email_id, label_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_email_labels_label ON email_labels(label_id);
+
+	CREATE TABLE IF NOT EXISTS sync_state (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS sync_locks (
+		name TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		-- Unix milliseconds the lock lapses unless renewed
+		expires_at INTEGER NOT NULL
+	);
+
+	-- Times are Unix milliseconds; finished_at is 0 while a run is going
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		triggered_by TEXT NOT NULL,
+		mode TEXT NOT NULL DEFAULT '',
+		started_at INTEGER NOT NULL,
+		finished_at INTEGER NOT NULL DEFAULT 0,
+		fetched INTEGER NOT NULL DEFAULT 0,
+		saved INTEGER NOT NULL DEFAULT 0,
+		deleted INTEGER NOT NULL DEFAULT 0,
+		ruled INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	);
+
+	-- The outbox of changes to send to Gmail. Email and label IDs are one
+	-- per line; times are Unix milliseconds, 0 if unset.
+	CREATE TABLE IF NOT EXISTS pending_actions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email_ids TEXT NOT NULL,
+		add_labels TEXT NOT NULL DEFAULT '',
+		remove_labels TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT ''
+	);
+	`
+
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %v", err)
+	}
+
+	err = s.addMissingColumns(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Indexes on added columns can only be created once they exist
+	_, err = s.db.ExecContext(ctx, `
+	CREATE INDEX IF NOT EXISTS idx_emails_thread ON emails(thread_id, received_at);
+	CREATE INDEX IF NOT EXISTS idx_emails_deleted ON emails(deleted_at) WHERE deleted_at > 0;
+	CREATE INDEX IF NOT EXISTS idx_emails_domain ON emails(sender_domain, from_address);
+	CREATE INDEX IF NOT EXISTS idx_emails_size ON emails(size_estimate);
+	CREATE INDEX IF NOT EXISTS idx_emails_fingerprint ON emails(fingerprint);
+	CREATE INDEX IF NOT EXISTS idx_pending_actions_status ON pending_actions(status, id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating tables: %v", err)
+	}
+
+	err = s.backfillSenderDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.backfillFingerprints(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Tables created")
+	return nil
+}
+
+// addedColumns lists columns added to existing tables after they were
+// first created. SQLite has no ADD COLUMN IF NOT EXISTS.
+var addedColumns = []struct {
+	table, column, definition string
+}{
+	{"emails", "body_html", "TEXT NOT NULL DEFAULT ''"},
+	{"emails", "headers", "TEXT NOT NULL DEFAULT ''"},
+	{"emails", "thread_id", "TEXT NOT NULL DEFAULT ''"},
+	{"emails", "received_at", "INTEGER NOT NULL DEFAULT 0"},
+	// Unix milliseconds the email was moved to the trash, 0 if it is not
+	{"emails", "deleted_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"emails", "sender_domain", "TEXT NOT NULL DEFAULT ''"},
+	{"emails", "size_estimate", "INTEGER NOT NULL DEFAULT 0"},
+	// Attachment file names, one per line
+	{"emails", "attachments", "TEXT NOT NULL DEFAULT ''"},
+	{"emails", "fingerprint", "TEXT NOT NULL DEFAULT ''"},
+	{"pending_actions", "kind", "TEXT NOT NULL DEFAULT 'labels'"},
+	{"pending_actions", "url", "TEXT NOT NULL DEFAULT ''"},
+	{"pending_actions", "next_attempt_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"pending_actions", "sent_at", "INTEGER NOT NULL DEFAULT 0"},
+	// Until when a worker has claimed the action
+	{"pending_actions", "locked_until", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// columnBackfills fills in an added column for the rows that existed
+// before it, run once right after the column is added
+var columnBackfills = map[string]string{
+	// Rows saved before threads were tracked each start their own thread
+	"emails.thread_id": `UPDATE emails SET thread_id = id`,
+}
+
+func (s *Store) addMissingColumns(ctx context.Context) error {
+	for _, c := range addedColumns {
+		var exists bool
+		err := s.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("error inspecting table %s: %v", c.table, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = s.db.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, c.table, c.column, c.definition))
+		if err != nil {
+			return fmt.Errorf("error adding column %s.%s: %v", c.table, c.column, err)
+		}
+		if backfill, ok := columnBackfills[c.table+"."+c.column]; ok {
+			_, err = s.db.ExecContext(ctx, backfill)
+			if err != nil {
+				return fmt.Errorf("error filling in column %s.%s: %v", c.table, c.column, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// openTestStore returns an empty in-memory store with the current schema
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := openMemory(t)
+	if err := s.CreateTables(context.Background()); err != nil {
+		t.Fatalf("CreateTables: %v", err)
+	}
+	return s
+}
+
+func openMemory(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// saveTestEmails saves emails, failing the test if that does not work
+func saveTestEmails(t *testing.T, s *Store, emails ...models.Email) {
+	t.Helper()
+	if err := s.SaveEmails(context.Background(), emails); err != nil {
+		t.Fatalf("SaveEmails: %v", err)
+	}
+}
+
+func TestCreateTablesMigratesOldSchema(t *testing.T) {
+	ctx := context.Background()
+	s := openMemory(t)
+
+	// The first schema, before any column was added, with a row saved then
+	_, err := s.db.ExecContext(ctx, `
+	CREATE TABLE emails (
+		id TEXT PRIMARY KEY,
+		from_address TEXT NOT NULL,
+		subject TEXT,
+		body TEXT,
+		date_received TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE VIRTUAL TABLE emails_fts USING fts5(
+		from_address, subject, body,
+		content='emails', content_rowid='rowid'
+	);
+	CREATE TRIGGER emails_fts_insert AFTER INSERT ON emails BEGIN
+		INSERT INTO emails_fts(rowid, from_address, subject, body)
+		VALUES (new.rowid, new.from_address, new.subject, new.body);
+	END;
+	CREATE TRIGGER emails_fts_update AFTER UPDATE ON emails BEGIN
+		INSERT INTO emails_fts(emails_fts, rowid, from_address, subject, body)
+		VALUES ('delete', old.rowid, old.from_address, old.subject, old.body);
+		INSERT INTO emails_fts(rowid, from_address, subject, body)
+		VALUES (new.rowid, new.from_address, new.subject, new.body);
+	END;
+	CREATE TABLE pending_actions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email_ids TEXT NOT NULL,
+		add_labels TEXT NOT NULL DEFAULT '',
+		remove_labels TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT ''
+	);
+	INSERT INTO emails (id, from_address, subject, body, date_received)
+	VALUES ('m1', 'Alice <alice@mail.example.co.uk>', 'Hello', 'Hi there', '2025-01-02');
+	INSERT INTO pending_actions (email_ids, add_labels, created_at) VALUES ('m1', 'STARRED', 1);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Running it twice must be harmless
+	for range 2 {
+		if err := s.CreateTables(ctx); err != nil {
+			t.Fatalf("CreateTables: %v", err)
+		}
+	}
+
+	var threadID, domain, fingerprint string
+	err = s.db.QueryRowContext(ctx, `SELECT thread_id, sender_domain, fingerprint FROM emails WHERE id = 'm1'`).
+		Scan(&threadID, &domain, &fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threadID != "m1" {
+		t.Errorf("thread_id = %q, want the email's own ID", threadID)
+	}
+	if domain != "example.co.uk" {
+		t.Errorf("sender_domain = %q, want example.co.uk", domain)
+	}
+	if fingerprint == "" {
+		t.Error("fingerprint was not filled in")
+	}
+
+	actions, err := s.ListActions(ctx, "")
+	if err != nil {
+		t.Fatalf("ListActions: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Kind != models.ActionLabels {
+		t.Errorf("actions = %+v, want the old one as a label change", actions)
+	}
+
+	// The backfills kept the search index in step
+	emails, err := s.SearchEmails(ctx, "hello", "")
+	if err != nil {
+		t.Fatalf("SearchEmails: %v", err)
+	}
+	if len(emails) != 1 || emails[0].ID != "m1" {
+		t.Errorf("search found %d emails, want m1", len(emails))
+	}
+}
@@ -0,0 +1,185 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// SummarizeEmails counts the emails matching filter and adds up their size
+func (s *Store) SummarizeEmails(ctx context.Context, filter models.EmailFilter) (models.EmailSummary, error) {
+	conditions, args := filterClause(filter, nil, nil)
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(size_estimate), 0) FROM emails %s`, whereClause(conditions))
+
+	var summary models.EmailSummary
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(&summary.Count, &summary.Size)
+	if err != nil {
+		return summary, fmt.Errorf("error summarizing emails: %v", err)
+	}
+	return summary, nil
+}
+
+// TopSenders returns the limit senders with the most emails matching
+// filter, most first
+func (s *Store) TopSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error) {
+	return s.rankSenders(ctx, filter, "COUNT(*)", limit)
+}
+
+// LargestSenders returns the limit senders whose emails matching filter
+// take the most space, largest first
+func (s *Store) LargestSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error) {
+	return s.rankSenders(ctx, filter, "SUM(size_estimate)", limit)
+}
+
+func (s *Store) rankSenders(ctx context.Context, filter models.EmailFilter, rank string, limit int) ([]models.SenderCount, error) {
+	conditions, args := filterClause(filter, nil, nil)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+	SELECT from_address, COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	%s
+	GROUP BY from_address
+	ORDER BY %s DESC, from_address
+	LIMIT ?
+	`, whereClause(conditions), rank)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying senders: %v", err)
+	}
+	defer rows.Close()
+
+	var senders []models.SenderCount
+	for rows.Next() {
+		var sender models.SenderCount
+		err := rows.Scan(&sender.Address, &sender.Count, &sender.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sender: %v", err)
+		}
+		senders = append(senders, sender)
+	}
+
+	return senders, rows.Err()
+}
+
+// LargestEmails returns the limit largest emails matching filter,
+// largest first
+func (s *Store) LargestEmails(ctx context.Context, filter models.EmailFilter, limit int) ([]models.Email, error) {
+	conditions, args := filterClause(filter, nil, nil)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	%s
+	ORDER BY size_estimate DESC, id
+	LIMIT ?
+	`, emailColumns, whereClause(conditions))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying emails: %v", err)
+	}
+
+	return scanEmails(rows)
+}
+
+// SizeByLabel returns the count and size of the emails carrying each
+// label, largest first. An email with several labels counts for each.
+func (s *Store) SizeByLabel(ctx context.Context) ([]models.SizeGroup, error) {
+	return s.sizeGroups(ctx, `
+	SELECT COALESCE(labels.name, email_labels.label_id), COUNT(*), COALESCE(SUM(emails.size_estimate), 0)
+	FROM emails
+	JOIN email_labels ON email_labels.email_id = emails.id
+	LEFT JOIN labels ON labels.id = email_labels.label_id
+	WHERE emails.deleted_at = 0
+	GROUP BY 1
+	ORDER BY 3 DESC, 1
+	`)
+}
+
+// SizeByYear returns the count and size of the emails received in each
+// year, newest first. Emails with an unknown receive time are grouped as
+// "unknown".
+func (s *Store) SizeByYear(ctx context.Context) ([]models.SizeGroup, error) {
+	return s.sizeGroups(ctx, `
+	SELECT CASE WHEN received_at = 0 THEN 'unknown'
+		ELSE strftime('%Y', received_at / 1000, 'unixepoch') END,
+		COUNT(*), COALESCE(SUM(size_estimate), 0)
+	FROM emails
+	WHERE deleted_at = 0
+	GROUP BY 1
+	ORDER BY 1 DESC
+	`)
+}
+
+func (s *Store) sizeGroups(ctx context.Context, query string) ([]models.SizeGroup, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sizes: %v", err)
+	}
+	defer rows.Close()
+
+	var groups []models.SizeGroup
+	for rows.Next() {
+		var group models.SizeGroup
+		err := rows.Scan(&group.Name, &group.Count, &group.Size)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sizes: %v", err)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// ListUnsizedEmails returns the IDs of up to limit emails outside the
+// trash with no size recorded, in ID order after the ID after
+func (s *Store) ListUnsizedEmails(ctx context.Context, after string, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT id FROM emails
+	WHERE size_estimate = 0 AND deleted_at = 0 AND id > ?
+	ORDER BY id
+	LIMIT ?
+	`, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unsized emails: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning email ID: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// SaveSizes records the size estimate and attachment names of emails
+func (s *Store) SaveSizes(ctx context.Context, emails []models.Email) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, email := range emails {
+		_, err := tx.ExecContext(ctx, `UPDATE emails SET size_estimate = ?, attachments = ? WHERE id = ?`,
+			email.SizeEstimate, strings.Join(email.Attachments, "\n"), email.ID)
+		if err != nil {
+			return fmt.Errorf("error saving size of email %s: %v", email.ID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error saving sizes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// syncLockName is the sync_locks row every sync competes for
+const syncLockName = "sync"
+
+// interruptedRunError is recorded for runs whose process died
+const interruptedRunError = "interrupted"
+
+// TryLockSync takes the sync lock for owner until ttl from now, or renews
+// it if owner already holds it. It reports false if another owner holds a
+// lock that has not lapsed.
+func (s *Store) TryLockSync(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	query := `
+	INSERT INTO sync_locks (name, owner, expires_at)
+	VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET
+		owner = excluded.owner,
+		expires_at = excluded.expires_at
+	WHERE sync_locks.owner = excluded.owner OR sync_locks.expires_at < ?
+	`
+
+	now := time.Now()
+	result, err := s.db.ExecContext(ctx, query, syncLockName, owner, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error taking sync lock: %v", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error taking sync lock: %v", err)
+	}
+	return n == 1, nil
+}
+
+// UnlockSync releases the sync lock if owner holds it
+func (s *Store) UnlockSync(ctx context.Context, owner string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sync_locks WHERE name = ? AND owner = ?`, syncLockName, owner)
+	if err != nil {
+		return fmt.Errorf("error releasing sync lock: %v", err)
+	}
+	return nil
+}
+
+// StartSyncRun records a new run and sets its ID. The caller holds the
+// sync lock, so any run still marked as going was left by a process that
+// died and is marked interrupted.
+func (s *Store) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	now := time.Now().UnixMilli()
+	_, err := s.db.ExecContext(ctx, `UPDATE sync_runs SET finished_at = ?, error = ? WHERE finished_at = 0`, now, interruptedRunError)
+	if err != nil {
+		return fmt.Errorf("error closing interrupted sync runs: %v", err)
+	}
+
+	query := `
+	INSERT INTO sync_runs (triggered_by, mode, started_at)
+	VALUES (?, ?, ?)
+	`
+	result, err := s.db.ExecContext(ctx, query, run.Trigger, run.Mode, unixMilli(run.StartedAt))
+	if err != nil {
+		return fmt.Errorf("error recording sync run: %v", err)
+	}
+	run.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error recording sync run: %v", err)
+	}
+	return nil
+}
+
+// FinishSyncRun records how a run ended
+func (s *Store) FinishSyncRun(ctx context.Context, run models.SyncRun) error {
+	query := `
+	UPDATE sync_runs SET
+		mode = ?, finished_at = ?, fetched = ?, saved = ?, deleted = ?, ruled = ?, error = ?
+	WHERE id = ?
+	`
+
+	_, err := s.db.ExecContext(ctx, query, run.Mode, unixMilli(run.FinishedAt),
+		run.Fetched, run.Saved, run.Deleted, run.Ruled, run.Error, run.ID)
+	if err != nil {
+		return fmt.Errorf("error recording end of sync run: %v", err)
+	}
+	return nil
+}
+
+// ListSyncRuns returns the limit most recent runs, newest first
+func (s *Store) ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	query := `
+	SELECT id, triggered_by, mode, started_at, finished_at, fetched, saved, deleted, ruled, error
+	FROM sync_runs
+	ORDER BY id DESC
+	LIMIT ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sync runs: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []models.SyncRun
+	for rows.Next() {
+		var run models.SyncRun
+		var startedAt, finishedAt int64
+		err := rows.Scan(&run.ID, &run.Trigger, &run.Mode, &startedAt, &finishedAt,
+			&run.Fetched, &run.Saved, &run.Deleted, &run.Ruled, &run.Error)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sync run: %v", err)
+		}
+		run.StartedAt = fromUnixMilli(startedAt)
+		run.FinishedAt = fromUnixMilli(finishedAt)
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading sync runs: %v", err)
+	}
+
+	return runs, nil
+}
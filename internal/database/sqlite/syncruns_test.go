@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTryLockSync(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	lock := func(owner string, ttl time.Duration) bool {
+		t.Helper()
+		locked, err := s.TryLockSync(ctx, owner, ttl)
+		if err != nil {
+			t.Fatalf("TryLockSync(%s): %v", owner, err)
+		}
+		return locked
+	}
+
+	if !lock("a", time.Minute) {
+		t.Fatal("a could not take a free lock")
+	}
+	if !lock("a", time.Minute) {
+		t.Error("a could not renew its lock")
+	}
+	if lock("b", time.Minute) {
+		t.Error("b took a lock a holds")
+	}
+
+	// Only the holder releases it
+	if err := s.UnlockSync(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if lock("b", time.Minute) {
+		t.Error("b took the lock after releasing one it did not hold")
+	}
+	if err := s.UnlockSync(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if !lock("b", -time.Second) {
+		t.Fatal("b could not take a released lock")
+	}
+
+	// b's lock has lapsed, as if its process died
+	if !lock("a", time.Minute) {
+		t.Error("a could not take over a lapsed lock")
+	}
+}
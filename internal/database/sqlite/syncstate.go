@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// sync_state keys, shared with the Postgres backend
+const (
+	keyCheckpointPageToken = "checkpoint_page_token"
+	keyCheckpointMessageID = "checkpoint_message_id"
+	keyHistoryID           = "history_id"
+)
+
+const setSyncStateQuery = `
+	INSERT INTO sync_state (key, value, updated_at)
+	VALUES (?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT (key) DO UPDATE SET
+		value = excluded.value,
+		updated_at = excluded.updated_at
+	`
+
+// SaveEmailBatch saves emails and the sync checkpoint in one transaction,
+// so the checkpoint never points past an email that was not committed
+func (s *Store) SaveEmailBatch(ctx context.Context, emails []models.Email, checkpoint models.SyncCheckpoint) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveEmails(ctx, tx, emails); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, setSyncStateQuery, keyCheckpointPageToken, checkpoint.PageToken)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, setSyncStateQuery, keyCheckpointMessageID, checkpoint.LastMessageID)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving sync checkpoint: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing email batch: %v", err)
+	}
+	return nil
+}
+
+// GetSyncCheckpoint returns where the last interrupted sync stopped.
+// The zero checkpoint means the next sync starts from the beginning.
+func (s *Store) GetSyncCheckpoint(ctx context.Context) (models.SyncCheckpoint, error) {
+	query := `
+	SELECT key, value
+	FROM sync_state
+	WHERE key IN (?, ?)
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, keyCheckpointPageToken, keyCheckpointMessageID)
+	if err != nil {
+		return models.SyncCheckpoint{}, fmt.Errorf("error querying sync checkpoint: %v", err)
+	}
+	defer rows.Close()
+
+	var checkpoint models.SyncCheckpoint
+	for rows.Next() {
+		var key, value string
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return models.SyncCheckpoint{}, fmt.Errorf("error scanning sync checkpoint: %v", err)
+		}
+		switch key {
+		case keyCheckpointPageToken:
+			checkpoint.PageToken = value
+		case keyCheckpointMessageID:
+			checkpoint.LastMessageID = value
+		}
+	}
+
+	return checkpoint, rows.Err()
+}
+
+// ClearSyncCheckpoint forgets the checkpoint once a sync has completed
+func (s *Store) ClearSyncCheckpoint(ctx context.Context) error {
+	query := `DELETE FROM sync_state WHERE key IN (?, ?)`
+
+	_, err := s.db.ExecContext(ctx, query, keyCheckpointPageToken, keyCheckpointMessageID)
+	if err != nil {
+		return fmt.Errorf("error clearing sync checkpoint: %v", err)
+	}
+	return nil
+}
+
+// GetHistoryID returns the Gmail history ID the last sync reached, 0 if no
+// sync has completed
+func (s *Store) GetHistoryID(ctx context.Context) (uint64, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM sync_state WHERE key = ?`, keyHistoryID).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying history ID: %v", err)
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing history ID %q: %v", value, err)
+	}
+	return id, nil
+}
+
+// SaveHistoryID records the Gmail history ID a sync reached
+func (s *Store) SaveHistoryID(ctx context.Context, historyID uint64) error {
+	_, err := s.db.ExecContext(ctx, setSyncStateQuery, keyHistoryID, strconv.FormatUint(historyID, 10))
+	if err != nil {
+		return fmt.Errorf("error saving history ID: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RestoreEmails takes emails back out of the trash and returns how many
+// were in it
+func (s *Store) RestoreEmails(ctx context.Context, emailIDs []string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var restored int64
+	for _, id := range emailIDs {
+		result, err := tx.ExecContext(ctx, `UPDATE emails SET deleted_at = 0 WHERE id = ? AND deleted_at > 0`, id)
+		if err != nil {
+			return 0, fmt.Errorf("error restoring email %s: %v", id, err)
+		}
+		n, _ := result.RowsAffected()
+		restored += n
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error committing restore: %v", err)
+	}
+
+	logger.Info("Restored emails", "count", restored)
+	return restored, nil
+}
+
+// PurgeEmails permanently deletes emails that are in the trash and
+// returns how many there were. Emails that are not in the trash are left
+// alone.
+func (s *Store) PurgeEmails(ctx context.Context, emailIDs []string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, id := range emailIDs {
+		result, err := tx.ExecContext(ctx, `DELETE FROM emails WHERE id = ? AND deleted_at > 0`, id)
+		if err != nil {
+			return 0, fmt.Errorf("error purging email %s: %v", id, err)
+		}
+		n, _ := result.RowsAffected()
+		purged += n
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("error committing purge: %v", err)
+	}
+
+	logger.Info("Purged emails", "count", purged)
+	return purged, nil
+}
+
+// PurgeTrashBefore permanently deletes emails moved to the trash before
+// the given time and returns how many there were
+func (s *Store) PurgeTrashBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM emails WHERE deleted_at > 0 AND deleted_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("error purging trash: %v", err)
+	}
+	return result.RowsAffected()
+}
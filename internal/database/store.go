@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore implements store.Store with the query functions of this
+// package
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+// Pool returns the underlying connection pool
+func (s *PostgresStore) Pool() *pgxpool.Pool {
+	return s.pool
+}
+
+func (s *PostgresStore) Close() {
+	s.pool.Close()
+}
+
+func (s *PostgresStore) SaveEmails(ctx context.Context, emails []models.Email) error {
+	return SaveEmails(ctx, s.pool, emails)
+}
+
+func (s *PostgresStore) GetAllEmails(ctx context.Context, sortBy string) ([]models.Email, error) {
+	return GetAllEmails(ctx, s.pool, sortBy)
+}
+
+func (s *PostgresStore) GetEmail(ctx context.Context, emailID string) (models.Email, error) {
+	return GetEmail(ctx, s.pool, emailID)
+}
+
+func (s *PostgresStore) GetEmailsBySender(ctx context.Context, sender string, sortBy string) ([]models.Email, error) {
+	return GetEmailsBySender(ctx, s.pool, sender, sortBy)
+}
+
+func (s *PostgresStore) ListEmails(ctx context.Context, filter models.EmailFilter, sortBy string, after *models.EmailCursor, limit int) ([]models.Email, *models.EmailCursor, error) {
+	return ListEmails(ctx, s.pool, filter, sortBy, after, limit)
+}
+
+func (s *PostgresStore) ListEmailIDs(ctx context.Context, filter models.EmailFilter) ([]string, error) {
+	return ListEmailIDs(ctx, s.pool, filter)
+}
+
+func (s *PostgresStore) DeleteEmail(ctx context.Context, emailID string) error {
+	return DeleteEmail(ctx, s.pool, emailID)
+}
+
+func (s *PostgresStore) DeleteEmails(ctx context.Context, emailIDs []string) error {
+	return DeleteEmails(ctx, s.pool, emailIDs)
+}
+
+func (s *PostgresStore) DeleteEmailsBySender(ctx context.Context, sender string) error {
+	return DeleteEmailsBySender(ctx, s.pool, sender)
+}
+
+func (s *PostgresStore) GetAllSenders(ctx context.Context) ([]string, error) {
+	return GetAllSenders(ctx, s.pool)
+}
+
+func (s *PostgresStore) ListSenders(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.SenderCount, error) {
+	return ListSenders(ctx, s.pool, filter, descending, after, limit)
+}
+
+func (s *PostgresStore) ListDomains(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.DomainCount, error) {
+	return ListDomains(ctx, s.pool, filter, descending, after, limit)
+}
+
+func (s *PostgresStore) SaveLabels(ctx context.Context, labels []models.Label) error {
+	return SaveLabels(ctx, s.pool, labels)
+}
+
+func (s *PostgresStore) GetAllLabels(ctx context.Context) ([]models.Label, error) {
+	return GetAllLabels(ctx, s.pool)
+}
+
+func (s *PostgresStore) GetEmailsByLabel(ctx context.Context, labelID string, sortBy string) ([]models.Email, error) {
+	return GetEmailsByLabel(ctx, s.pool, labelID, sortBy)
+}
+
+func (s *PostgresStore) AddEmailLabel(ctx context.Context, emailIDs []string, labelID string) error {
+	return AddEmailLabel(ctx, s.pool, emailIDs, labelID)
+}
+
+func (s *PostgresStore) RemoveEmailLabel(ctx context.Context, emailIDs []string, labelID string) error {
+	return RemoveEmailLabel(ctx, s.pool, emailIDs, labelID)
+}
+
+func (s *PostgresStore) SummarizeEmails(ctx context.Context, filter models.EmailFilter) (models.EmailSummary, error) {
+	return SummarizeEmails(ctx, s.pool, filter)
+}
+
+func (s *PostgresStore) TopSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error) {
+	return TopSenders(ctx, s.pool, filter, limit)
+}
+
+func (s *PostgresStore) LargestSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error) {
+	return LargestSenders(ctx, s.pool, filter, limit)
+}
+
+func (s *PostgresStore) LargestEmails(ctx context.Context, filter models.EmailFilter, limit int) ([]models.Email, error) {
+	return LargestEmails(ctx, s.pool, filter, limit)
+}
+
+func (s *PostgresStore) SizeByLabel(ctx context.Context) ([]models.SizeGroup, error) {
+	return SizeByLabel(ctx, s.pool)
+}
+
+func (s *PostgresStore) SizeByYear(ctx context.Context) ([]models.SizeGroup, error) {
+	return SizeByYear(ctx, s.pool)
+}
+
+func (s *PostgresStore) ListUnsizedEmails(ctx context.Context, after string, limit int) ([]string, error) {
+	return ListUnsizedEmails(ctx, s.pool, after, limit)
+}
+
+func (s *PostgresStore) SaveSizes(ctx context.Context, emails []models.Email) error {
+	return SaveSizes(ctx, s.pool, emails)
+}
+
+func (s *PostgresStore) ListDuplicateClusters(ctx context.Context, limit int) ([]models.DuplicateCluster, error) {
+	return ListDuplicateClusters(ctx, s.pool, limit)
+}
+
+func (s *PostgresStore) GetDuplicates(ctx context.Context, fingerprint string) ([]models.Email, error) {
+	return GetDuplicates(ctx, s.pool, fingerprint)
+}
+
+func (s *PostgresStore) SaveEmailBatch(ctx context.Context, emails []models.Email, checkpoint models.SyncCheckpoint) error {
+	return SaveEmailBatch(ctx, s.pool, emails, checkpoint)
+}
+
+func (s *PostgresStore) GetSyncCheckpoint(ctx context.Context) (models.SyncCheckpoint, error) {
+	return GetSyncCheckpoint(ctx, s.pool)
+}
+
+func (s *PostgresStore) ClearSyncCheckpoint(ctx context.Context) error {
+	return ClearSyncCheckpoint(ctx, s.pool)
+}
+
+func (s *PostgresStore) SearchEmails(ctx context.Context, query string, sortBy string) ([]models.Email, error) {
+	return SearchEmails(ctx, s.pool, query, sortBy)
+}
+
+func (s *PostgresStore) ListThreads(ctx context.Context, filter models.EmailFilter, after *models.ThreadCursor, limit int) ([]models.Thread, *models.ThreadCursor, error) {
+	return ListThreads(ctx, s.pool, filter, after, limit)
+}
+
+func (s *PostgresStore) GetThread(ctx context.Context, threadID string, trash bool) ([]models.Email, error) {
+	return GetThread(ctx, s.pool, threadID, trash)
+}
+
+func (s *PostgresStore) RestoreEmails(ctx context.Context, emailIDs []string) (int64, error) {
+	return RestoreEmails(ctx, s.pool, emailIDs)
+}
+
+func (s *PostgresStore) PurgeEmails(ctx context.Context, emailIDs []string) (int64, error) {
+	return PurgeEmails(ctx, s.pool, emailIDs)
+}
+
+func (s *PostgresStore) PurgeTrashBefore(ctx context.Context, before time.Time) (int64, error) {
+	return PurgeTrashBefore(ctx, s.pool, before)
+}
+
+func (s *PostgresStore) GetHistoryID(ctx context.Context) (uint64, error) {
+	return GetHistoryID(ctx, s.pool)
+}
+
+func (s *PostgresStore) SaveHistoryID(ctx context.Context, historyID uint64) error {
+	return SaveHistoryID(ctx, s.pool, historyID)
+}
+
+func (s *PostgresStore) TryLockSync(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	return TryLockSync(ctx, s.pool, owner, ttl)
+}
+
+func (s *PostgresStore) UnlockSync(ctx context.Context, owner string) error {
+	return UnlockSync(ctx, s.pool, owner)
+}
+
+func (s *PostgresStore) StartSyncRun(ctx context.Context, run *models.SyncRun) error {
+	return StartSyncRun(ctx, s.pool, run)
+}
+
+func (s *PostgresStore) FinishSyncRun(ctx context.Context, run models.SyncRun) error {
+	return FinishSyncRun(ctx, s.pool, run)
+}
+
+func (s *PostgresStore) ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error) {
+	return ListSyncRuns(ctx, s.pool, limit)
+}
+
+func (s *PostgresStore) QueueAction(ctx context.Context, action *models.PendingAction) error {
+	return QueueAction(ctx, s.pool, action)
+}
+
+func (s *PostgresStore) ListActions(ctx context.Context, status string) ([]models.PendingAction, error) {
+	return ListActions(ctx, s.pool, status)
+}
+
+func (s *PostgresStore) ClaimAction(ctx context.Context, id int64, ttl time.Duration) (bool, error) {
+	return ClaimAction(ctx, s.pool, id, ttl)
+}
+
+func (s *PostgresStore) RecordActionFailure(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	return RecordActionFailure(ctx, s.pool, id, reason, retryAt)
+}
+
+func (s *PostgresStore) MarkActionSent(ctx context.Context, id int64, sentAt time.Time) error {
+	return MarkActionSent(ctx, s.pool, id, sentAt)
+}
+
+func (s *PostgresStore) SetActionStatus(ctx context.Context, id int64, status, reason string) error {
+	return SetActionStatus(ctx, s.pool, id, status, reason)
+}
+
+func (s *PostgresStore) RetryAction(ctx context.Context, id int64) error {
+	return RetryAction(ctx, s.pool, id)
+}
+
+func (s *PostgresStore) DeleteAction(ctx context.Context, id int64) error {
+	return DeleteAction(ctx, s.pool, id)
+}
+
+func (s *PostgresStore) CountActions(ctx context.Context) (models.ActionCounts, error) {
+	return CountActions(ctx, s.pool)
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return Ping(ctx, s.pool)
+}
+
+func (s *PostgresStore) CountRows(ctx context.Context) (map[string]int64, error) {
+	return CountRows(ctx, s.pool)
+}
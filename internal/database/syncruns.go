@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// syncLockName is the sync_locks row every sync competes for
+const syncLockName = "sync"
+
+// interruptedRunError is recorded for runs whose process died
+const interruptedRunError = "interrupted"
+
+// TryLockSync takes the sync lock for owner until ttl from now, or renews
+// it if owner already holds it. It reports false if another owner holds a
+// lock that has not lapsed.
+func TryLockSync(ctx context.Context, pool *pgxpool.Pool, owner string, ttl time.Duration) (bool, error) {
+	query := `
+	INSERT INTO sync_locks (name, owner, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO UPDATE SET
+		owner = EXCLUDED.owner,
+		expires_at = EXCLUDED.expires_at
+	WHERE sync_locks.owner = EXCLUDED.owner OR sync_locks.expires_at < $4
+	`
+
+	now := time.Now()
+	result, err := pool.Exec(ctx, query, syncLockName, owner, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, fmt.Errorf("error taking sync lock: %v", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// UnlockSync releases the sync lock if owner holds it
+func UnlockSync(ctx context.Context, pool *pgxpool.Pool, owner string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM sync_locks WHERE name = $1 AND owner = $2`, syncLockName, owner)
+	if err != nil {
+		return fmt.Errorf("error releasing sync lock: %v", err)
+	}
+	return nil
+}
+
+// StartSyncRun records a new run and sets its ID. The caller holds the
+// sync lock, so any run still marked as going was left by a process that
+// died and is marked interrupted.
+func StartSyncRun(ctx context.Context, pool *pgxpool.Pool, run *models.SyncRun) error {
+	now := time.Now().UnixMilli()
+	_, err := pool.Exec(ctx, `UPDATE sync_runs SET finished_at = $1, error = $2 WHERE finished_at = 0`, now, interruptedRunError)
+	if err != nil {
+		return fmt.Errorf("error closing interrupted sync runs: %v", err)
+	}
+
+	query := `
+	INSERT INTO sync_runs (triggered_by, mode, started_at)
+	VALUES ($1, $2, $3)
+	RETURNING id
+	`
+	err = pool.QueryRow(ctx, query, run.Trigger, run.Mode, unixMilli(run.StartedAt)).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("error recording sync run: %v", err)
+	}
+	return nil
+}
+
+// FinishSyncRun records how a run ended
+func FinishSyncRun(ctx context.Context, pool *pgxpool.Pool, run models.SyncRun) error {
+	query := `
+	UPDATE sync_runs SET
+		mode = $2, finished_at = $3, fetched = $4, saved = $5, deleted = $6, ruled = $7, error = $8
+	WHERE id = $1
+	`
+
+	_, err := pool.Exec(ctx, query, run.ID, run.Mode, unixMilli(run.FinishedAt),
+		run.Fetched, run.Saved, run.Deleted, run.Ruled, run.Error)
+	if err != nil {
+		return fmt.Errorf("error recording end of sync run: %v", err)
+	}
+	return nil
+}
+
+// ListSyncRuns returns the limit most recent runs, newest first
+func ListSyncRuns(ctx context.Context, pool *pgxpool.Pool, limit int) ([]models.SyncRun, error) {
+	query := `
+	SELECT id, triggered_by, mode, started_at, finished_at, fetched, saved, deleted, ruled, error
+	FROM sync_runs
+	ORDER BY id DESC
+	LIMIT $1
+	`
+
+	rows, err := pool.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying sync runs: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []models.SyncRun
+	for rows.Next() {
+		var run models.SyncRun
+		var startedAt, finishedAt int64
+		err := rows.Scan(&run.ID, &run.Trigger, &run.Mode, &startedAt, &finishedAt,
+			&run.Fetched, &run.Saved, &run.Deleted, &run.Ruled, &run.Error)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning sync run: %v", err)
+		}
+		run.StartedAt = fromUnixMilli(startedAt)
+		run.FinishedAt = fromUnixMilli(finishedAt)
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading sync runs: %v", err)
+	}
+
+	return runs, nil
+}
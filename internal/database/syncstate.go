@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sync_state keys
+const (
+	keyCheckpointPageToken = "checkpoint_page_token"
+	keyCheckpointMessageID = "checkpoint_message_id"
+	keyHistoryID           = "history_id"
+)
+
+const setSyncStateQuery = `
+	INSERT INTO sync_state (key, value, updated_at)
+	VALUES ($1, $2, CURRENT_TIMESTAMP)
+	ON CONFLICT (key) DO UPDATE SET
+		value = EXCLUDED.value,
+		updated_at = EXCLUDED.updated_at
+	`
+
+// SaveEmailBatch saves emails and the sync checkpoint in one transaction,
+// so the checkpoint never points past an email that was not committed
+func SaveEmailBatch(ctx context.Context, pool *pgxpool.Pool, emails []models.Email, checkpoint models.SyncCheckpoint) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, email := range emails {
+		queueEmail(batch, email)
+	}
+	batch.Queue(setSyncStateQuery, keyCheckpointPageToken, checkpoint.PageToken)
+	batch.Queue(setSyncStateQuery, keyCheckpointMessageID, checkpoint.LastMessageID)
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("error saving email batch: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("error committing email batch: %v", err)
+	}
+	return nil
+}
+
+// GetSyncCheckpoint returns where the last interrupted sync stopped.
+// The zero checkpoint means the next sync starts from the beginning.
+func GetSyncCheckpoint(ctx context.Context, pool *pgxpool.Pool) (models.SyncCheckpoint, error) {
+	query := `
+	SELECT key, value
+	FROM sync_state
+	WHERE key IN ($1, $2)
+	`
+
+	rows, err := pool.Query(ctx, query, keyCheckpointPageToken, keyCheckpointMessageID)
+	if err != nil {
+		return models.SyncCheckpoint{}, fmt.Errorf("error querying sync checkpoint: %v", err)
+	}
+	defer rows.Close()
+
+	var checkpoint models.SyncCheckpoint
+	for rows.Next() {
+		var key, value string
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return models.SyncCheckpoint{}, fmt.Errorf("error scanning sync checkpoint: %v", err)
+		}
+		switch key {
+		case keyCheckpointPageToken:
+			checkpoint.PageToken = value
+		case keyCheckpointMessageID:
+			checkpoint.LastMessageID = value
+		}
+	}
+
+	return checkpoint, rows.Err()
+}
+
+// ClearSyncCheckpoint forgets the checkpoint once a sync has completed
+func ClearSyncCheckpoint(ctx context.Context, pool *pgxpool.Pool) error {
+	query := `DELETE FROM sync_state WHERE key IN ($1, $2)`
+
+	_, err := pool.Exec(ctx, query, keyCheckpointPageToken, keyCheckpointMessageID)
+	if err != nil {
+		return fmt.Errorf("error clearing sync checkpoint: %v", err)
+	}
+	return nil
+}
+
+// GetHistoryID returns the Gmail history ID the last sync reached, 0 if no
+// sync has completed
+func GetHistoryID(ctx context.Context, pool *pgxpool.Pool) (uint64, error) {
+	var value string
+	err := pool.QueryRow(ctx, `SELECT value FROM sync_state WHERE key = $1`, keyHistoryID).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying history ID: %v", err)
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing history ID %q: %v", value, err)
+	}
+	return id, nil
+}
+
+// SaveHistoryID records the Gmail history ID a sync reached
+func SaveHistoryID(ctx context.Context, pool *pgxpool.Pool, historyID uint64) error {
+	_, err := pool.Exec(ctx, setSyncStateQuery, keyHistoryID, strconv.FormatUint(historyID, 10))
+	if err != nil {
+		return fmt.Errorf("error saving history ID: %v", err)
+	}
+	return nil
+}
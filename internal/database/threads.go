@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// threadColumns is the select list of a thread, grouped by thread_id
+const threadColumns = `
+	thread_id,
+	COALESCE((SELECT subject FROM emails e WHERE e.thread_id = emails.thread_id
+		ORDER BY received_at, id LIMIT 1), ''),
+	array_agg(DISTINCT from_address),
+	COUNT(*),
+	MAX(received_at)
+	`
+
+// ListThreads returns one page of threads containing emails that match
+// filter, most recently active first. Pass the cursor returned by the
+// previous call as after, nil for the first page. The returned cursor is
+// nil when there are no more pages.
+func ListThreads(ctx context.Context, pool *pgxpool.Pool, filter models.EmailFilter, after *models.ThreadCursor, limit int) ([]models.Thread, *models.ThreadCursor, error) {
+	// The filter picks the threads, which are then summarized whole
+	// rather than from their matching emails only
+	matching, args := filterClause(filter, nil, nil)
+	conditions, _ := filterClause(models.EmailFilter{Trash: filter.Trash}, nil, nil)
+	conditions = append(conditions, fmt.Sprintf("thread_id IN (SELECT thread_id FROM emails %s)", whereClause(matching)))
+	having := ""
+	if after != nil {
+		args = append(args, unixMilli(after.LastActivity), after.ID)
+		having = fmt.Sprintf("HAVING (MAX(received_at), thread_id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	%s
+	GROUP BY thread_id
+	%s
+	ORDER BY MAX(received_at) DESC, thread_id DESC
+	LIMIT $%d
+	`, threadColumns, whereClause(conditions), having, len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying threads: %v", err)
+	}
+	defer rows.Close()
+
+	var threads []models.Thread
+	for rows.Next() {
+		var thread models.Thread
+		var lastActivity int64
+		err := rows.Scan(&thread.ID, &thread.Subject, &thread.Participants, &thread.Count, &lastActivity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error scanning thread: %v", err)
+		}
+		thread.LastActivity = fromUnixMilli(lastActivity)
+		threads = append(threads, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading threads: %v", err)
+	}
+
+	if len(threads) < limit {
+		return threads, nil, nil
+	}
+	last := threads[len(threads)-1]
+	return threads, &models.ThreadCursor{LastActivity: last.LastActivity, ID: last.ID}, nil
+}
+
+// GetThread returns the emails of a thread, oldest first. With trash set
+// it returns the emails of the thread that are in the trash instead.
+func GetThread(ctx context.Context, pool *pgxpool.Pool, threadID string, trash bool) ([]models.Email, error) {
+	conditions, args := filterClause(models.EmailFilter{Trash: trash}, nil, []any{threadID})
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM emails
+	WHERE thread_id = $1 AND %s
+	ORDER BY received_at, id
+	`, emailColumns, strings.Join(conditions, " AND "))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying thread: %v", err)
+	}
+
+	return scanEmails(rows)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("database")
+
+// queryTracer gives every query and batch sent through the pool a span
+type queryTracer struct{}
+
+var (
+	_ pgx.QueryTracer = queryTracer{}
+	_ pgx.BatchTracer = queryTracer{}
+)
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	sql := compactSQL(data.SQL)
+	ctx, _ = tracer.Start(ctx, "db "+operation(sql), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.query.text", sql),
+	))
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.response.affected_rows", data.CommandTag.RowsAffected()))
+	}
+	tracing.End(span, data.Err)
+}
+
+func (queryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	ctx, _ = tracer.Start(ctx, "db batch", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system.name", "postgresql"),
+		attribute.Int("db.operation.batch.size", data.Batch.Len()),
+	))
+	return ctx
+}
+
+// TraceBatchQuery notes failed statements on the batch span, which
+// covers the whole round trip
+func (queryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if data.Err == nil {
+		return
+	}
+	trace.SpanFromContext(ctx).AddEvent("query failed", trace.WithAttributes(
+		attribute.String("db.query.text", compactSQL(data.SQL)),
+		attribute.String("error.message", data.Err.Error()),
+	))
+}
+
+func (queryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	tracing.End(trace.SpanFromContext(ctx), data.Err)
+}
+
+// compactSQL puts a query on one line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+// operation is the first keyword of a query, such as SELECT
+func operation(sql string) string {
+	op, _, _ := strings.Cut(sql, " ")
+	return strings.ToUpper(op)
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RestoreEmails takes emails back out of the trash and returns how many
+// were in it
+func RestoreEmails(ctx context.Context, pool *pgxpool.Pool, emailIDs []string) (int64, error) {
+	result, err := pool.Exec(ctx, `UPDATE emails SET deleted_at = 0 WHERE id = ANY($1) AND deleted_at > 0`, emailIDs)
+	if err != nil {
+		return 0, fmt.Errorf("error restoring emails: %v", err)
+	}
+
+	logger.Info("Restored emails", "count", result.RowsAffected())
+	return result.RowsAffected(), nil
+}
+
+// PurgeEmails permanently deletes emails that are in the trash and
+// returns how many there were. Emails that are not in the trash are left
+// alone.
+func PurgeEmails(ctx context.Context, pool *pgxpool.Pool, emailIDs []string) (int64, error) {
+	result, err := pool.Exec(ctx, `DELETE FROM emails WHERE id = ANY($1) AND deleted_at > 0`, emailIDs)
+	if err != nil {
+		return 0, fmt.Errorf("error purging emails: %v", err)
+	}
+
+	logger.Info("Purged emails", "count", result.RowsAffected())
+	return result.RowsAffected(), nil
+}
+
+// PurgeTrashBefore permanently deletes emails moved to the trash before
+// the given time and returns how many there were
+func PurgeTrashBefore(ctx context.Context, pool *pgxpool.Pool, before time.Time) (int64, error) {
+	result, err := pool.Exec(ctx, `DELETE FROM emails WHERE deleted_at > 0 AND deleted_at < $1`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("error purging trash: %v", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -4,56 +4,74 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
 )
 
-func GetClient() (*http.Client, error) {
-	ctx := context.Background()
+var (
+	logger = logging.For("gmail")
+	tracer = tracing.Tracer("gmail")
+)
 
-	// Read credentials.json file
-	b, err := os.ReadFile("credentials.json")
+func GetClient(ctx context.Context, cfg config.GmailConfig) (*http.Client, error) {
+	// Read credentials file
+	b, err := os.ReadFile(cfg.CredentialsFile)
 	if err != nil {
-		log.Fatalf("Unable to read credentials.json: %v", err)
+		return nil, fmt.Errorf("unable to read credentials file %s: %v", cfg.CredentialsFile, err)
 	}
 
 	// Create OAuth config
-	config, err := google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
+	config, err := google.ConfigFromJSON(b, gmail.GmailModifyScope)
 	if err != nil {
-		log.Fatalf("Unable to parse credentials: %v", err)
+		return nil, fmt.Errorf("unable to parse credentials file %s: %v", cfg.CredentialsFile, err)
 	}
 
 	// Get token
-	tokFile := "token.json"
+	tokFile := cfg.TokenFile
 	tok, err := tokenFromFile(tokFile)
 	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
+		tok, err = getTokenFromWeb(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveToken(tokFile, tok); err != nil {
+			return nil, err
+		}
 	}
 
-	return config.Client(ctx, tok), nil
+	// Every attempt, retries included, gets its own span
+	source := config.TokenSource(ctx, tok)
+	traced := otelhttp.NewTransport(&oauth2.Transport{Source: source},
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "gmail " + apiMethod(req)
+		}))
+	return &http.Client{Transport: &transport{base: traced, source: source}}, nil
 }
 
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+// getTokenFromWeb walks the user through the OAuth consent on the terminal
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
 	fmt.Printf("Go to this link in your browser:\n%v\n\n", authURL)
 	fmt.Print("Enter authorization code: ")
 
 	var authCode string
 	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
+		return nil, fmt.Errorf("unable to read authorization code: %v", err)
 	}
 
-	tok, err := config.Exchange(context.TODO(), authCode)
+	tok, err := config.Exchange(ctx, authCode)
 	if err != nil {
-		log.Fatalf("Unable to retrieve token: %v", err)
+		return nil, fmt.Errorf("unable to retrieve token: %v", err)
 	}
-	return tok
+	return tok, nil
 }
 
 func tokenFromFile(file string) (*oauth2.Token, error) {
@@ -67,12 +85,15 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 	return tok, err
 }
 
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving token to: %s\n", path)
+func saveToken(path string, token *oauth2.Token) error {
+	logger.Info("Saving token", "path", path)
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Fatalf("Unable to cache token: %v", err)
+		return fmt.Errorf("unable to cache token: %v", err)
 	}
 	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	if err := json.NewEncoder(f).Encode(token); err != nil {
+		return fmt.Errorf("unable to cache token: %v", err)
+	}
+	return nil
 }
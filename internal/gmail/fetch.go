@@ -2,106 +2,283 @@ package gmail
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"iter"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
 	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 )
 
-// FetchEmails retrieves emails from Gmail with optional limit
-// If maxResults is 0, it fetches ALL emails (with pagination)
-func FetchEmails(client *http.Client, maxResults int64) ([]models.Email, error) {
-	ctx := context.Background()
-
-	// Create Gmail service
-	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		return nil, fmt.Errorf("unable to create Gmail service: %v", err)
-	}
+// FetchProgress reports how far FetchEmails has got
+type FetchProgress struct {
+	// Total is the number of messages expected, 0 if unknown
+	Total int64
+	// Listed is the number of message IDs returned by list calls so far
+	Listed int64
+	// Fetched is the number of full messages retrieved so far
+	Fetched int64
+}
 
-	var allEmails []models.Email
-	pageToken := ""
-	user := "me"
-
-	// If maxResults is 0, fetch ALL emails
-	fetchAll := maxResults == 0
-	
-	for {
-		// Gmail API max is 500 per request
-		batchSize := int64(500)
-		if !fetchAll && maxResults < 500 {
-			batchSize = maxResults
-		}
+// FetchedEmail is an email produced by FetchEmails together with the
+// position it was listed at, so a consumer can checkpoint after saving it
+type FetchedEmail struct {
+	Email models.Email
+	// PageToken is the list token of the page the email came from,
+	// empty for the first page
+	PageToken string
+}
 
-		// Build the request
-		req := srv.Users.Messages.List(user).MaxResults(batchSize)
-		if pageToken != "" {
-			req = req.PageToken(pageToken)
-		}
+// Checkpoint returns the position to resume from once this email is saved
+func (f FetchedEmail) Checkpoint() models.SyncCheckpoint {
+	return models.SyncCheckpoint{PageToken: f.PageToken, LastMessageID: f.Email.ID}
+}
 
-		// Execute the request
-		r, err := req.Do()
+// FetchEmails streams emails from Gmail with optional limit
+// If maxResults is 0, it fetches ALL emails (with pagination)
+// Listing starts at from.PageToken and skips everything up to and including
+// from.LastMessageID, so an interrupted sync can pick up where it stopped.
+// Iteration stops at the first error, which is yielded with a zero email.
+// onProgress may be nil.
+func FetchEmails(ctx context.Context, client *http.Client, cfg config.GmailConfig, maxResults int64, from models.SyncCheckpoint, onProgress func(FetchProgress)) iter.Seq2[FetchedEmail, error] {
+	return func(yield func(FetchedEmail, error) bool) {
+		// Create Gmail service
+		srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 		if err != nil {
-			return nil, fmt.Errorf("unable to retrieve messages: %v", err)
+			yield(FetchedEmail{}, fmt.Errorf("unable to create Gmail service: %v", err))
+			return
 		}
 
-		fmt.Printf("Fetched %d message IDs (total so far: %d)...\n", len(r.Messages), len(allEmails)+len(r.Messages))
+		pageToken := from.PageToken
+		skipThrough := from.LastMessageID
+		user := "me"
 
-		// Process each message
-		for _, msg := range r.Messages {
-			message, err := srv.Users.Messages.Get(user, msg.Id).Format("full").Do()
+		// If maxResults is 0, fetch ALL emails
+		fetchAll := maxResults == 0
+
+		var progress FetchProgress
+		if fetchAll {
+			profile, err := srv.Users.GetProfile(user).Context(ctx).Do()
 			if err != nil {
-				fmt.Printf("Unable to retrieve message %s: %v\n", msg.Id, err)
-				continue
+				yield(FetchedEmail{}, fmt.Errorf("unable to retrieve profile: %v", err))
+				return
+			}
+			progress.Total = profile.MessagesTotal
+		} else {
+			progress.Total = maxResults
+		}
+		report := func() {
+			if onProgress != nil {
+				onProgress(progress)
+			}
+		}
+		report()
+
+		// Each page is a span covering its list call and message gets
+		var page trace.Span
+		defer func() {
+			if page != nil {
+				page.End()
+			}
+		}()
+
+		for {
+			// Gmail API max is 500 per request, enforced by config validation
+			batchSize := cfg.PageSize
+			if !fetchAll && maxResults-progress.Fetched < batchSize {
+				batchSize = maxResults - progress.Fetched
 			}
 
-			email := models.Email{
-				ID: msg.Id,
+			// Build the request
+			req := srv.Users.Messages.List(user).MaxResults(batchSize)
+			if pageToken != "" {
+				req = req.PageToken(pageToken)
 			}
 
-			// Extract headers
-			for _, header := range message.Payload.Headers {
-				switch header.Name {
-				case "From":
-					email.From = header.Value
-				case "Subject":
-					email.Subject = header.Value
-				case "Date":
-					email.Date = header.Value
+			var pageCtx context.Context
+			pageCtx, page = tracer.Start(ctx, "gmail.MessagesPage", trace.WithAttributes(
+				attribute.Int64("gmail.page_size", batchSize),
+				attribute.Bool("gmail.first_page", pageToken == ""),
+			))
+
+			// Execute the request
+			r, err := req.Context(pageCtx).Do()
+			if err != nil {
+				if ctx.Err() != nil {
+					yield(FetchedEmail{}, ctx.Err())
+					return
 				}
+				err = fmt.Errorf("unable to retrieve messages: %v", err)
+				page.RecordError(err)
+				yield(FetchedEmail{}, err)
+				return
+			}
+
+			messages := r.Messages
+			if skipThrough != "" {
+				messages = messagesAfter(messages, skipThrough)
+				skipThrough = ""
 			}
 
-			// Extract body (simplified - gets snippet)
-			email.Body = message.Snippet
+			page.SetAttributes(attribute.Int("gmail.messages", len(messages)))
+			progress.Listed += int64(len(messages))
+			report()
+			logger.Debug("Listed message IDs", "count", len(messages), "listed", progress.Listed)
 
-			allEmails = append(allEmails, email)
+			// Process each message
+			for _, msg := range messages {
+				message, err := srv.Users.Messages.Get(user, msg.Id).Format("full").Context(pageCtx).Do()
+				if err != nil {
+					if ctx.Err() != nil {
+						yield(FetchedEmail{}, ctx.Err())
+						return
+					}
+					logger.Warn("Unable to retrieve message", "id", msg.Id, "err", err)
+					continue
+				}
 
-			// If we have a specific limit and reached it, stop
-			if !fetchAll && int64(len(allEmails)) >= maxResults {
-				return allEmails, nil
+				progress.Fetched++
+				metrics.MessagesFetched.Inc()
+				report()
+				if !yield(FetchedEmail{Email: parseMessage(message), PageToken: pageToken}, nil) {
+					return
+				}
+
+				// If we have a specific limit and reached it, stop
+				if !fetchAll && progress.Fetched >= maxResults {
+					return
+				}
+			}
+
+			page.End()
+			page = nil
+
+			// Check if there are more pages
+			pageToken = r.NextPageToken
+			if pageToken == "" {
+				// No more pages
+				break
 			}
 		}
 
-		// Check if there are more pages
-		pageToken = r.NextPageToken
-		if pageToken == "" {
-			// No more pages
-			break
+		logger.Info("Finished fetching", "fetched", progress.Fetched)
+	}
+}
+
+// messagesAfter drops everything up to and including the message with the
+// given ID. If the ID is not on the page, e.g. because it was deleted since
+// the checkpoint was taken, the whole page is kept; saving is idempotent.
+func messagesAfter(messages []*gmail.Message, id string) []*gmail.Message {
+	for i, msg := range messages {
+		if msg.Id == id {
+			return messages[i+1:]
 		}
+	}
+	return messages
+}
 
-		// If not fetching all and we got enough, stop
-		if !fetchAll && int64(len(allEmails)) >= maxResults {
-			break
+func parseMessage(message *gmail.Message) models.Email {
+	email := models.Email{
+		ID:           message.Id,
+		ThreadID:     message.ThreadId,
+		Labels:       message.LabelIds,
+		SizeEstimate: message.SizeEstimate,
+	}
+	if message.InternalDate > 0 {
+		email.ReceivedAt = time.UnixMilli(message.InternalDate)
+	}
+
+	// Extract headers
+	var headers strings.Builder
+	for _, header := range message.Payload.Headers {
+		switch header.Name {
+		case "From":
+			email.From = header.Value
+		case "Subject":
+			email.Subject = header.Value
+		case "Date":
+			email.Date = header.Value
+			if email.ReceivedAt.IsZero() {
+				email.ReceivedAt, _ = mail.ParseDate(header.Value)
+			}
 		}
+		fmt.Fprintf(&headers, "%s: %s\n", header.Name, header.Value)
 	}
+	email.Headers = headers.String()
 
-	fmt.Printf("Finished fetching. Total emails: %d\n", len(allEmails))
-	return allEmails, nil
+	// Extract body, falling back to the snippet if there is no text part
+	plain, html := bodyParts(message.Payload)
+	email.Body = plain
+	if email.Body == "" {
+		email.Body = message.Snippet
+	}
+	email.HTMLBody = html
+	email.Attachments = attachmentNames(message.Payload, nil)
+
+	return email
+}
+
+// attachmentNames appends the file names of the attachments under part
+// to names
+func attachmentNames(part *gmail.MessagePart, names []string) []string {
+	if part == nil {
+		return names
+	}
+	if part.Filename != "" {
+		names = append(names, part.Filename)
+	}
+	for _, child := range part.Parts {
+		names = attachmentNames(child, names)
+	}
+	return names
 }
 
-// FetchAllEmails is a convenience function to fetch all emails
-func FetchAllEmails(client *http.Client) ([]models.Email, error) {
-	return FetchEmails(client, 0)
+// bodyParts returns the first text/plain and text/html parts of a message,
+// skipping attachments
+func bodyParts(part *gmail.MessagePart) (plain, html string) {
+	if part == nil || part.Filename != "" {
+		return "", ""
+	}
+
+	if part.Body != nil && part.Body.Data != "" {
+		switch strings.ToLower(part.MimeType) {
+		case "text/plain":
+			plain = decodeBody(part.Body.Data)
+		case "text/html":
+			html = decodeBody(part.Body.Data)
+		}
+	}
+
+	for _, child := range part.Parts {
+		p, h := bodyParts(child)
+		if plain == "" {
+			plain = p
+		}
+		if html == "" {
+			html = h
+		}
+	}
+	return plain, html
+}
+
+// decodeBody decodes a part body. Gmail uses URL-safe base64, usually
+// padded; anything that is not valid UTF-8 is dropped.
+func decodeBody(data string) string {
+	decoded, err := base64.URLEncoding.DecodeString(data)
+	if err != nil {
+		decoded, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+		if err != nil {
+			return ""
+		}
+	}
+	return strings.ToValidUTF8(string(decoded), "")
 }
@@ -0,0 +1,177 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"slices"
+
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// ErrHistoryExpired means Gmail no longer has the history since the
+// requested ID, which it keeps for about a week, so a full sync is needed
+var ErrHistoryExpired = errors.New("gmail history is no longer available")
+
+// MailboxChanges is what changed in the mailbox since a history ID
+type MailboxChanges struct {
+	// Added are new messages
+	Added []string
+	// Relabeled are existing messages whose labels changed
+	Relabeled []string
+	// Deleted are messages deleted for good
+	Deleted []string
+	// HistoryID is the ID the changes reach up to
+	HistoryID uint64
+}
+
+// CurrentHistoryID returns the mailbox's latest history ID
+func CurrentHistoryID(ctx context.Context, client *http.Client) (uint64, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return 0, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	profile, err := srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return 0, fmt.Errorf("unable to retrieve profile: %v", err)
+	}
+	return profile.HistoryId, nil
+}
+
+// ListChanges returns what changed since startHistoryID, or
+// ErrHistoryExpired if that is too long ago
+func ListChanges(ctx context.Context, client *http.Client, startHistoryID uint64) (changes MailboxChanges, err error) {
+	ctx, span := tracer.Start(ctx, "gmail.ListChanges")
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("gmail.added", len(changes.Added)),
+			attribute.Int("gmail.relabeled", len(changes.Relabeled)),
+			attribute.Int("gmail.deleted", len(changes.Deleted)),
+		)
+		tracing.End(span, err)
+	}()
+
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return MailboxChanges{}, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	changes = MailboxChanges{HistoryID: startHistoryID}
+	added := make(map[string]bool)
+	relabeled := make(map[string]bool)
+	deleted := make(map[string]bool)
+
+	req := srv.Users.History.List("me").
+		StartHistoryId(startHistoryID).
+		HistoryTypes("messageAdded", "messageDeleted", "labelAdded", "labelRemoved")
+	err = req.Pages(ctx, func(r *gmail.ListHistoryResponse) error {
+		for _, h := range r.History {
+			for _, m := range h.MessagesAdded {
+				added[m.Message.Id] = true
+			}
+			for _, m := range h.LabelsAdded {
+				relabeled[m.Message.Id] = true
+			}
+			for _, m := range h.LabelsRemoved {
+				relabeled[m.Message.Id] = true
+			}
+			for _, m := range h.MessagesDeleted {
+				deleted[m.Message.Id] = true
+			}
+		}
+		changes.HistoryID = max(changes.HistoryID, r.HistoryId)
+		return nil
+	})
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return MailboxChanges{}, ErrHistoryExpired
+		}
+		return MailboxChanges{}, fmt.Errorf("unable to retrieve history: %v", err)
+	}
+
+	// A message added and then deleted is just gone, and a new message's
+	// labels are fetched with it
+	for id := range deleted {
+		delete(added, id)
+		delete(relabeled, id)
+		changes.Deleted = append(changes.Deleted, id)
+	}
+	for id := range added {
+		delete(relabeled, id)
+		changes.Added = append(changes.Added, id)
+	}
+	for id := range relabeled {
+		changes.Relabeled = append(changes.Relabeled, id)
+	}
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Relabeled)
+	slices.Sort(changes.Deleted)
+	return changes, nil
+}
+
+// FetchMessages streams the full messages with the given IDs. Messages
+// deleted since they were listed are skipped. Iteration stops at the first
+// other error, which is yielded with a zero email.
+func FetchMessages(ctx context.Context, client *http.Client, ids []string) iter.Seq2[models.Email, error] {
+	return fetchMessages(ctx, client, ids, parseMessage)
+}
+
+// FetchSizes streams the size estimate and attachment names of the
+// messages with the given IDs, for emails saved before they were recorded.
+// Only ID, SizeEstimate and Attachments are set. Messages are skipped and
+// errors yielded as by FetchMessages.
+func FetchSizes(ctx context.Context, client *http.Client, ids []string) iter.Seq2[models.Email, error] {
+	return fetchMessages(ctx, client, ids, func(message *gmail.Message) models.Email {
+		return models.Email{
+			ID:           message.Id,
+			SizeEstimate: message.SizeEstimate,
+			Attachments:  attachmentNames(message.Payload, nil),
+		}
+	}, "id", "sizeEstimate", "payload(filename,parts)")
+}
+
+// fetchMessages gets each message, limited to fields if any are given,
+// and yields it parsed
+func fetchMessages(ctx context.Context, client *http.Client, ids []string, parse func(*gmail.Message) models.Email, fields ...googleapi.Field) iter.Seq2[models.Email, error] {
+	return func(yield func(models.Email, error) bool) {
+		srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+		if err != nil {
+			yield(models.Email{}, fmt.Errorf("unable to create Gmail service: %v", err))
+			return
+		}
+
+		for _, id := range ids {
+			call := srv.Users.Messages.Get("me", id).Format("full").Context(ctx)
+			if len(fields) > 0 {
+				call = call.Fields(fields...)
+			}
+			message, err := call.Do()
+			if err != nil {
+				var apiErr *googleapi.Error
+				if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+					continue
+				}
+				if ctx.Err() != nil {
+					yield(models.Email{}, ctx.Err())
+					return
+				}
+				yield(models.Email{}, fmt.Errorf("unable to retrieve message %s: %v", id, err))
+				return
+			}
+			metrics.MessagesFetched.Inc()
+			if !yield(parse(message), nil) {
+				return
+			}
+		}
+	}
+}
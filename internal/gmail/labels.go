@@ -0,0 +1,95 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// FetchLabels retrieves the label definitions of the mailbox
+func FetchLabels(ctx context.Context, client *http.Client) ([]models.Label, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	r, err := srv.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve labels: %v", err)
+	}
+
+	labels := make([]models.Label, 0, len(r.Labels))
+	for _, l := range r.Labels {
+		labels = append(labels, models.Label{ID: l.Id, Name: l.Name, Type: l.Type})
+	}
+	return labels, nil
+}
+
+// CreateLabel adds a user label, shown in the label list and on messages
+func CreateLabel(ctx context.Context, client *http.Client, name string) (models.Label, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return models.Label{}, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	l, err := srv.Users.Labels.Create("me", &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	}).Context(ctx).Do()
+	if err != nil {
+		return models.Label{}, fmt.Errorf("unable to create label %q: %v%s", name, err, scopeHint(err))
+	}
+	return models.Label{ID: l.Id, Name: l.Name, Type: l.Type}, nil
+}
+
+// ErrUnreachable means Gmail could not be reached or kept failing after
+// retries, so the change can be sent again later
+var ErrUnreachable = errors.New("Gmail is unreachable")
+
+// unreachable reports whether err is a network failure, a rate limit or
+// a server error rather than Gmail refusing the request
+func unreachable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// batchModifyLimit is the most message IDs one BatchModify call accepts
+const batchModifyLimit = 1000
+
+// ModifyLabels adds and removes labels on messages. Archiving is
+// removing INBOX. The change can safely be repeated, and errors when
+// Gmail cannot be reached wrap ErrUnreachable.
+func ModifyLabels(ctx context.Context, client *http.Client, ids []string, add, remove []string) error {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	for start := 0; start < len(ids); start += batchModifyLimit {
+		end := min(start+batchModifyLimit, len(ids))
+		err := srv.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
+			Ids:            ids[start:end],
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		}).Context(ctx).Do()
+		if err != nil {
+			if ctx.Err() == nil && unreachable(err) {
+				return fmt.Errorf("%w: %v", ErrUnreachable, err)
+			}
+			return fmt.Errorf("unable to modify labels of messages: %v%s", err, scopeHint(err))
+		}
+	}
+	return nil
+}
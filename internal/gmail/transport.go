@@ -0,0 +1,134 @@
+package gmail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// maxRetries is how often a rate limited or failed call is repeated
+	maxRetries = 3
+	// retryBaseDelay is the wait before the first retry, doubled for each
+	// one after
+	retryBaseDelay = time.Second
+	// maxRetryDelay caps the wait, including one asked for by Gmail
+	maxRetryDelay = 30 * time.Second
+)
+
+// transport counts Gmail API calls and retries those that hit a rate
+// limit (429) or a server error (5xx), backing off in between
+type transport struct {
+	base http.RoundTripper
+	// source is the OAuth token source base authorizes requests with
+	source oauth2.TokenSource
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := apiMethod(req)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		metrics.GmailRequests.Inc(method, status)
+
+		if err != nil || !retryable(resp.StatusCode) || attempt == maxRetries || !rewindable(req) {
+			return resp, err
+		}
+		delay := retryDelay(resp, attempt)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		metrics.GmailRetries.Inc(method)
+	}
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// rewindable reports whether the request body can be sent again
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// retryDelay honours a Retry-After in seconds and otherwise backs off
+// exponentially with jitter
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		return min(time.Duration(secs)*time.Second, maxRetryDelay)
+	}
+	delay := retryBaseDelay << attempt
+	delay += rand.N(delay / 2)
+	return min(delay, maxRetryDelay)
+}
+
+// idCollections are path segments followed by an ID, unless the next
+// segment is one of collectionVerbs
+var (
+	idCollections   = map[string]bool{"messages": true, "threads": true, "labels": true, "drafts": true, "attachments": true}
+	collectionVerbs = map[string]bool{"batchModify": true, "batchDelete": true, "import": true, "send": true}
+)
+
+// apiMethod names the call for metrics, such as "GET messages/{id}" for
+// /gmail/v1/users/me/messages/18c2..., keeping IDs out of the labels
+func apiMethod(req *http.Request) string {
+	_, rest, ok := strings.Cut(req.URL.Path, "/users/")
+	if !ok {
+		return req.Method + " other"
+	}
+	segments := strings.Split(rest, "/")[1:]
+	for i := 1; i < len(segments); i++ {
+		if idCollections[segments[i-1]] && !collectionVerbs[segments[i]] {
+			segments[i] = "{id}"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+// CheckToken reports whether a client from GetClient has a usable access
+// token, refreshing it if it expired
+func CheckToken(client *http.Client) error {
+	t, ok := client.Transport.(*transport)
+	if !ok {
+		return errors.New("not a Gmail client")
+	}
+	if t.source == nil {
+		return errors.New("Gmail client has no OAuth token")
+	}
+	if _, err := t.source.Token(); err != nil {
+		return fmt.Errorf("OAuth token is not usable: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package gmail
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIMethod(t *testing.T) {
+	tests := []struct {
+		method, url string
+		want        string
+	}{
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/me/profile", "GET profile"},
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/me/messages", "GET messages"},
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/me/messages/18c2f3a9b7d1e0f4?format=full", "GET messages/{id}"},
+		{"POST", "https://gmail.googleapis.com/gmail/v1/users/me/messages/18c2f3a9b7d1e0f4/trash", "POST messages/{id}/trash"},
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/me/messages/18c2/attachments/ANGjdJ8", "GET messages/{id}/attachments/{id}"},
+		{"POST", "https://gmail.googleapis.com/gmail/v1/users/me/messages/batchModify", "POST messages/batchModify"},
+		{"POST", "https://gmail.googleapis.com/gmail/v1/users/me/messages/send", "POST messages/send"},
+		{"DELETE", "https://gmail.googleapis.com/gmail/v1/users/me/labels/Label_42", "DELETE labels/{id}"},
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/someone%40example.com/threads/abc", "GET threads/{id}"},
+		{"GET", "https://gmail.googleapis.com/gmail/v1/users/me/history?startHistoryId=1", "GET history"},
+		{"POST", "https://gmail.googleapis.com/gmail/v1/users/me/watch", "POST watch"},
+		{"POST", "https://gmail.googleapis.com/batch/gmail/v1", "POST other"},
+		{"POST", "https://oauth2.googleapis.com/token", "POST other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want+" "+tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if got := apiMethod(req); got != tt.want {
+				t.Errorf("apiMethod(%s %s) = %q, want %q", tt.method, tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		attempt    int
+		// The delay must fall in [min, max]
+		min, max time.Duration
+	}{
+		{name: "Retry-After", retryAfter: "7", attempt: 0, min: 7 * time.Second, max: 7 * time.Second},
+		{name: "Retry-After zero", retryAfter: "0", attempt: 3, min: 0, max: 0},
+		{name: "Retry-After capped", retryAfter: "3600", attempt: 0, min: maxRetryDelay, max: maxRetryDelay},
+		{name: "Retry-After date is ignored", retryAfter: "Wed, 21 Oct 2015 07:28:00 GMT", attempt: 0,
+			min: retryBaseDelay, max: retryBaseDelay * 3 / 2},
+		{name: "negative Retry-After is ignored", retryAfter: "-5", attempt: 1,
+			min: 2 * retryBaseDelay, max: 3 * retryBaseDelay},
+		{name: "first retry", attempt: 0, min: retryBaseDelay, max: retryBaseDelay * 3 / 2},
+		{name: "third retry", attempt: 2, min: 4 * retryBaseDelay, max: 6 * retryBaseDelay},
+		{name: "capped backoff", attempt: 20, min: maxRetryDelay, max: maxRetryDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			// The jitter is random, so try a few times
+			for range 20 {
+				if got := retryDelay(resp, tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("retryDelay(attempt %d) = %s, want between %s and %s", tt.attempt, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
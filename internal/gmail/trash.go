@@ -0,0 +1,70 @@
+package gmail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// TrashMessages moves messages to the Gmail trash, where Gmail deletes
+// them for good after 30 days. Messages already deleted for good count as
+// trashed. It stops at the first failure and returns the IDs trashed so
+// far with the error.
+func TrashMessages(ctx context.Context, client *http.Client, ids []string) ([]string, error) {
+	return eachMessage(ctx, client, ids, "trash", func(srv *gmail.Service, id string) error {
+		_, err := srv.Users.Messages.Trash("me", id).Context(ctx).Do()
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// UntrashMessages takes messages back out of the Gmail trash. It stops at
+// the first failure and returns the IDs restored so far with the error.
+func UntrashMessages(ctx context.Context, client *http.Client, ids []string) ([]string, error) {
+	return eachMessage(ctx, client, ids, "untrash", func(srv *gmail.Service, id string) error {
+		_, err := srv.Users.Messages.Untrash("me", id).Context(ctx).Do()
+		return err
+	})
+}
+
+// eachMessage calls call on each message in turn. Errors when Gmail
+// cannot be reached wrap ErrUnreachable.
+func eachMessage(ctx context.Context, client *http.Client, ids []string, verb string, call func(*gmail.Service, string) error) ([]string, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	done := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if err := call(srv, id); err != nil {
+			if ctx.Err() != nil {
+				return done, ctx.Err()
+			}
+			if unreachable(err) {
+				return done, fmt.Errorf("%w: unable to %s message %s: %v", ErrUnreachable, verb, id, err)
+			}
+			return done, fmt.Errorf("unable to %s message %s: %v%s", verb, id, err, scopeHint(err))
+		}
+		done = append(done, id)
+	}
+	return done, nil
+}
+
+// scopeHint explains a permission error caused by a token that was saved
+// when the app only asked for read access
+func scopeHint(err error) string {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		return " (the saved token may only grant read access; delete the token file and sign in again)"
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// Watch asks Gmail to publish changes to the mailbox to a Pub/Sub topic.
+// Calling it again renews the watch. It returns when the watch expires,
+// which is at most a week away.
+func Watch(ctx context.Context, client *http.Client, topic string) (time.Time, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	resp, err := srv.Users.Watch("me", &gmail.WatchRequest{TopicName: topic}).Context(ctx).Do()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to watch mailbox: %v", err)
+	}
+	return time.UnixMilli(resp.Expiration), nil
+}
+
+// EmailAddress returns the address of the signed in account
+func EmailAddress(ctx context.Context, client *http.Client) (string, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return "", fmt.Errorf("unable to create Gmail service: %v", err)
+	}
+
+	profile, err := srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve profile: %v", err)
+	}
+	return profile.EmailAddress, nil
+}
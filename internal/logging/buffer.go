@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bufferSize is how many entries Recent keeps
+const bufferSize = 2000
+
+// Entry is one log entry as kept for the log viewer
+type Entry struct {
+	Time      time.Time
+	Level     slog.Level
+	Component string
+	Message   string
+	// Attrs are the remaining attributes as key=value pairs
+	Attrs string
+}
+
+// ring keeps the latest entries
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	// changed is closed and replaced whenever an entry is added
+	changed chan struct{}
+}
+
+var recent = &ring{changed: make(chan struct{})}
+
+func (b *ring) add(e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) < bufferSize {
+		b.entries = append(b.entries, e)
+	} else {
+		b.entries[b.next] = e
+		b.next = (b.next + 1) % bufferSize
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Recent returns the latest entries, oldest first, and a channel closed
+// when the next one arrives
+func Recent() ([]Entry, <-chan struct{}) {
+	b := recent
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Entry, 0, len(b.entries))
+	out = append(out, b.entries[b.next:]...)
+	out = append(out, b.entries[:b.next]...)
+	return out, b.changed
+}
+
+// bufferHandler turns records into entries for the ring
+type bufferHandler struct {
+	level     slog.Level
+	buf       *ring
+	component string
+	// attrs are attributes added with WithAttrs, already formatted
+	attrs []string
+	group string
+}
+
+func (h *bufferHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *bufferHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := append([]string{}, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttr(attrs, h.group, a)
+		return true
+	})
+	h.buf.add(Entry{
+		Time:      r.Time,
+		Level:     r.Level,
+		Component: h.component,
+		Message:   r.Message,
+		Attrs:     strings.Join(attrs, " "),
+	})
+	return nil
+}
+
+func (h *bufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.attrs = append([]string{}, h.attrs...)
+	for _, a := range attrs {
+		if a.Key == "component" && h.group == "" {
+			out.component = a.Value.String()
+			continue
+		}
+		out.attrs = appendAttr(out.attrs, h.group, a)
+	}
+	return &out
+}
+
+func (h *bufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.group = h.group + name + "."
+	return &out
+}
+
+func appendAttr(attrs []string, group string, a slog.Attr) []string {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		prefix := group
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	value := a.Value.String()
+	if strings.ContainsAny(value, " \"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+	return append(attrs, group+a.Key+"="+value)
+}
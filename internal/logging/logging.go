@@ -0,0 +1,145 @@
+// Package logging sets up log/slog for the whole program: leveled text or
+// JSON entries on stderr and in a rotating file, with the most recent kept
+// in memory for the log viewer. Packages log through a logger from For,
+// which tags every entry with its component.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+)
+
+// root is the handler every logger writes through. Until Setup runs it
+// writes text to stderr.
+var root atomic.Pointer[slog.Handler]
+
+func init() {
+	setRoot(slog.NewTextHandler(os.Stderr, nil))
+}
+
+func setRoot(h slog.Handler) {
+	root.Store(&h)
+}
+
+// For returns the logger of a component, such as "gmail" or "sync". It can
+// be created before Setup runs and follows it.
+func For(component string) *slog.Logger {
+	return slog.New(&lazyHandler{}).With("component", component)
+}
+
+// Setup sends every entry at cfg.Level or above to stderr, the log file if
+// one is set and the buffer behind Recent. slog's default logger, and with
+// it the log package, go the same way. The returned function closes the
+// log file.
+func Setup(cfg config.LogConfig) (func() error, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %v", cfg.Level, err)
+	}
+
+	var out io.Writer = os.Stderr
+	closeFile := func() error { return nil }
+	if cfg.File != "" {
+		file, err := openRotatingFile(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out = io.MultiWriter(os.Stderr, file)
+		closeFile = file.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var output slog.Handler
+	if cfg.Format == config.LogFormatJSON {
+		output = slog.NewJSONHandler(out, opts)
+	} else {
+		output = slog.NewTextHandler(out, opts)
+	}
+
+	setRoot(fanout{output, &bufferHandler{level: level, buf: recent}})
+	slog.SetDefault(slog.New(&lazyHandler{}))
+	return closeFile, nil
+}
+
+// lazyHandler looks up the root handler for every entry, so loggers made
+// before Setup still follow it. Attributes and groups added to the logger
+// are replayed onto the root each time.
+type lazyHandler struct {
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h *lazyHandler) current() slog.Handler {
+	handler := *root.Load()
+	for _, wrap := range h.wrap {
+		handler = wrap(handler)
+	}
+	return handler
+}
+
+func (h *lazyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*root.Load()).Enabled(ctx, level)
+}
+
+func (h *lazyHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h *lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *lazyHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *lazyHandler) with(wrap func(slog.Handler) slog.Handler) slog.Handler {
+	wraps := append(append([]func(slog.Handler) slog.Handler{}, h.wrap...), wrap)
+	return &lazyHandler{wrap: wraps}
+}
+
+// fanout passes every entry to each handler that wants it
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
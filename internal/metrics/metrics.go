@@ -0,0 +1,330 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// writes them in the Prometheus text format. Every metric is created here
+// and registered with the package so the /metrics handler finds it.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// MessagesFetched counts full messages fetched from Gmail
+	MessagesFetched = NewCounter("gmailscraper_messages_fetched_total",
+		"Messages fetched from Gmail")
+	// GmailRequests counts Gmail API calls by method and HTTP status,
+	// "error" if no response came back
+	GmailRequests = NewCounter("gmailscraper_gmail_requests_total",
+		"Gmail API calls by method and HTTP status", "method", "status")
+	// GmailRetries counts Gmail API calls repeated after a rate limit or
+	// server error
+	GmailRetries = NewCounter("gmailscraper_gmail_retries_total",
+		"Gmail API calls retried after a rate limit or server error", "method")
+	// SyncDuration is how long syncs take by trigger, mode and result
+	SyncDuration = NewHistogram("gmailscraper_sync_duration_seconds",
+		"Duration of syncs by trigger, mode and result",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}, "trigger", "mode", "result")
+	// DBUpsertDuration is how long saving a batch takes by table
+	DBUpsertDuration = NewHistogram("gmailscraper_db_upsert_duration_seconds",
+		"Duration of database upserts by table",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}, "table")
+	// LastSuccessfulSync is when the last sync in this process succeeded
+	LastSuccessfulSync = NewGauge("gmailscraper_last_successful_sync_timestamp_seconds",
+		"Unix time the last successful sync in this process finished")
+)
+
+// metric is anything the registry can write out
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// WriteAll writes every registered metric in the Prometheus text format
+func WriteAll(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// family holds what every kind of metric shares: its name, help text,
+// label names and one series per combination of label values
+type family struct {
+	name, help, kind string
+	labels           []string
+
+	mu     sync.Mutex
+	series map[string]any
+}
+
+func newFamily(name, help, kind string, labels []string) family {
+	return family{name: name, help: help, kind: kind, labels: labels, series: make(map[string]any)}
+}
+
+// get returns the series for values, creating it with create if needed
+func (f *family) get(values []string, create func() any) any {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+	}
+	return s
+}
+
+// each calls fn for every series in a stable order, with its labels
+// formatted for the exposition format
+func (f *family) each(fn func(labels string, s any)) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	series := make(map[string]any, len(f.series))
+	for key, s := range f.series {
+		series[key] = s
+	}
+	f.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		var values []string
+		if len(f.labels) > 0 {
+			values = strings.Split(key, "\xff")
+		}
+		fn(formatLabels(f.labels, values), series[key])
+	}
+}
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+}
+
+// Counter is a count that only goes up, optionally split by labels
+type Counter struct {
+	family
+}
+
+// NewCounter creates and registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, "counter", labels)}
+	if len(labels) == 0 {
+		c.Add(0)
+	}
+	register(c)
+	return c
+}
+
+// Add adds n to the series with the given label values
+func (c *Counter) Add(n float64, values ...string) {
+	v := c.get(values, func() any { return new(atomicFloat) }).(*atomicFloat)
+	v.add(n)
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w)
+	c.each(func(labels string, s any) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatFloat(s.(*atomicFloat).load()))
+	})
+}
+
+// Gauge is a value that goes up and down, optionally split by labels
+type Gauge struct {
+	family
+}
+
+// NewGauge creates and registers a gauge with the given label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{family: newFamily(name, help, "gauge", labels)}
+	if len(labels) == 0 {
+		g.Set(0)
+	}
+	register(g)
+	return g
+}
+
+// Set sets the series with the given label values
+func (g *Gauge) Set(n float64, values ...string) {
+	v := g.get(values, func() any { return new(atomicFloat) }).(*atomicFloat)
+	v.store(n)
+}
+
+// SetTime sets the series to t as Unix seconds
+func (g *Gauge) SetTime(t time.Time, values ...string) {
+	g.Set(float64(t.UnixNano())/1e9, values...)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w)
+	g.each(func(labels string, s any) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatFloat(s.(*atomicFloat).load()))
+	})
+}
+
+// Histogram counts observations into buckets, optionally split by labels
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+type histogramSeries struct {
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates and registers a histogram with the given upper
+// bucket bounds, in increasing order, and label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, "histogram", labels), buckets: buckets}
+	register(h)
+	return h
+}
+
+// Observe records v in the series with the given label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	s := h.get(values, func() any { return &histogramSeries{counts: make([]uint64, len(h.buckets))} }).(*histogramSeries)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// ObserveSince records the seconds since start
+func (h *Histogram) ObserveSince(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w)
+	h.each(func(labels string, s any) {
+		series := s.(*histogramSeries)
+		series.mu.Lock()
+		counts := append([]uint64(nil), series.counts...)
+		sum, count := series.sum, series.count
+		series.mu.Unlock()
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLe(labels, formatFloat(bound)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLe(labels, "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, count)
+	})
+}
+
+// WriteGauge writes a gauge family computed at scrape time, such as row
+// counts read from the database. values maps the value of the one label
+// to the gauge.
+func WriteGauge(w io.Writer, name, help, label string, values map[string]float64) {
+	f := family{name: name, help: help, kind: "gauge"}
+	f.header(w)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels([]string{label}, []string{k}), formatFloat(values[k]))
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLe adds the le label of a histogram bucket to formatted labels
+func withLe(labels, le string) string {
+	if labels == "" {
+		return `{le="` + le + `"}`
+	}
+	return labels[:len(labels)-1] + `,le="` + le + `"}`
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// atomicFloat is a float64 updated without a lock
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(n float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+n)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) store(n float64) {
+	f.bits.Store(math.Float64bits(n))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
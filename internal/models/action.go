@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Kinds of change kept in the outbox
+const (
+	ActionLabels      = "labels"
+	ActionTrash       = "trash"
+	ActionUntrash     = "untrash"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// States of an action in the outbox
+const (
+	// ActionPending is waiting to be sent, possibly to be retried
+	ActionPending = "pending"
+	// ActionSent was accepted by Gmail and waits for the next incremental
+	// sync to confirm it
+	ActionSent = "sent"
+	// ActionFailed was given up on
+	ActionFailed = "failed"
+	// ActionConflict disagrees with what Gmail reported after it
+	ActionConflict = "conflict"
+)
+
+// PendingAction is a change to the mailbox made locally and kept in the
+// outbox until Gmail has it
+type PendingAction struct {
+	ID int64
+	// Kind is one of the Action kinds above
+	Kind     string
+	EmailIDs []string
+	// AddLabels and RemoveLabels are the label IDs of an ActionLabels
+	AddLabels    []string
+	RemoveLabels []string
+	// URL is the one-click unsubscribe link of an ActionUnsubscribe
+	URL string
+	// Status is one of the Action states above
+	Status    string
+	CreatedAt time.Time
+	// NextAttemptAt is when a pending action is due to be sent
+	NextAttemptAt time.Time
+	// SentAt is when Gmail accepted it, zero until then
+	SentAt time.Time
+	// Attempts is how often sending it has failed
+	Attempts int
+	// LastError is why the last attempt failed or the conflict found
+	LastError string
+}
+
+// ActionCounts is how many actions are in each outbox state
+type ActionCounts struct {
+	Pending   int64
+	Sent      int64
+	Failed    int64
+	Conflicts int64
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// DuplicateCluster is a set of emails sharing a fingerprint
+type DuplicateCluster struct {
+	Fingerprint string
+	Subject     string
+	From        string
+	Count       int
+	// Size is the total size of every copy
+	Size int64
+}
+
+// Fingerprint identifies an email across accounts and imports, which give
+// the same message different IDs. It is the Message-ID header if there is
+// one, else a hash of the sender, date, subject and body with case and
+// whitespace differences ironed out.
+func (e Email) Fingerprint() string {
+	for _, line := range strings.Split(e.Headers, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Message-ID") {
+			if id := strings.Trim(strings.TrimSpace(value), "<>"); id != "" {
+				return "mid:" + strings.ToLower(id)
+			}
+		}
+	}
+
+	from := e.From
+	if addr, err := mail.ParseAddress(e.From); err == nil {
+		from = addr.Address
+	}
+	date := e.Date
+	if t, err := mail.ParseDate(e.Date); err == nil {
+		date = t.UTC().Format(time.RFC3339)
+	}
+
+	h := sha256.New()
+	for _, field := range []string{from, date, e.Subject, e.Body} {
+		h.Write([]byte(normalizeText(field)))
+		h.Write([]byte{0})
+	}
+	return "hash:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeText lower-cases s and collapses runs of whitespace
+func normalizeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
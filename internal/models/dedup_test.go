@@ -0,0 +1,105 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := Email{
+		ID:      "m1",
+		From:    "Alice <alice@example.com>",
+		Date:    "Mon, 2 Jan 2006 15:04:05 -0700",
+		Subject: "Quarterly report",
+		Body:    "Numbers are up.\nSee attached.",
+	}
+	with := func(change func(e *Email)) Email {
+		e := base
+		change(&e)
+		return e
+	}
+
+	tests := []struct {
+		name string
+		a, b Email
+		same bool
+	}{
+		{
+			name: "Message-ID ignores everything else",
+			a:    with(func(e *Email) { e.Headers = "Message-ID: <X1@example.com>" }),
+			b:    Email{ID: "m2", Headers: "message-id:<x1@EXAMPLE.com>", Subject: "Other"},
+			same: true,
+		},
+		{
+			name: "different Message-IDs",
+			a:    with(func(e *Email) { e.Headers = "Message-ID: <x1@example.com>" }),
+			b:    with(func(e *Email) { e.Headers = "Message-ID: <x2@example.com>" }),
+			same: false,
+		},
+		{
+			name: "empty Message-ID falls back to the content",
+			a:    with(func(e *Email) { e.Headers = "Message-ID: <>" }),
+			b:    with(func(e *Email) { e.ID = "m2" }),
+			same: true,
+		},
+		{
+			name: "other IDs",
+			a:    base,
+			b:    with(func(e *Email) { e.ID = "m2"; e.ThreadID = "t2" }),
+			same: true,
+		},
+		{
+			name: "sender display name",
+			a:    base,
+			b:    with(func(e *Email) { e.From = "alice@example.com" }),
+			same: true,
+		},
+		{
+			name: "date in another zone",
+			a:    base,
+			b:    with(func(e *Email) { e.Date = "Mon, 2 Jan 2006 22:04:05 +0000" }),
+			same: true,
+		},
+		{
+			name: "case and whitespace",
+			a:    base,
+			b:    with(func(e *Email) { e.Subject = "  QUARTERLY   Report "; e.Body = "numbers are up. see attached." }),
+			same: true,
+		},
+		{
+			name: "another sender",
+			a:    base,
+			b:    with(func(e *Email) { e.From = "Bob <bob@example.com>" }),
+			same: false,
+		},
+		{
+			name: "another body",
+			a:    base,
+			b:    with(func(e *Email) { e.Body = "Numbers are down." }),
+			same: false,
+		},
+		{
+			name: "fields do not run together",
+			a:    with(func(e *Email) { e.Subject = "ab"; e.Body = "c" }),
+			b:    with(func(e *Email) { e.Subject = "a"; e.Body = "bc" }),
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := tt.a.Fingerprint(), tt.b.Fingerprint()
+			if (a == b) != tt.same {
+				t.Errorf("fingerprints %q and %q, want same %v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func TestFingerprintPrefix(t *testing.T) {
+	if got := (Email{Headers: "Message-ID: <A@B>"}).Fingerprint(); got != "mid:a@b" {
+		t.Errorf("Fingerprint() = %q, want %q", got, "mid:a@b")
+	}
+	if got := (Email{Subject: "Hi"}).Fingerprint(); !strings.HasPrefix(got, "hash:") {
+		t.Errorf("Fingerprint() = %q, want a hash: fingerprint", got)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SenderDomain returns the registrable domain of a From header, so
+// news@mail.example.co.uk and info@example.co.uk both give example.co.uk.
+// Hosts the public suffix list knows nothing about are returned whole and
+// an unparseable From is returned lower-cased as is.
+func SenderDomain(from string) string {
+	address := from
+	if addr, err := mail.ParseAddress(from); err == nil {
+		address = addr.Address
+	}
+
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return strings.ToLower(strings.TrimSpace(from))
+	}
+	host := strings.ToLower(strings.Trim(address[at+1:], " <>."))
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// SenderDomain returns the registrable domain the email was sent from
+func (e Email) SenderDomain() string {
+	return SenderDomain(e.From)
+}
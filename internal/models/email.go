@@ -1,9 +1,40 @@
 package models
 
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrNotFound is returned when a lookup by ID matches nothing
+var ErrNotFound = errors.New("not found")
+
 type Email struct {
-	ID	string
-	From	string
-	Subject	string
-	Body	string
-	Date	string
+	ID      string
+	From    string
+	Subject string
+	// Body is the plain text body, or Gmail's snippet if there is none
+	Body string
+	Date string
+	// HTMLBody is the text/html part, empty for plain text emails
+	HTMLBody string
+	// Headers is the full header block, one "Name: Value" per line
+	Headers string
+	// ThreadID is the Gmail thread ID, empty for mail imported from
+	// elsewhere; see ThreadKey
+	ThreadID string
+	// ReceivedAt is when Gmail received the email, zero if unknown
+	ReceivedAt time.Time
+	// Labels holds the Gmail label IDs applied to the email
+	Labels []string
+	// SizeEstimate is Gmail's estimate of the message size in bytes, 0
+	// if unknown
+	SizeEstimate int64
+	// Attachments holds the file names of the attachments
+	Attachments []string
+}
+
+// HasLabel reports whether the email carries the Gmail label id
+func (e Email) HasLabel(id string) bool {
+	return slices.Contains(e.Labels, id)
 }
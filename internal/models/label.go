@@ -0,0 +1,10 @@
+package models
+
+// Label is a Gmail label, either a system label such as INBOX or one the
+// user created
+type Label struct {
+	ID   string
+	Name string
+	// Type is "system" or "user"
+	Type string
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// EmailFilter restricts which emails a paginated query returns. The zero
+// value matches every email.
+type EmailFilter struct {
+	// Sender matches from_address exactly
+	Sender string
+	// SenderContains matches senders whose address contains it, ignoring
+	// case
+	SenderContains string
+	// Domain matches the registrable domain of the sender, see
+	// SenderDomain
+	Domain string
+	// Query is a full text search in web search syntax, see
+	// store.SearchStore
+	Query string
+	// Trash selects emails in the trash instead of everything else
+	Trash bool
+	// Label matches emails carrying the label ID
+	Label string
+	// Before matches emails received before it, zero for any time
+	Before time.Time
+	// MinSize matches emails of at least this many bytes, 0 for any size
+	MinSize int64
+	// Unsubscribable matches emails with a List-Unsubscribe header
+	Unsubscribable bool
+	// IDs matches only these emails, nil for any
+	IDs []string
+}
+
+// EmailCursor marks the last row of a page of emails. The next page starts
+// after it in the query's sort order.
+type EmailCursor struct {
+	// Key is the value of the sort column for the row
+	Key string
+	ID  string
+}
+
+// SenderCount is a sender with the number of emails it sent and their
+// total size in bytes
+type SenderCount struct {
+	Address string
+	Count   int
+	Size    int64
+}
+
+// EmailSummary is the number and total size in bytes of some emails
+type EmailSummary struct {
+	Count int
+	Size  int64
+}
+
+// SizeGroup is the number and total size of the emails sharing a label,
+// a year or some other grouping
+type SizeGroup struct {
+	Name  string
+	Count int
+	Size  int64
+}
+
+// DomainCount is a sender domain with the number of emails sent from it
+// and how many distinct addresses sent them
+type DomainCount struct {
+	Domain  string
+	Count   int
+	Senders int
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// SyncCheckpoint records the last email committed by an interrupted sync
+type SyncCheckpoint struct {
+	// PageToken is the Gmail list token of the page holding LastMessageID
+	PageToken     string
+	LastMessageID string
+}
+
+// IsZero reports whether there is nothing to resume from
+func (c SyncCheckpoint) IsZero() bool {
+	return c.PageToken == "" && c.LastMessageID == ""
+}
+
+// What started a sync run
+const (
+	SyncTriggerUI       = "ui"
+	SyncTriggerAPI      = "api"
+	SyncTriggerSchedule = "schedule"
+	SyncTriggerPush     = "push"
+)
+
+// How a sync run fetched mail
+const (
+	// SyncModeFull lists the whole mailbox, resuming from a checkpoint
+	SyncModeFull = "full"
+	// SyncModeIncremental fetches what changed since the last sync
+	SyncModeIncremental = "incremental"
+)
+
+// SyncRun records one sync for the run history
+type SyncRun struct {
+	ID      int64
+	Trigger string
+	Mode    string
+	// FinishedAt is zero while the run is in progress
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Fetched    int64
+	Saved      int64
+	// Deleted is how many emails were gone from Gmail and moved to the
+	// local trash
+	Deleted int64
+	// Ruled is how many emails post-sync rules acted on
+	Ruled int64
+	// Error is empty if the run succeeded
+	Error string
+}
+
+// Running reports whether the run has not finished
+func (r SyncRun) Running() bool {
+	return r.FinishedAt.IsZero()
+}
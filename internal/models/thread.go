@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Thread is a conversation: the emails sharing a thread key
+type Thread struct {
+	ID string
+	// Subject is the subject of the first email
+	Subject string
+	// Participants are the distinct senders, in no particular order
+	Participants []string
+	Count        int
+	LastActivity time.Time
+}
+
+// ThreadCursor marks the last thread of a page of threads, which are
+// ordered by last activity
+type ThreadCursor struct {
+	LastActivity time.Time
+	ID           string
+}
+
+// ThreadKey returns the key grouping an email with the rest of its
+// conversation. That is the Gmail thread ID if there is one. Imported mail
+// is chained through its headers instead: the root of References, else
+// In-Reply-To, else its own Message-ID, so replies land with the message
+// that started the thread. The key is worked out from the email alone, so
+// a reply to a reply that only carries In-Reply-To is keyed on its parent
+// and starts a thread of its own; mail clients nearly always send
+// References, which avoids this.
+func (e Email) ThreadKey() string {
+	if e.ThreadID != "" {
+		return e.ThreadID
+	}
+
+	var references, inReplyTo, messageID string
+	for _, line := range strings.Split(e.Headers, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "references":
+			references = value
+		case "in-reply-to":
+			inReplyTo = value
+		case "message-id":
+			messageID = value
+		}
+	}
+
+	for _, ids := range []string{references, inReplyTo, messageID} {
+		if fields := strings.Fields(ids); len(fields) > 0 {
+			return "msg:" + strings.Trim(fields[0], "<>")
+		}
+	}
+	return e.ID
+}
@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestThreadKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		email Email
+		want  string
+	}{
+		{
+			name:  "Gmail thread ID wins",
+			email: Email{ID: "m1", ThreadID: "t1", Headers: "Message-ID: <a@example.com>"},
+			want:  "t1",
+		},
+		{
+			name: "root of References",
+			email: Email{ID: "m3", Headers: "Message-ID: <c@example.com>\n" +
+				"In-Reply-To: <b@example.com>\n" +
+				"References: <a@example.com> <b@example.com>"},
+			want: "msg:a@example.com",
+		},
+		{
+			name:  "In-Reply-To without References",
+			email: Email{ID: "m2", Headers: "Message-ID: <b@example.com>\nIn-Reply-To: <a@example.com>"},
+			want:  "msg:a@example.com",
+		},
+		{
+			// The root is not known without References, see ThreadKey
+			name:  "reply to a reply without References",
+			email: Email{ID: "m3", Headers: "Message-ID: <c@example.com>\nIn-Reply-To: <b@example.com>"},
+			want:  "msg:b@example.com",
+		},
+		{
+			name:  "own Message-ID",
+			email: Email{ID: "m1", Headers: "Subject: Hello\nMessage-ID: <a@example.com>"},
+			want:  "msg:a@example.com",
+		},
+		{
+			name:  "header names in any case",
+			email: Email{ID: "m2", Headers: "message-id: <b@example.com>\nIN-REPLY-TO:   <a@example.com>  "},
+			want:  "msg:a@example.com",
+		},
+		{
+			name:  "empty References falls through",
+			email: Email{ID: "m2", Headers: "References:\nIn-Reply-To: <a@example.com>"},
+			want:  "msg:a@example.com",
+		},
+		{
+			name:  "no threading headers",
+			email: Email{ID: "m1", Headers: "Subject: Hello"},
+			want:  "m1",
+		},
+		{
+			name:  "no headers",
+			email: Email{ID: "m1"},
+			want:  "m1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.email.ThreadKey(); got != tt.want {
+				t.Errorf("ThreadKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package schedule parses when the daemon syncs: either a fixed interval
+// such as "15m" or a five field cron expression such as "*/30 7-22 * * 1-5".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns when to run next
+type Schedule interface {
+	// Next returns the first run time after t
+	Next(t time.Time) time.Time
+}
+
+// Parse reads an interval or a cron expression. Cron fields are minute,
+// hour, day of month, month and day of week (0 or 7 is Sunday); each is
+// "*", a number, a range "a-b", a list "a,b" or any of those with a step
+// "/n". Times are in the local time zone.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	fields := strings.Fields(spec)
+	if len(fields) == 1 {
+		d, err := time.ParseDuration(strings.TrimPrefix(spec, "@every "))
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q is neither a duration nor a cron expression", spec)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("schedule interval must be at least 1m (got %s)", d)
+		}
+		return interval(d), nil
+	}
+	if len(fields) == 2 && fields[0] == "@every" {
+		return Parse(fields[1])
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (got %d)", spec, len(fields))
+	}
+
+	var c cron
+	var err error
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+		name     string
+	}{
+		{&c.minutes, 0, 59, "minute"},
+		{&c.hours, 0, 23, "hour"},
+		{&c.days, 1, 31, "day of month"},
+		{&c.months, 1, 12, "month"},
+		{&c.weekdays, 0, 7, "day of week"},
+	} {
+		*f.set, err = parseField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %s field %q: %v", f.name, fields[i], err)
+		}
+	}
+	// 7 is another name for Sunday
+	if c.weekdays&(1<<7) != 0 {
+		c.weekdays |= 1
+	}
+	c.anyDay = fields[2] == "*"
+	c.anyWeekday = fields[4] == "*"
+	return c, nil
+}
+
+type interval time.Duration
+
+func (d interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// cron holds one bit per allowed value of each field
+type cron struct {
+	minutes, hours, days, months, weekdays uint64
+	// anyDay and anyWeekday record a "*" field. As in cron, when both
+	// day fields are restricted a day matching either one runs.
+	anyDay, anyWeekday bool
+}
+
+// maxSearch bounds the search for the next run, since an expression like
+// "0 0 31 2 *" never matches
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+func (c cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for t.Before(limit) {
+		switch {
+		case !has(c.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(c.hours, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(c.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	day := has(c.days, t.Day())
+	weekday := has(c.weekdays, int(t.Weekday()))
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// parseField reads a comma separated list of values, ranges and steps
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			lo, err = parseValue(from, min, max)
+			if err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				hi, err = parseValue(to, min, max)
+				if err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("range %q is backwards", rangePart)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d is outside %d-%d", n, min, max)
+	}
+	return n, nil
+}
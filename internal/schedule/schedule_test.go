@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, time.January, 15, 10, 7, 30, 0, time.Local)
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "15m", want: from.Add(15 * time.Minute)},
+		{spec: "@every 2h", want: from.Add(2 * time.Hour)},
+		{spec: " 1h30m ", want: from.Add(90 * time.Minute)},
+		{spec: "30s", wantErr: true},
+		{spec: "soon", wantErr: true},
+		{spec: "", wantErr: true},
+
+		{spec: "* * * * *", want: time.Date(2025, time.January, 15, 10, 8, 0, 0, time.Local)},
+		{spec: "*/30 * * * *", want: time.Date(2025, time.January, 15, 10, 30, 0, 0, time.Local)},
+		{spec: "0 9 * * *", want: time.Date(2025, time.January, 16, 9, 0, 0, 0, time.Local)},
+		{spec: "5,45 10 * * *", want: time.Date(2025, time.January, 15, 10, 45, 0, 0, time.Local)},
+		{spec: "0 7-22/5 * * *", want: time.Date(2025, time.January, 15, 12, 0, 0, 0, time.Local)},
+		{spec: "0 8 * * 1-5", want: time.Date(2025, time.January, 16, 8, 0, 0, 0, time.Local)},
+		{spec: "0 8 * * 0", want: time.Date(2025, time.January, 19, 8, 0, 0, 0, time.Local)},
+		{spec: "0 8 * * 7", want: time.Date(2025, time.January, 19, 8, 0, 0, 0, time.Local)},
+		{spec: "0 0 1 * *", want: time.Date(2025, time.February, 1, 0, 0, 0, 0, time.Local)},
+		{spec: "0 0 29 2 *", want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.Local)},
+		// Either day field matches when both are restricted
+		{spec: "0 0 20 * 5", want: time.Date(2025, time.January, 17, 0, 0, 0, 0, time.Local)},
+		{spec: "0 0 31 2 *", want: time.Time{}},
+
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want an error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("store")
+
+// instrumented times the upserts of a store for metrics and traces them
+type instrumented struct {
+	Store
+}
+
+func (s instrumented) SaveEmails(ctx context.Context, emails []models.Email) (err error) {
+	defer metrics.DBUpsertDuration.ObserveSince(time.Now(), "emails")
+	ctx, span := tracer.Start(ctx, "store.SaveEmails", trace.WithAttributes(attribute.Int("store.emails", len(emails))))
+	defer func() { tracing.End(span, err) }()
+	return s.Store.SaveEmails(ctx, emails)
+}
+
+func (s instrumented) SaveEmailBatch(ctx context.Context, emails []models.Email, checkpoint models.SyncCheckpoint) (err error) {
+	defer metrics.DBUpsertDuration.ObserveSince(time.Now(), "emails")
+	ctx, span := tracer.Start(ctx, "store.SaveEmailBatch", trace.WithAttributes(attribute.Int("store.emails", len(emails))))
+	defer func() { tracing.End(span, err) }()
+	return s.Store.SaveEmailBatch(ctx, emails, checkpoint)
+}
+
+func (s instrumented) SaveLabels(ctx context.Context, labels []models.Label) (err error) {
+	defer metrics.DBUpsertDuration.ObserveSince(time.Now(), "labels")
+	ctx, span := tracer.Start(ctx, "store.SaveLabels", trace.WithAttributes(attribute.Int("store.labels", len(labels))))
+	defer func() { tracing.End(span, err) }()
+	return s.Store.SaveLabels(ctx, labels)
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/database"
+	"github.com/HoustonMiles/gmailScraper/internal/database/sqlite"
+)
+
+var (
+	_ Store = (*database.PostgresStore)(nil)
+	_ Store = (*sqlite.Store)(nil)
+)
+
+// Open connects to the backend named by the URL scheme and creates its
+// tables if they don't exist. postgres:// selects Postgres and sqlite://path
+// an embedded SQLite file. Upserts are timed for metrics.
+func Open(ctx context.Context, cfg config.DatabaseConfig) (Store, error) {
+	scheme, _, ok := strings.Cut(cfg.URL, "://")
+	if !ok {
+		return nil, fmt.Errorf("database URL has no scheme: %s", cfg.URL)
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		pool, err := database.InitDB(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := database.CreateTables(ctx, pool); err != nil {
+			pool.Close()
+			return nil, err
+		}
+		return instrumented{database.NewPostgresStore(pool)}, nil
+	case "sqlite":
+		path, err := sqlite.PathFromURL(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		db, err := sqlite.Open(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.CreateTables(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return instrumented{db}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme %q", scheme)
+	}
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// EmailStore saves, lists and deletes emails. sortBy is one of the
+// config.Sort* orders. Deleting moves emails to the trash, see TrashStore;
+// everything except a filter with Trash set leaves trashed emails out.
+type EmailStore interface {
+	SaveEmails(ctx context.Context, emails []models.Email) error
+	GetAllEmails(ctx context.Context, sortBy string) ([]models.Email, error)
+	// GetEmail returns models.ErrNotFound if there is no such email
+	GetEmail(ctx context.Context, emailID string) (models.Email, error)
+	GetEmailsBySender(ctx context.Context, sender string, sortBy string) ([]models.Email, error)
+	// ListEmails returns one page of emails and the cursor of the next
+	// page, nil on the last page
+	ListEmails(ctx context.Context, filter models.EmailFilter, sortBy string, after *models.EmailCursor, limit int) ([]models.Email, *models.EmailCursor, error)
+	// ListEmailIDs returns the IDs of every email matching filter
+	ListEmailIDs(ctx context.Context, filter models.EmailFilter) ([]string, error)
+	DeleteEmail(ctx context.Context, emailID string) error
+	DeleteEmails(ctx context.Context, emailIDs []string) error
+	DeleteEmailsBySender(ctx context.Context, sender string) error
+}
+
+// SenderStore lists the distinct senders
+type SenderStore interface {
+	GetAllSenders(ctx context.Context) ([]string, error)
+	// ListSenders returns one page of senders ordered by address, starting
+	// after the address after
+	ListSenders(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.SenderCount, error)
+	// ListDomains returns one page of registrable sender domains ordered
+	// by domain, starting after the domain after
+	ListDomains(ctx context.Context, filter models.EmailFilter, descending bool, after string, limit int) ([]models.DomainCount, error)
+}
+
+// LabelStore keeps Gmail label definitions and which emails carry them
+type LabelStore interface {
+	SaveLabels(ctx context.Context, labels []models.Label) error
+	GetAllLabels(ctx context.Context) ([]models.Label, error)
+	GetEmailsByLabel(ctx context.Context, labelID string, sortBy string) ([]models.Email, error)
+	// AddEmailLabel records that emails now carry a label
+	AddEmailLabel(ctx context.Context, emailIDs []string, labelID string) error
+	// RemoveEmailLabel records that emails no longer carry a label
+	RemoveEmailLabel(ctx context.Context, emailIDs []string, labelID string) error
+}
+
+// SyncStateStore records sync progress so interrupted syncs can resume
+type SyncStateStore interface {
+	// SaveEmailBatch saves emails and checkpoint atomically
+	SaveEmailBatch(ctx context.Context, emails []models.Email, checkpoint models.SyncCheckpoint) error
+	GetSyncCheckpoint(ctx context.Context) (models.SyncCheckpoint, error)
+	ClearSyncCheckpoint(ctx context.Context) error
+	// GetHistoryID returns the Gmail history ID the last sync reached, 0
+	// if no sync has completed
+	GetHistoryID(ctx context.Context) (uint64, error)
+	SaveHistoryID(ctx context.Context, historyID uint64) error
+}
+
+// SyncRunStore records sync runs and keeps two syncs, possibly in
+// different processes, from overlapping
+type SyncRunStore interface {
+	// TryLockSync takes or renews the sync lock for owner until ttl from
+	// now. It reports false if another owner holds it.
+	TryLockSync(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	UnlockSync(ctx context.Context, owner string) error
+	// StartSyncRun records a new run and sets its ID. Runs left
+	// unfinished by a process that died are marked interrupted.
+	StartSyncRun(ctx context.Context, run *models.SyncRun) error
+	FinishSyncRun(ctx context.Context, run models.SyncRun) error
+	// ListSyncRuns returns the limit most recent runs, newest first
+	ListSyncRuns(ctx context.Context, limit int) ([]models.SyncRun, error)
+}
+
+// SearchStore runs full text searches over sender, subject and body
+type SearchStore interface {
+	SearchEmails(ctx context.Context, query string, sortBy string) ([]models.Email, error)
+}
+
+// TrashStore manages emails that were deleted but not yet purged
+type TrashStore interface {
+	// RestoreEmails takes emails out of the trash and returns how many
+	// were in it
+	RestoreEmails(ctx context.Context, emailIDs []string) (int64, error)
+	// PurgeEmails permanently deletes emails that are in the trash and
+	// returns how many there were
+	PurgeEmails(ctx context.Context, emailIDs []string) (int64, error)
+	// PurgeTrashBefore permanently deletes emails trashed before before
+	// and returns how many there were
+	PurgeTrashBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// StatsStore summarizes emails by count and size, for the cleanup wizard
+// and the storage view. Trashed emails are left out.
+type StatsStore interface {
+	SummarizeEmails(ctx context.Context, filter models.EmailFilter) (models.EmailSummary, error)
+	// TopSenders returns the limit senders with the most emails matching
+	// filter, most first
+	TopSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error)
+	// LargestSenders returns the limit senders whose emails matching
+	// filter take the most space, largest first
+	LargestSenders(ctx context.Context, filter models.EmailFilter, limit int) ([]models.SenderCount, error)
+	// LargestEmails returns the limit largest emails matching filter
+	LargestEmails(ctx context.Context, filter models.EmailFilter, limit int) ([]models.Email, error)
+	SizeByLabel(ctx context.Context) ([]models.SizeGroup, error)
+	SizeByYear(ctx context.Context) ([]models.SizeGroup, error)
+	// ListUnsizedEmails returns the IDs of up to limit emails outside the
+	// trash with no size recorded, in ID order after the ID after
+	ListUnsizedEmails(ctx context.Context, after string, limit int) ([]string, error)
+	// SaveSizes records the size estimate and attachment names of emails
+	SaveSizes(ctx context.Context, emails []models.Email) error
+}
+
+// DedupStore finds emails saved more than once under different IDs, see
+// models.Email.Fingerprint. Trashed emails are left out.
+type DedupStore interface {
+	// ListDuplicateClusters returns up to limit fingerprints shared by
+	// several emails, most copies first
+	ListDuplicateClusters(ctx context.Context, limit int) ([]models.DuplicateCluster, error)
+	// GetDuplicates returns the emails with a fingerprint, oldest first
+	GetDuplicates(ctx context.Context, fingerprint string) ([]models.Email, error)
+}
+
+// ThreadStore groups emails into conversations
+type ThreadStore interface {
+	// ListThreads returns one page of threads, most recently active
+	// first, and the cursor of the next page, nil on the last page
+	ListThreads(ctx context.Context, filter models.EmailFilter, after *models.ThreadCursor, limit int) ([]models.Thread, *models.ThreadCursor, error)
+	// GetThread returns the emails of a thread, oldest first, either
+	// those in the trash or those that are not
+	GetThread(ctx context.Context, threadID string, trash bool) ([]models.Email, error)
+}
+
+// ActionStore is the outbox of changes to the mailbox. Every change meant
+// for Gmail is queued here first and sent by a worker, see
+// models.PendingAction for the states an action goes through.
+type ActionStore interface {
+	// QueueAction adds a pending action, due now, and sets its ID
+	QueueAction(ctx context.Context, action *models.PendingAction) error
+	// ListActions returns the actions in status, or all of them if status
+	// is empty, oldest first
+	ListActions(ctx context.Context, status string) ([]models.PendingAction, error)
+	// ClaimAction leases a pending action to the caller until ttl from
+	// now. It reports false if another worker holds it.
+	ClaimAction(ctx context.Context, id int64, ttl time.Duration) (bool, error)
+	// RecordActionFailure counts a failed attempt to send an action and
+	// releases it to be retried at retryAt
+	RecordActionFailure(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	// MarkActionSent records that Gmail accepted an action at sentAt
+	MarkActionSent(ctx context.Context, id int64, sentAt time.Time) error
+	// SetActionStatus moves an action to status with reason as its last
+	// error, and releases it
+	SetActionStatus(ctx context.Context, id int64, status, reason string) error
+	// RetryAction makes a failed or conflicting action pending again
+	RetryAction(ctx context.Context, id int64) error
+	DeleteAction(ctx context.Context, id int64) error
+	CountActions(ctx context.Context) (models.ActionCounts, error)
+}
+
+// HealthStore reports on the backend itself, for health checks and
+// metrics
+type HealthStore interface {
+	// Ping checks that the database answers
+	Ping(ctx context.Context) error
+	// CountRows returns the number of rows in each table
+	CountRows(ctx context.Context) (map[string]int64, error)
+}
+
+// Store is everything the UI, handlers and CLI need from a storage
+// backend
+type Store interface {
+	EmailStore
+	SenderStore
+	LabelStore
+	SyncStateStore
+	SyncRunStore
+	SearchStore
+	ThreadStore
+	TrashStore
+	StatsStore
+	DedupStore
+	ActionStore
+	HealthStore
+
+	Close()
+}
@@ -1,207 +1,558 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
-	"github.com/HoustonMiles/gmailScraper/internal/database"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
 	"github.com/HoustonMiles/gmailScraper/internal/ui/components"
 	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
-	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var logger = logging.For("ui")
+
+// sortLabels maps the sort dropdown entries to database sort orders
+var sortLabels = []struct {
+	label  string
+	sortBy string
+}{
+	{"Newest First", config.SortDateNewest},
+	{"Oldest First", config.SortDateOldest},
+	{"Sender A-Z", config.SortSenderAsc},
+	{"Sender Z-A", config.SortSenderDesc},
+}
+
+// Entries of the filter dropdown other than senders
+const (
+	allEmailsOption = "All Emails"
+	trashOption     = "Trash"
+)
+
+// trashPurgeInterval is how often emails past the trash retention are
+// purged while the app runs
+const trashPurgeInterval = time.Hour
+
+// outboxInterval is how often the outbox is checked for actions due to be
+// sent, besides whenever one is queued
+const outboxInterval = time.Minute
+
+// List groupings offered by the group dropdown
+const (
+	groupBySender = "Sender"
+	groupByDomain = "Domain"
+	groupByThread = "Thread"
 )
 
 type App struct {
 	fyneApp     fyne.App
 	mainWindow  fyne.Window
-	db          *pgxpool.Pool
+	db          store.Store
 	gmailClient *http.Client
-	
+	cfg         *config.Config
+	ctx         context.Context
+	cancel      context.CancelFunc
+
 	emailList   *components.EmailList
+	threadList  *components.ThreadList
 	emailView   *components.EmailView
-	senderList  *widget.Select
-	sortSelect  *widget.Select  // ADD THIS
+	senderList  *components.SenderPicker
+	labelSelect *widget.Select
+	sortSelect  *widget.Select
+	groupSelect *widget.Select
+	searchEntry *widget.Entry
+	palette     *components.CommandPalette
+	snackbar    *components.Snackbar
+	restoreBtn  *widget.Button
+	outboxBtn   *widget.Button
 	viewMode    string
-	sortBy      string  // ADD THIS
+	sortBy      string
+	groupBy     string
+	query       string
+	// labels backs the label filter, in its order
+	labels []models.Label
 }
 
-func NewApp(db *pgxpool.Pool, gmailClient *http.Client) *App {
+func NewApp(db store.Store, gmailClient *http.Client, cfg *config.Config) *App {
 	a := &App{
 		fyneApp:     app.New(),
 		db:          db,
 		gmailClient: gmailClient,
+		cfg:         cfg,
 		viewMode:    "all",
-		sortBy:      "date_newest",  // ADD THIS - default sort
+		sortBy:      cfg.UI.DefaultSort,
+		groupBy:     groupBySender,
 	}
-	
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+
 	a.mainWindow = a.fyneApp.NewWindow("Gmail Manager")
 	a.mainWindow.Resize(fyne.NewSize(1000, 600))
-	
+
 	a.setupUI()
-	
+	a.bindShortcuts()
+
 	return a
 }
 
 func (a *App) setupUI() {
 	// Create components
 	a.emailView = components.NewEmailView()
-	a.emailList = components.NewEmailList(a.db, a.emailView, a)
-	
-	// Load senders for dropdown
-	senders, err := database.GetAllSenders(a.db)
-	if err != nil {
-		log.Printf("Error loading senders: %v", err)
-		senders = []string{}
+	a.emailList = components.NewEmailList(a.ctx, a.db, a.emailView, a, a.sortBy)
+	a.emailList.OnDomainAction = a.showDomainActions
+	a.threadList = components.NewThreadList(a.ctx, a.db, a.emailView)
+	a.threadList.Container.Hide()
+	a.palette = components.NewCommandPalette(a.mainWindow)
+	a.snackbar = components.NewSnackbar()
+
+	a.searchEntry = widget.NewEntry()
+	a.searchEntry.SetPlaceHolder("Search mail (quoted phrases, OR, -exclude)")
+	a.searchEntry.OnSubmitted = func(query string) {
+		a.search(query)
+		a.mainWindow.Canvas().Unfocus()
 	}
-	
-	// Add "All Emails" option
-	senderOptions := append([]string{"All Emails"}, senders...)
-	
-	a.senderList = widget.NewSelect(senderOptions, func(selected string) {
-		if selected == "All Emails" {
+
+	// Sender picker, which searches the senders as they are needed
+	a.senderList = components.NewSenderPicker(a.ctx, a.db, a.mainWindow, []string{allEmailsOption, trashOption}, func(selected string) {
+		switch selected {
+		case allEmailsOption:
 			a.viewMode = "all"
-			a.emailList.LoadAllEmails(a.sortBy)
-		} else {
+		case trashOption:
+			a.viewMode = "trash"
+		default:
 			a.viewMode = "sender"
-			a.emailList.LoadEmailsBySender(selected, a.sortBy)
 		}
+		if a.restoreBtn != nil {
+			if a.viewMode == "trash" {
+				a.restoreBtn.Show()
+			} else {
+				a.restoreBtn.Hide()
+			}
+		}
+		a.loadList()
 	})
-	a.senderList.SetSelected("All Emails")
-	
+	a.senderList.SetSelected(allEmailsOption)
+
+	// Label dropdown narrows whichever sender or view is shown
+	a.labelSelect = widget.NewSelect(nil, func(string) { a.loadList() })
+	a.loadLabels()
+
 	// Sort dropdown
+	sortOptions := make([]string, 0, len(sortLabels))
+	initialSort := ""
+	for _, s := range sortLabels {
+		sortOptions = append(sortOptions, s.label)
+		if s.sortBy == a.sortBy {
+			initialSort = s.label
+		}
+	}
 	a.sortSelect = widget.NewSelect(
-		[]string{"Newest First", "Oldest First", "Sender A-Z", "Sender Z-A"},
+		sortOptions,
 		func(selected string) {
-			switch selected {
-			case "Newest First":
-				a.sortBy = "date_newest"
-			case "Oldest First":
-				a.sortBy = "date_oldest"
-			case "Sender A-Z":
-				a.sortBy = "sender_asc"
-			case "Sender Z-A":
-				a.sortBy = "sender_desc"
+			for _, s := range sortLabels {
+				if s.label == selected {
+					a.sortBy = s.sortBy
+				}
 			}
 			a.refreshView()
 		},
 	)
-	a.sortSelect.SetSelected("Newest First")
-	
-	// Create toolbar
+	a.sortSelect.SetSelected(initialSort)
+
+	// Group dropdown switches between the sender and domain trees and
+	// conversations
+	a.groupSelect = widget.NewSelect([]string{groupBySender, groupByDomain, groupByThread}, func(selected string) {
+		a.groupBy = selected
+		a.emailList.GroupByDomain(selected == groupByDomain)
+		if selected == groupByThread {
+			a.emailList.Container.Hide()
+			a.threadList.Container.Show()
+			a.sortSelect.Disable()
+		} else {
+			a.threadList.Container.Hide()
+			a.emailList.Container.Show()
+			a.sortSelect.Enable()
+		}
+		a.loadList()
+	})
+	a.groupSelect.SetSelected(a.groupBy)
+
+	// Create toolbar and selection bar
 	toolbar := a.createToolbar()
-	
-	// Wrap email list in scroll container
-	emailScroll := container.NewScroll(a.emailList.Container)
-	
-	// Create split view
+	selectionBar := a.createSelectionBar()
+
+	// Create split view. The email list scrolls itself.
 	split := container.NewHSplit(
-		emailScroll,         // CHANGED: wrap in scroll
+		container.NewStack(a.emailList.Container, a.threadList.Container),
 		a.emailView.Container,
 	)
 	split.SetOffset(0.4)
-	
+
 	// Main layout
 	content := container.NewBorder(
-		toolbar,  // top
-		nil,      // bottom
-		nil,      // left
-		nil,      // right
-		split,    // center
+		toolbar, // top
+		container.NewVBox(a.snackbar.Container, selectionBar), // bottom
+		nil,   // left
+		nil,   // right
+		split, // center
 	)
-	
+
 	a.mainWindow.SetContent(content)
 }
 
+func (a *App) createSelectionBar() *fyne.Container {
+	countLabel := widget.NewLabel("No emails selected")
+	a.emailList.Selection.OnChanged(func() {
+		switch n := a.emailList.Selection.Count(); n {
+		case 0:
+			countLabel.SetText("No emails selected")
+		case 1:
+			countLabel.SetText("1 email selected")
+		default:
+			countLabel.SetText(fmt.Sprintf("%d emails selected", n))
+		}
+	})
+
+	selectAllBtn := widget.NewButton("Select All Matching", func() {
+		a.emailList.SelectAllMatching()
+	})
+
+	clearBtn := widget.NewButton("Clear Selection", func() {
+		a.emailList.ClearSelection()
+	})
+
+	// Only offered in the Trash view
+	a.restoreBtn = widget.NewButton("Restore Selected", func() {
+		a.restoreSelected()
+	})
+	a.restoreBtn.Hide()
+
+	// Shown while changes wait for Gmail or were given up on
+	a.outboxBtn = widget.NewButton("", func() {
+		a.showOutbox()
+	})
+	a.outboxBtn.Hide()
+
+	return container.NewHBox(
+		countLabel,
+		selectAllBtn,
+		clearBtn,
+		a.restoreBtn,
+		a.outboxBtn,
+		widget.NewLabel("Shift-click a checkbox to select a range"),
+		widget.NewLabel(a.shortcutHelp()),
+	)
+}
+
 func (a *App) createToolbar() *fyne.Container {
 	// Sync button
 	syncBtn := widget.NewButton("Sync Emails", func() {
 		a.syncEmails()
 	})
-	
+
 	// Delete selected button
 	deleteBtn := widget.NewButton("Delete Selected", func() {
 		a.deleteSelected()
 	})
-	
+
+	// Archive, read state and star buttons
+	archiveBtn := widget.NewButton("Archive", func() {
+		a.archiveSelected()
+	})
+	readBtn := widget.NewButton("Mark Read", func() {
+		a.markRead()
+	})
+	unreadBtn := widget.NewButton("Mark Unread", func() {
+		a.markUnread()
+	})
+	starBtn := widget.NewButton("Star", func() {
+		a.toggleStar()
+	})
+
+	// Label selected button
+	labelsBtn := widget.NewButton("Label", func() {
+		a.showLabelDialog()
+	})
+
 	// Refresh button
 	refreshBtn := widget.NewButton("Refresh", func() {
 		a.refreshView()
 	})
-	
-	return container.NewHBox(
-		syncBtn,
-		deleteBtn,
-		refreshBtn,
-		widget.NewLabel("Filter:"),
-		a.senderList,
-		widget.NewLabel("Sort:"),  // ADD THIS
-		a.sortSelect,              // ADD THIS
+
+	// Cleanup wizard button
+	cleanupBtn := widget.NewButton("Clean Up", func() {
+		a.showCleanupWizard()
+	})
+
+	// Storage usage button
+	storageBtn := widget.NewButton("Storage", func() {
+		a.showStorageView()
+	})
+
+	// Duplicates button
+	duplicatesBtn := widget.NewButton("Duplicates", func() {
+		a.showDuplicatesView()
+	})
+
+	// Sync history button
+	historyBtn := widget.NewButton("History", func() {
+		a.showSyncRuns()
+	})
+
+	// Log viewer button
+	logsBtn := widget.NewButton("Logs", func() {
+		a.showLogs()
+	})
+
+	// The search box takes the remaining width
+	return container.NewBorder(nil, nil,
+		container.NewHBox(
+			syncBtn,
+			deleteBtn,
+			archiveBtn,
+			readBtn,
+			unreadBtn,
+			starBtn,
+			refreshBtn,
+			cleanupBtn,
+			storageBtn,
+			duplicatesBtn,
+			historyBtn,
+			logsBtn,
+			labelsBtn,
+			widget.NewLabel("Filter:"),
+			a.senderList.Button,
+			widget.NewLabel("Label:"),
+			a.labelSelect,
+			widget.NewLabel("Sort:"),
+			a.sortSelect,
+			widget.NewLabel("Group:"),
+			a.groupSelect,
+		),
+		nil,
+		a.searchEntry,
 	)
 }
 
 func (a *App) syncEmails() {
+	ctx, cancel := context.WithCancel(a.ctx)
+
 	// Show progress dialog
-	progress := dialog.NewProgressInfinite("Syncing", "Fetching emails from Gmail...", a.mainWindow)
+	progress := components.NewSyncProgressDialog(a.mainWindow, cancel)
 	progress.Show()
-	
+
 	go func() {
-		err := handlers.SyncEmails(a.gmailClient, a.db, 0) // Fetch 50 at a time
+		defer cancel()
+		run, err := handlers.RunSync(ctx, a.gmailClient, a.db, a.cfg.Gmail, models.SyncTriggerUI, nil, progress.Update)
 		progress.Hide()
-		
-		if err != nil {
-			dialog.ShowError(err, a.mainWindow)
-		} else {
-			dialog.ShowInformation("Success", "Emails synced successfully!", a.mainWindow)
-			a.refreshView()
-		}
+
+		fyne.Do(func() {
+			switch {
+			case errors.Is(err, handlers.ErrSyncRunning):
+				dialog.ShowInformation("Sync Running",
+					"Another sync, possibly from the daemon or the API, is already running. Try again once it has finished.", a.mainWindow)
+			case errors.Is(err, context.Canceled):
+				dialog.ShowInformation("Sync Cancelled",
+					fmt.Sprintf("Sync cancelled. %d emails fetched so far were saved.", run.Saved), a.mainWindow)
+				a.refreshView()
+			case err != nil:
+				dialog.ShowError(err, a.mainWindow)
+			default:
+				dialog.ShowInformation("Success", fmt.Sprintf("Emails synced successfully! %d emails saved.", run.Saved), a.mainWindow)
+				a.refreshView()
+			}
+		})
 	}()
 }
 
-func (a *App) deleteSelected() {
+// actionIDs returns the checked emails or, with nothing checked, the
+// email under the keyboard cursor
+func (a *App) actionIDs() []string {
 	selectedIDs := a.emailList.GetSelectedIDs()
+	if id := a.emailList.CurrentID(); len(selectedIDs) == 0 && id != "" && a.groupBy != groupByThread {
+		selectedIDs = []string{id}
+	}
+	return selectedIDs
+}
+
+func (a *App) deleteSelected() {
+	selectedIDs := a.actionIDs()
 	if len(selectedIDs) == 0 {
 		dialog.ShowInformation("No Selection", "Please select emails to delete", a.mainWindow)
 		return
 	}
-	
-	msg := fmt.Sprintf("Are you sure you want to delete %d email(s)?", len(selectedIDs))
-	dialog.ShowConfirm("Confirm Delete", msg, func(confirmed bool) {
-		if confirmed {
-			err := database.DeleteEmails(a.db, selectedIDs)
+
+	if a.viewMode == "trash" {
+		a.purgeSelected(selectedIDs)
+		return
+	}
+
+	a.confirmTrash(a.mainWindow, selectedIDs, fmt.Sprintf("Move %d email(s) to the trash?", len(selectedIDs)), nil)
+}
+
+// confirmTrash asks in parent before moving ids to the trash, offering to
+// trash them in Gmail too, and then offers to undo it. done, if not nil,
+// runs once they have been moved.
+func (a *App) confirmTrash(parent fyne.Window, ids []string, prompt string, done func()) {
+	msg := widget.NewLabel(prompt)
+	remote := widget.NewCheck("Also move them to the Gmail trash", nil)
+	dialog.ShowCustomConfirm("Confirm Delete", "Delete", "Cancel", container.NewVBox(msg, remote), func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		go func() {
+			batch, err := handlers.TrashEmails(a.ctx, a.db, ids, remote.Checked)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, parent)
+				}
+				if len(batch.IDs) > 0 {
+					a.emailList.ClearSelection()
+					a.snackbar.Show(fmt.Sprintf("Moved %d emails to the trash", len(batch.IDs)), func() {
+						a.undoTrash(batch)
+					})
+				}
+				a.refreshView()
+				if done != nil {
+					done()
+				}
+			})
+		}()
+	}, parent)
+}
+
+// undoTrash takes the last deleted batch back out of the trash, in Gmail
+// too if it was trashed there
+func (a *App) undoTrash(batch handlers.TrashedBatch) {
+	go func() {
+		err := handlers.UndoTrash(a.ctx, a.db, batch)
+		fyne.Do(func() {
 			if err != nil {
 				dialog.ShowError(err, a.mainWindow)
 			} else {
-				dialog.ShowInformation("Success", fmt.Sprintf("Deleted %d emails", len(selectedIDs)), a.mainWindow)
-				a.refreshView()
+				a.snackbar.Show(fmt.Sprintf("Restored %d emails", len(batch.IDs)), nil)
 			}
+			a.refreshView()
+		})
+	}()
+}
+
+// restoreSelected takes the selected emails out of the local trash
+func (a *App) restoreSelected() {
+	selectedIDs := a.emailList.GetSelectedIDs()
+	if len(selectedIDs) == 0 {
+		dialog.ShowInformation("No Selection", "Please select emails to restore", a.mainWindow)
+		return
+	}
+	a.restoreEmails(selectedIDs)
+}
+
+// restoreEmails takes emails out of the local trash in the background and
+// offers to undo it
+func (a *App) restoreEmails(ids []string) {
+	go func() {
+		restored, err := a.db.RestoreEmails(a.ctx, ids)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.mainWindow)
+				return
+			}
+			a.emailList.ClearSelection()
+			a.snackbar.Show(fmt.Sprintf("Restored %d emails", restored), func() { a.retrashEmails(ids) })
+			a.refreshView()
+		})
+	}()
+}
+
+// retrashEmails undoes restoreEmails
+func (a *App) retrashEmails(ids []string) {
+	go func() {
+		err := a.db.DeleteEmails(a.ctx, ids)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.mainWindow)
+			}
+			a.refreshView()
+		})
+	}()
+}
+
+// purgeSelected permanently deletes emails selected in the Trash view
+func (a *App) purgeSelected(ids []string) {
+	a.confirmPurge(ids, fmt.Sprintf("Permanently delete %d email(s)? This cannot be undone.", len(ids)))
+}
+
+// confirmPurge asks before permanently deleting ids from the trash
+func (a *App) confirmPurge(ids []string, msg string) {
+	dialog.ShowConfirm("Delete Forever", msg, func(confirmed bool) {
+		if !confirmed {
+			return
 		}
+		go func() {
+			_, err := a.db.PurgeEmails(a.ctx, ids)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, a.mainWindow)
+					return
+				}
+				a.emailList.ClearSelection()
+				a.refreshView()
+			})
+		}()
 	}, a.mainWindow)
 }
 
 func (a *App) refreshView() {
-	// Reload senders
-	senders, err := database.GetAllSenders(a.db)
-	if err != nil {
-		log.Printf("Error loading senders: %v", err)
-	} else {
-		senderOptions := append([]string{"All Emails"}, senders...)
-		a.senderList.Options = senderOptions
-		a.senderList.Refresh()
+	a.loadLabels()
+	a.refreshOutbox()
+
+	a.loadList()
+}
+
+// loadList reloads the visible list with the current filter and sort.
+// Threads are always ordered by last activity.
+func (a *App) loadList() {
+	// Dropdown callbacks fire while the UI is still being built; the
+	// group dropdown is set up last and loads the list
+	if a.groupSelect == nil {
+		return
+	}
+
+	filter := models.EmailFilter{Query: a.query, Trash: a.viewMode == "trash"}
+	if a.viewMode == "sender" {
+		filter.Sender = a.senderList.Selected
 	}
-	
-	// Reload email list with current sort
-	if a.viewMode == "all" {
-		a.emailList.LoadAllEmails(a.sortBy)
-	} else if a.senderList.Selected != "All Emails" {
-		a.emailList.LoadEmailsBySender(a.senderList.Selected, a.sortBy)
+	if label := a.labelByName(a.labelSelect.Selected); label != nil {
+		filter.Label = label.ID
 	}
+	if a.groupBy == groupByThread {
+		a.threadList.LoadThreads(filter)
+	} else {
+		a.emailList.LoadEmails(filter, a.sortBy)
+	}
+}
+
+// search narrows the list to emails matching query, "" shows everything
+func (a *App) search(query string) {
+	a.query = strings.TrimSpace(query)
+	a.searchEntry.SetText(a.query)
+	a.loadList()
 }
 
 func (a *App) Run() {
+	defer a.cancel()
+	go handlers.RunTrashRetention(a.ctx, a.db, a.cfg.Trash.RetentionDays, trashPurgeInterval)
+	go handlers.RunOutbox(a.ctx, a.gmailClient, a.db, outboxInterval)
+	go a.watchOutbox()
 	a.mainWindow.ShowAndRun()
 }
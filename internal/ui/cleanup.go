@@ -0,0 +1,245 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/components"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// cleanupActionLabels names the action button of each step
+var cleanupActionLabels = map[string]string{
+	handlers.CleanupTrash:       "Move Checked to Trash",
+	handlers.CleanupArchive:     "Archive Checked",
+	handlers.CleanupUnsubscribe: "Unsubscribe from Checked",
+}
+
+// cleanupWizard walks through the cleanup categories one step at a time
+// in its own window. Each step lists what it found, all checked, and
+// takes its action on the checked rows or is skipped. All fields are only
+// touched on the Fyne UI goroutine.
+type cleanupWizard struct {
+	app    *App
+	window fyne.Window
+	steps  []handlers.CleanupStep
+
+	step    int
+	items   []handlers.CleanupItem
+	checked []bool
+	results []handlers.CleanupResult
+
+	title     *widget.Label
+	summary   *widget.Label
+	list      *widget.List
+	actionBtn *widget.Button
+	skipBtn   *widget.Button
+	toggleBtn *widget.Button
+}
+
+// showCleanupWizard opens the cleanup wizard window
+func (a *App) showCleanupWizard() {
+	w := &cleanupWizard{
+		app:    a,
+		window: a.fyneApp.NewWindow("Mailbox Cleanup"),
+		steps:  handlers.CleanupSteps(a.cfg.Cleanup, time.Now()),
+	}
+
+	w.title = widget.NewLabel("")
+	w.title.TextStyle = fyne.TextStyle{Bold: true}
+	w.summary = widget.NewLabel("")
+	w.summary.Wrapping = fyne.TextWrapWord
+
+	w.list = widget.NewList(
+		func() int { return len(w.items) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("Template")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil), widget.NewLabel("0 B"), label)
+		},
+		w.updateRow,
+	)
+
+	w.actionBtn = widget.NewButton("", w.runStep)
+	w.actionBtn.Importance = widget.HighImportance
+	w.skipBtn = widget.NewButton("Skip", w.nextStep)
+	w.toggleBtn = widget.NewButton("Uncheck All", w.toggleAll)
+
+	w.window.SetContent(container.NewBorder(
+		container.NewVBox(w.title, w.summary),
+		container.NewHBox(w.toggleBtn, layout.NewSpacer(), w.skipBtn, w.actionBtn),
+		nil, nil,
+		w.list,
+	))
+	w.window.Resize(fyne.NewSize(700, 500))
+	w.window.Show()
+
+	w.loadStep()
+}
+
+func (w *cleanupWizard) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	label := c.Objects[0].(*widget.Label)
+	check := c.Objects[1].(*widget.Check)
+	size := c.Objects[2].(*widget.Label)
+
+	item := w.items[i]
+	if item.Count == 1 {
+		label.SetText(item.Title)
+	} else {
+		label.SetText(fmt.Sprintf("%s (%d emails)", item.Title, item.Count))
+	}
+	size.SetText(components.FormatSize(item.Size))
+
+	// Rows are recycled, so the check always reflects w.checked
+	check.OnChanged = nil
+	check.SetChecked(w.checked[i])
+	check.OnChanged = func(checked bool) {
+		w.checked[i] = checked
+	}
+}
+
+// loadStep shows the current step, loading its rows in the background
+func (w *cleanupWizard) loadStep() {
+	step := w.steps[w.step]
+	w.title.SetText(fmt.Sprintf("Step %d of %d: %s", w.step+1, len(w.steps), step.Title))
+	w.summary.SetText(step.Description + "\nLoading...")
+	w.items, w.checked = nil, nil
+	w.list.Refresh()
+	w.actionBtn.SetText(cleanupActionLabels[step.Action])
+	w.setBusy(true)
+
+	go func() {
+		items, summary, err := handlers.LoadCleanupItems(w.app.ctx, w.app.db, step)
+		fyne.Do(func() {
+			w.setBusy(false)
+			if err != nil {
+				dialog.ShowError(err, w.window)
+				return
+			}
+
+			w.items = items
+			w.checked = make([]bool, len(items))
+			for i := range w.checked {
+				w.checked[i] = true
+			}
+			w.toggleBtn.SetText("Uncheck All")
+			w.list.Refresh()
+
+			found := fmt.Sprintf("Found %d emails using %s.", summary.Count, components.FormatSize(summary.Size))
+			if len(items) == 0 {
+				found = "Nothing found."
+				w.actionBtn.Disable()
+			}
+			w.summary.SetText(step.Description + "\n" + found)
+		})
+	}()
+}
+
+func (w *cleanupWizard) setBusy(busy bool) {
+	for _, btn := range []*widget.Button{w.actionBtn, w.skipBtn, w.toggleBtn} {
+		if busy {
+			btn.Disable()
+		} else {
+			btn.Enable()
+		}
+	}
+}
+
+// toggleAll checks every row, or unchecks them all if all are checked
+func (w *cleanupWizard) toggleAll() {
+	all := true
+	for _, checked := range w.checked {
+		all = all && checked
+	}
+	for i := range w.checked {
+		w.checked[i] = !all
+	}
+	if all {
+		w.toggleBtn.SetText("Check All")
+	} else {
+		w.toggleBtn.SetText("Uncheck All")
+	}
+	w.list.Refresh()
+}
+
+// runStep takes the step's action on the checked rows and moves on
+func (w *cleanupWizard) runStep() {
+	step := w.steps[w.step]
+	var items []handlers.CleanupItem
+	for i, item := range w.items {
+		if w.checked[i] {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		w.nextStep()
+		return
+	}
+
+	w.setBusy(true)
+	w.summary.SetText(step.Description + "\nWorking...")
+	go func() {
+		result, links, err := handlers.RunCleanup(w.app.ctx, w.app.db, step, items, w.app.cfg.UI.OneClickUnsubscribe)
+		fyne.Do(func() {
+			w.setBusy(false)
+			w.results = append(w.results, result)
+			w.app.openLinks(links)
+			if err != nil {
+				dialog.ShowError(err, w.window)
+			}
+			w.app.refreshView()
+			w.nextStep()
+		})
+	}()
+}
+
+func (w *cleanupWizard) nextStep() {
+	w.step++
+	if w.step < len(w.steps) {
+		w.loadStep()
+		return
+	}
+	w.showSummary()
+}
+
+// showSummary replaces the steps with what was done and the space
+// reclaimed
+func (w *cleanupWizard) showSummary() {
+	var lines []string
+	var reclaimed int64
+	for _, r := range w.results {
+		switch r.Action {
+		case handlers.CleanupTrash:
+			lines = append(lines, fmt.Sprintf("%s: moved %d emails (%s) to the trash",
+				r.Step, r.Emails, components.FormatSize(r.Reclaimed)))
+		case handlers.CleanupArchive:
+			lines = append(lines, fmt.Sprintf("%s: archived %d emails", r.Step, r.Emails))
+		case handlers.CleanupUnsubscribe:
+			lines = append(lines, fmt.Sprintf("%s: unsubscribed from %d senders", r.Step, r.Senders))
+		}
+		reclaimed += r.Reclaimed
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Every step was skipped.")
+	}
+
+	title := widget.NewLabel(fmt.Sprintf("Space reclaimed: %s", components.FormatSize(reclaimed)))
+	title.TextStyle = fyne.TextStyle{Bold: true}
+	details := widget.NewLabel(strings.Join(lines, "\n") +
+		"\n\nGmail empties its trash after 30 days; empty it yourself to free the space now.")
+	details.Wrapping = fyne.TextWrapWord
+
+	w.window.SetContent(container.NewBorder(
+		title,
+		container.NewHBox(layout.NewSpacer(), widget.NewButton("Close", w.window.Close)),
+		nil, nil,
+		details,
+	))
+}
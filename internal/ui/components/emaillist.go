@@ -1,94 +1,675 @@
 package components
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"sort"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"github.com/HoustonMiles/gmailScraper/internal/database"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
 	"github.com/HoustonMiles/gmailScraper/internal/models"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
 )
 
+var logger = logging.For("ui")
+
+const (
+	// sendersPageSize is how many sender groups are loaded at a time
+	sendersPageSize = 200
+	// emailsPageSize is how many emails of an opened group are loaded at a time
+	emailsPageSize = 100
+)
+
+// Tree node IDs. The root is "", senders are branches and their emails
+// leaves. Grouped by domain, the root holds domain branches with the
+// senders under them. A "more" leaf at the end of a list loads the next
+// page when it scrolls into view.
+const (
+	domainPrefix            = "d:"
+	senderPrefix            = "s:"
+	emailPrefix             = "e:"
+	moreDomainsNode         = "md:"
+	moreSendersNode         = "ms:"
+	moreDomainSendersPrefix = "mds:"
+	moreEmailsPrefix        = "me:"
+	loadingNodePrefix       = "l:"
+)
+
+// senderGroup holds the loaded emails of one sender
+type senderGroup struct {
+	sender  models.SenderCount
+	nodes   []string
+	next    *models.EmailCursor
+	loaded  bool
+	loading bool
+	// ids holds every email ID of the group, including pages not loaded
+	// yet. It is nil until the group is selected as a whole.
+	ids []string
+}
+
+// domainGroup holds the loaded senders of one domain
+type domainGroup struct {
+	domain     models.DomainCount
+	nodes      []string
+	lastSender string
+	more       bool
+	loaded     bool
+	loading    bool
+	// ids holds every email ID of the domain, nil until the domain is
+	// selected as a whole
+	ids []string
+}
+
+// EmailList shows emails grouped by sender in a single virtualized tree.
+// Senders and each sender's emails are loaded a page at a time as they are
+// needed, so only what is on screen or was scrolled past is kept in memory.
+// All fields are only touched on the Fyne UI goroutine.
 type EmailList struct {
-	Container    *fyne.Container
-	db           *pgxpool.Pool
-	emailView    *EmailView
-	senderGroups []*SenderGroup
-	app          interface{}
+	Container *fyne.Container
+	Selection *Selection
+	ctx       context.Context
+	db        store.Store
+	emailView *EmailView
+	app       interface{}
+	tree      *keyTree
+
+	filter   models.EmailFilter
+	sortBy   string
+	byDomain bool
+
+	// OnDomainAction is called when the actions button of a domain is
+	// tapped, with the button to anchor a menu to
+	OnDomainAction func(domain models.DomainCount, button fyne.CanvasObject)
+	// Keys runs the app's shortcuts while the tree or a checkbox has focus
+	Keys KeyHandler
+
+	// generation is bumped on every reload so late page loads from a
+	// previous query are dropped
+	generation  int
+	rootNodes   []string
+	domains     map[string]*domainGroup
+	groups      map[string]*senderGroup
+	lastRoot    string
+	moreRoot    bool
+	loadingRoot bool
+	emails      map[string]models.Email
+
+	// anchor is the email last clicked; a shift-click selects everything
+	// from it to the clicked email
+	anchor string
+	// current is the node the keyboard cursor is on, an email or a closed
+	// sender group
+	current string
 }
 
-func NewEmailList(db *pgxpool.Pool, emailView *EmailView, app interface{}) *EmailList {
+func NewEmailList(ctx context.Context, db store.Store, emailView *EmailView, app interface{}, sortBy string) *EmailList {
 	el := &EmailList{
-		db:           db,
-		emailView:    emailView,
-		senderGroups: []*SenderGroup{},
-		app:          app,
+		ctx:       ctx,
+		db:        db,
+		emailView: emailView,
+		app:       app,
+		Selection: NewSelection(),
+	}
+
+	el.tree = newKeyTree(&el.Keys, el.childNodes, el.isBranch, el.createNode, el.updateNode)
+	el.tree.OnSelected = el.nodeSelected
+	el.tree.OnBranchOpened = func(node string) {
+		if strings.HasPrefix(node, domainPrefix) {
+			el.loadDomain(node)
+		} else {
+			el.loadGroup(node)
+		}
 	}
 
-	el.Container = container.NewVBox()
-	el.LoadAllEmails("date_newest")
+	el.Selection.OnChanged(el.tree.Refresh)
+
+	el.Container = container.NewStack(el.tree)
+	el.LoadAllEmails(sortBy)
 
 	return el
 }
 
 func (el *EmailList) LoadAllEmails(sortBy string) {
-	emails, err := database.GetAllEmails(el.db, sortBy)
-	if err != nil {
-		log.Printf("Error loading emails: %v", err)
+	el.reload(models.EmailFilter{}, sortBy)
+}
+
+func (el *EmailList) LoadEmailsBySender(sender string, sortBy string) {
+	el.reload(models.EmailFilter{Sender: sender}, sortBy)
+}
+
+// LoadEmails shows the emails matching filter, e.g. a search
+func (el *EmailList) LoadEmails(filter models.EmailFilter, sortBy string) {
+	el.reload(filter, sortBy)
+}
+
+// GroupByDomain switches between grouping by sender and by sender domain
+// with the senders under it. It takes effect on the next load.
+func (el *EmailList) GroupByDomain(byDomain bool) {
+	el.byDomain = byDomain
+}
+
+// reload drops everything loaded and starts again from the first page
+func (el *EmailList) reload(filter models.EmailFilter, sortBy string) {
+	el.generation++
+	el.filter = filter
+	el.sortBy = sortBy
+	el.rootNodes = nil
+	el.domains = make(map[string]*domainGroup)
+	el.groups = make(map[string]*senderGroup)
+	el.emails = make(map[string]models.Email)
+	el.lastRoot = ""
+	el.moreRoot = true
+	el.loadingRoot = false
+	el.anchor = ""
+	el.current = ""
+
+	el.tree.UnselectAll()
+	el.tree.CloseAllBranches()
+	el.loadMoreRoot()
+}
+
+// loadMoreRoot fetches the next page of sender or domain groups in the
+// background
+func (el *EmailList) loadMoreRoot() {
+	if el.loadingRoot || !el.moreRoot {
 		return
 	}
+	el.loadingRoot = true
+
+	generation := el.generation
+	filter, after := el.filter, el.lastRoot
+	descending := el.sortBy == config.SortSenderDesc
+	byDomain := el.byDomain
 
-	el.groupAndDisplay(emails)
+	go func() {
+		var senders []models.SenderCount
+		var domains []models.DomainCount
+		var err error
+		if byDomain {
+			domains, err = el.db.ListDomains(el.ctx, filter, descending, after, sendersPageSize)
+		} else {
+			senders, err = el.db.ListSenders(el.ctx, filter, descending, after, sendersPageSize)
+		}
+		fyne.Do(func() {
+			if generation != el.generation {
+				return
+			}
+			el.loadingRoot = false
+			if err != nil {
+				logger.Error("Unable to load senders", "err", err)
+				el.moreRoot = false
+				el.refreshRoot()
+				return
+			}
+
+			for _, sender := range senders {
+				el.groups[senderPrefix+sender.Address] = &senderGroup{sender: sender}
+				el.lastRoot = sender.Address
+			}
+			for _, domain := range domains {
+				el.domains[domainPrefix+domain.Domain] = &domainGroup{domain: domain, more: true}
+				el.lastRoot = domain.Domain
+			}
+			el.moreRoot = len(senders)+len(domains) == sendersPageSize
+			el.refreshRoot()
+		})
+	}()
 }
 
-func (el *EmailList) LoadEmailsBySender(sender string, sortBy string) {
-	emails, err := database.GetEmailsByFrom(el.db, sender, sortBy)
-	if err != nil {
-		log.Printf("Error loading emails: %v", err)
+func (el *EmailList) refreshRoot() {
+	var nodes []string
+	if el.byDomain {
+		for node := range el.domains {
+			nodes = append(nodes, node)
+		}
+	} else {
+		for node := range el.groups {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	if el.sortBy == config.SortSenderDesc {
+		sort.Sort(sort.Reverse(sort.StringSlice(nodes)))
+	}
+	if el.moreRoot {
+		if el.byDomain {
+			nodes = append(nodes, moreDomainsNode)
+		} else {
+			nodes = append(nodes, moreSendersNode)
+		}
+	}
+	el.rootNodes = nodes
+	el.tree.Refresh()
+}
+
+// loadDomain fetches the next page of senders of a domain group in the
+// background
+func (el *EmailList) loadDomain(node string) {
+	domain, ok := el.domains[node]
+	if !ok || domain.loading || !domain.more {
 		return
 	}
+	domain.loading = true
 
-	el.groupAndDisplay(emails)
+	generation := el.generation
+	filter := el.filter
+	filter.Domain = domain.domain.Domain
+	after := domain.lastSender
+	descending := el.sortBy == config.SortSenderDesc
+
+	go func() {
+		senders, err := el.db.ListSenders(el.ctx, filter, descending, after, sendersPageSize)
+		fyne.Do(func() {
+			if generation != el.generation {
+				return
+			}
+			domain.loading = false
+			domain.loaded = true
+			if err != nil {
+				logger.Error("Unable to load senders of domain", "domain", domain.domain.Domain, "err", err)
+				domain.more = false
+				el.tree.Refresh()
+				return
+			}
+
+			for _, sender := range senders {
+				senderNode := senderPrefix + sender.Address
+				el.groups[senderNode] = &senderGroup{sender: sender}
+				domain.nodes = append(domain.nodes, senderNode)
+				domain.lastSender = sender.Address
+			}
+			domain.more = len(senders) == sendersPageSize
+			el.tree.Refresh()
+		})
+	}()
 }
 
-func (el *EmailList) groupAndDisplay(emails []models.Email) {
-	// Group emails by sender
-	grouped := make(map[string][]models.Email)
-	for _, email := range emails {
-		grouped[email.From] = append(grouped[email.From], email)
+// loadGroup fetches the next page of emails of a sender group in the
+// background
+func (el *EmailList) loadGroup(node string) {
+	group, ok := el.groups[node]
+	if !ok || group.loading || (group.loaded && group.next == nil) {
+		return
 	}
+	group.loading = true
+
+	generation := el.generation
+	filter := el.filter
+	filter.Sender = group.sender.Address
+	sortBy, after := el.sortBy, group.next
+
+	go func() {
+		emails, next, err := el.db.ListEmails(el.ctx, filter, sortBy, after, emailsPageSize)
+		fyne.Do(func() {
+			if generation != el.generation {
+				return
+			}
+			group.loading = false
+			group.loaded = true
+			if err != nil {
+				logger.Error("Unable to load emails from sender", "sender", group.sender.Address, "err", err)
+				group.next = nil
+				el.tree.Refresh()
+				return
+			}
+
+			for _, email := range emails {
+				el.emails[email.ID] = email
+				group.nodes = append(group.nodes, emailPrefix+email.ID)
+			}
+			group.next = next
+			el.tree.Refresh()
+		})
+	}()
+}
 
-	// Clear existing groups
-	el.senderGroups = []*SenderGroup{}
-	el.Container.Objects = []fyne.CanvasObject{}
+func (el *EmailList) childNodes(node string) []string {
+	if node == "" {
+		return el.rootNodes
+	}
 
-	// Sort senders alphabetically
-	senders := make([]string, 0, len(grouped))
-	for sender := range grouped {
-		senders = append(senders, sender)
+	if domain, ok := el.domains[node]; ok {
+		if !domain.loaded {
+			el.loadDomain(node)
+			return []string{loadingNodePrefix + node}
+		}
+		if domain.more {
+			return append(domain.nodes[:len(domain.nodes):len(domain.nodes)], moreDomainSendersPrefix+node)
+		}
+		return domain.nodes
 	}
-	sort.Strings(senders)
 
-	// Create a sender group for each sender
-	for _, sender := range senders {
-		senderEmails := grouped[sender]
-		group := NewSenderGroup(sender, senderEmails, el.emailView)
-		el.senderGroups = append(el.senderGroups, group)
-		
-		el.Container.Add(group.Container)
-		el.Container.Add(widget.NewSeparator())
+	group, ok := el.groups[node]
+	if !ok {
+		return nil
+	}
+	if !group.loaded {
+		el.loadGroup(node)
+		return []string{loadingNodePrefix + node}
 	}
+	if group.next != nil {
+		return append(group.nodes[:len(group.nodes):len(group.nodes)], moreEmailsPrefix+node)
+	}
+	return group.nodes
+}
 
-	el.Container.Refresh()
+func (el *EmailList) isBranch(node string) bool {
+	return node == "" || strings.HasPrefix(node, senderPrefix) || strings.HasPrefix(node, domainPrefix)
 }
 
-func (el *EmailList) GetSelectedIDs() []string {
-	var allSelected []string
-	for _, group := range el.senderGroups {
-		allSelected = append(allSelected, group.GetSelectedIDs()...)
+func (el *EmailList) createNode(branch bool) fyne.CanvasObject {
+	check := newRangeCheck(&el.Keys)
+	label := widget.NewLabel("Template")
+	label.Truncation = fyne.TextTruncateEllipsis
+	actions := widget.NewButtonWithIcon("", theme.MoreHorizontalIcon(), nil)
+	actions.Importance = widget.LowImportance
+	actions.Hide()
+	return container.NewBorder(nil, nil, check, actions, label)
+}
+
+func (el *EmailList) updateNode(node string, branch bool, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	label := c.Objects[0].(*widget.Label)
+	check := c.Objects[1].(*rangeCheck)
+	actions := c.Objects[2].(*widget.Button)
+
+	// Rows are recycled, so the checkbox always reflects the Selection
+	// rather than holding state of its own
+	check.OnChanged = nil
+	actions.Hide()
+	label.TextStyle = fyne.TextStyle{}
+
+	switch {
+	case strings.HasPrefix(node, domainPrefix):
+		domain, ok := el.domains[node]
+		if !ok {
+			return
+		}
+		label.SetText(fmt.Sprintf("%s (%d emails from %d addresses)",
+			domain.domain.Domain, domain.domain.Count, domain.domain.Senders))
+		check.SetChecked(el.Selection.ContainsAll(domain.ids))
+		check.OnChanged = func(checked bool) {
+			el.selectDomain(node, checked)
+		}
+		if el.OnDomainAction != nil {
+			actions.OnTapped = func() {
+				el.OnDomainAction(domain.domain, actions)
+			}
+			actions.Show()
+		}
+	case branch:
+		group, ok := el.groups[node]
+		if !ok {
+			return
+		}
+		label.SetText(fmt.Sprintf("%s (%d emails)", group.sender.Address, group.sender.Count))
+		check.SetChecked(el.Selection.ContainsAll(group.ids))
+		check.OnChanged = func(checked bool) {
+			el.selectGroup(node, checked)
+		}
+	case strings.HasPrefix(node, emailPrefix):
+		id := strings.TrimPrefix(node, emailPrefix)
+		email := el.emails[id]
+		subject := email.Subject
+		if email.HasLabel("STARRED") {
+			subject = "★ " + subject
+		}
+		label.TextStyle.Bold = email.HasLabel("UNREAD")
+		label.SetText(subject)
+		check.Show()
+		check.SetChecked(el.Selection.Contains(id))
+		check.OnChanged = func(checked bool) {
+			el.selectEmail(id, checked, check.shift)
+		}
+	case node == moreSendersNode, node == moreDomainsNode:
+		label.SetText("Loading more senders...")
+		check.Hide()
+		el.loadMoreRoot()
+	case strings.HasPrefix(node, moreDomainSendersPrefix):
+		label.SetText("Loading more senders...")
+		check.Hide()
+		el.loadDomain(strings.TrimPrefix(node, moreDomainSendersPrefix))
+	case strings.HasPrefix(node, moreEmailsPrefix):
+		label.SetText("Loading more emails...")
+		check.Hide()
+		el.loadGroup(strings.TrimPrefix(node, moreEmailsPrefix))
+	default:
+		label.SetText("Loading...")
+		check.Hide()
+	}
+}
+
+func (el *EmailList) nodeSelected(node string) {
+	if !strings.HasPrefix(node, emailPrefix) {
+		el.tree.Unselect(node)
+		return
+	}
+	el.current = node
+	if email, ok := el.emails[strings.TrimPrefix(node, emailPrefix)]; ok {
+		el.emailView.ShowEmail(email)
 	}
-	return allSelected
+}
+
+// visibleNodes returns the loaded groups and emails in display order,
+// leaving out the contents of closed groups
+func (el *EmailList) visibleNodes() []string {
+	var visible []string
+	var walk func(nodes []string)
+	walk = func(nodes []string) {
+		for _, node := range nodes {
+			if domain, ok := el.domains[node]; ok {
+				visible = append(visible, node)
+				if el.tree.IsBranchOpen(node) {
+					walk(domain.nodes)
+				}
+			} else if group, ok := el.groups[node]; ok {
+				visible = append(visible, node)
+				if el.tree.IsBranchOpen(node) {
+					visible = append(visible, group.nodes...)
+				}
+			}
+		}
+	}
+	walk(el.rootNodes)
+	return visible
+}
+
+// Move moves the keyboard cursor delta emails down, or up if negative,
+// and opens the email it lands on. Moving down onto a closed group opens
+// the group and stops on it; moving up skips closed groups.
+func (el *EmailList) Move(delta int) {
+	visible := el.visibleNodes()
+
+	i := -1
+	for j, node := range visible {
+		if node == el.current {
+			i = j
+			break
+		}
+	}
+	step := 1
+	if delta < 0 {
+		step, delta = -1, -delta
+	}
+	if i < 0 && step < 0 {
+		i = len(visible)
+	}
+
+	for i += step; i >= 0 && i < len(visible); i += step {
+		node := visible[i]
+		if strings.HasPrefix(node, emailPrefix) {
+			if delta--; delta > 0 {
+				continue
+			}
+			el.tree.Select(node)
+			el.tree.ScrollTo(node)
+			return
+		}
+		if step > 0 && !el.tree.IsBranchOpen(node) {
+			el.tree.UnselectAll()
+			el.current = node
+			el.tree.OpenBranch(node)
+			el.tree.ScrollTo(node)
+			return
+		}
+	}
+}
+
+// ToggleCurrent checks or unchecks the email under the keyboard cursor
+func (el *EmailList) ToggleCurrent() {
+	id := el.CurrentID()
+	if id == "" {
+		return
+	}
+	el.selectEmail(id, !el.Selection.Contains(id), false)
+}
+
+// CurrentID returns the ID of the email under the keyboard cursor, ""
+// if the cursor is not on an email
+func (el *EmailList) CurrentID() string {
+	if !strings.HasPrefix(el.current, emailPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(el.current, emailPrefix)
+}
+
+// selectEmail handles a click on an email checkbox. A shift-click applies
+// to every visible email between the previous click and this one.
+func (el *EmailList) selectEmail(id string, checked bool, extend bool) {
+	if extend && el.anchor != "" {
+		el.Selection.SetAll(el.visibleRange(el.anchor, id), checked)
+	} else {
+		el.Selection.Set(id, checked)
+	}
+	el.anchor = id
+}
+
+// visibleRange returns the IDs of the emails shown from a to b inclusive,
+// in either direction. If either is no longer shown only b is returned.
+func (el *EmailList) visibleRange(a, b string) []string {
+	var visible []string
+	for _, node := range el.visibleNodes() {
+		if strings.HasPrefix(node, emailPrefix) {
+			visible = append(visible, strings.TrimPrefix(node, emailPrefix))
+		}
+	}
+
+	start, end := -1, -1
+	for i, id := range visible {
+		if id == a {
+			start = i
+		}
+		if id == b {
+			end = i
+		}
+	}
+	if start < 0 || end < 0 {
+		return []string{b}
+	}
+	if start > end {
+		start, end = end, start
+	}
+	return visible[start : end+1]
+}
+
+// selectGroup selects or deselects every email of a sender group,
+// including pages that were never loaded
+func (el *EmailList) selectGroup(node string, checked bool) {
+	group, ok := el.groups[node]
+	if !ok {
+		return
+	}
+	if group.ids != nil {
+		el.Selection.SetAll(group.ids, checked)
+		return
+	}
+
+	generation := el.generation
+	filter := el.filter
+	filter.Sender = group.sender.Address
+	el.loadIDs(filter, func(ids []string) {
+		if generation != el.generation {
+			return
+		}
+		group.ids = ids
+		el.Selection.SetAll(ids, checked)
+	})
+}
+
+// selectDomain selects or deselects every email of a domain group,
+// including senders that were never loaded
+func (el *EmailList) selectDomain(node string, checked bool) {
+	domain, ok := el.domains[node]
+	if !ok {
+		return
+	}
+	if domain.ids != nil {
+		el.Selection.SetAll(domain.ids, checked)
+		return
+	}
+
+	generation := el.generation
+	el.loadIDs(el.DomainFilter(domain.domain.Domain), func(ids []string) {
+		if generation != el.generation {
+			return
+		}
+		domain.ids = ids
+		el.Selection.SetAll(ids, checked)
+	})
+}
+
+// DomainFilter returns the current filter narrowed to one sender domain
+func (el *EmailList) DomainFilter(domain string) models.EmailFilter {
+	filter := el.filter
+	filter.Domain = domain
+	return filter
+}
+
+// SelectAllMatching selects every email matching the current filter,
+// loaded or not
+func (el *EmailList) SelectAllMatching() {
+	el.loadIDs(el.filter, func(ids []string) {
+		el.Selection.SetAll(ids, true)
+	})
+}
+
+// ClearSelection deselects everything
+func (el *EmailList) ClearSelection() {
+	el.Selection.Clear()
+	el.anchor = ""
+}
+
+// loadIDs fetches the IDs matching filter in the background and passes
+// them to apply on the UI goroutine
+func (el *EmailList) loadIDs(filter models.EmailFilter, apply func([]string)) {
+	go func() {
+		ids, err := el.db.ListEmailIDs(el.ctx, filter)
+		fyne.Do(func() {
+			if err != nil {
+				logger.Error("Unable to load email IDs", "err", err)
+				return
+			}
+			apply(ids)
+		})
+	}()
+}
+
+// AllHaveLabel reports whether every one of ids that is loaded carries
+// the label id
+func (el *EmailList) AllHaveLabel(ids []string, labelID string) bool {
+	for _, id := range ids {
+		if email, ok := el.emails[id]; ok && !email.HasLabel(labelID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (el *EmailList) GetSelectedIDs() []string {
+	return el.Selection.IDs()
 }
@@ -1,6 +1,9 @@
 package components
 
 import (
+	"fmt"
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
@@ -12,30 +15,53 @@ type EmailView struct {
 	fromLabel *widget.Label
 	dateLabel *widget.Label
 	subject   *widget.Label
-	body      *widget.Label
+	body      *fyne.Container
+	scroll    *container.Scroll
+
+	// Toggles for the current email. Remote images are blocked again
+	// whenever another email is shown.
+	loadImages  *widget.Check
+	showSource  *widget.Check
+	showHeaders *widget.Check
+	imagesNote  *widget.Label
+
+	// emails holds the email shown, or every message of a conversation
+	emails  []models.Email
+	showing bool
 }
 
 func NewEmailView() *EmailView {
 	ev := &EmailView{
-		fromLabel: widget.NewLabel("From: "),
-		dateLabel: widget.NewLabel("Date: "),
-		subject:   widget.NewLabel("Subject: "),
-		body:      widget.NewLabel("Select an email to view"),
+		fromLabel:  widget.NewLabel("From: "),
+		dateLabel:  widget.NewLabel("Date: "),
+		subject:    widget.NewLabel("Subject: "),
+		body:       container.NewVBox(wrappedLabel("Select an email to view")),
+		imagesNote: widget.NewLabel(""),
 	}
-	
-	ev.body.Wrapping = fyne.TextWrapWord
-	
+
+	ev.loadImages = widget.NewCheck("Load images", func(bool) { ev.render() })
+	ev.showSource = widget.NewCheck("View source", func(bool) { ev.render() })
+	ev.showHeaders = widget.NewCheck("Show headers", func(bool) { ev.render() })
+	ev.loadImages.Disable()
+	ev.showSource.Disable()
+	ev.showHeaders.Disable()
+	ev.imagesNote.Importance = widget.LowImportance
+	ev.imagesNote.Hide()
+
+	ev.scroll = container.NewScroll(ev.body)
+
 	ev.Container = container.NewBorder(
 		container.NewVBox(
 			ev.fromLabel,
 			ev.dateLabel,
 			ev.subject,
+			container.NewHBox(ev.loadImages, ev.showSource, ev.showHeaders, ev.imagesNote),
 			widget.NewSeparator(),
 		),
 		nil, nil, nil,
-		container.NewScroll(ev.body),
+		ev.scroll,
 	)
-	
+
 	return ev
 }
 
@@ -43,5 +69,116 @@ func (ev *EmailView) ShowEmail(email models.Email) {
 	ev.fromLabel.SetText("From: " + email.From)
 	ev.dateLabel.SetText("Date: " + email.Date)
 	ev.subject.SetText("Subject: " + email.Subject)
-	ev.body.SetText(email.Body)
+	ev.show([]models.Email{email})
+}
+
+// ShowThread shows every message of a conversation, oldest first, with
+// quoted text collapsed
+func (ev *EmailView) ShowThread(thread models.Thread, emails []models.Email) {
+	ev.fromLabel.SetText("Participants: " + strings.Join(thread.Participants, ", "))
+	ev.dateLabel.SetText("Last activity: " + formatActivity(thread.LastActivity))
+	ev.subject.SetText(fmt.Sprintf("Subject: %s (%d messages)", thread.Subject, len(emails)))
+	ev.show(emails)
+}
+
+func (ev *EmailView) show(emails []models.Email) {
+	ev.emails = emails
+	ev.showing = false
+	ev.loadImages.SetChecked(false)
+	ev.showSource.SetChecked(false)
+	ev.showHeaders.SetChecked(false)
+	ev.loadImages.Disable()
+	ev.showSource.Enable()
+	ev.showHeaders.Disable()
+	for _, email := range emails {
+		if email.HTMLBody != "" {
+			ev.loadImages.Enable()
+		}
+		if email.Headers != "" {
+			ev.showHeaders.Enable()
+		}
+	}
+
+	ev.showing = true
+	ev.render()
+	ev.scroll.ScrollToTop()
+}
+
+// render fills the body for the current emails and toggles
+func (ev *EmailView) render() {
+	if !ev.showing {
+		return
+	}
+	conversation := len(ev.emails) > 1
+
+	var objects []fyne.CanvasObject
+	blocked := 0
+	for i, email := range ev.emails {
+		if conversation {
+			if i > 0 {
+				objects = append(objects, widget.NewSeparator())
+			}
+			heading := widget.NewLabel(fmt.Sprintf("%s — %s", email.From, email.Date))
+			heading.TextStyle = fyne.TextStyle{Bold: true}
+			heading.Truncation = fyne.TextTruncateEllipsis
+			objects = append(objects, heading)
+		}
+
+		if ev.showHeaders.Checked {
+			objects = append(objects, monospaceLabel(email.Headers), widget.NewSeparator())
+		}
+
+		switch {
+		case ev.showSource.Checked && email.HTMLBody != "":
+			objects = append(objects, monospaceLabel(email.HTMLBody))
+		case ev.showSource.Checked:
+			objects = append(objects, monospaceLabel(email.Body))
+		case email.HTMLBody != "":
+			rendered := RenderHTML(email.HTMLBody, HTMLOptions{
+				LoadImages:     ev.loadImages.Checked,
+				CollapseQuotes: conversation,
+			})
+			objects = append(objects, rendered.Objects...)
+			blocked += rendered.BlockedImages
+		case conversation:
+			text, quoted := splitQuoted(email.Body)
+			objects = append(objects, wrappedLabel(text))
+			if quoted != "" {
+				objects = append(objects, collapsedQuote(QuoteBlock(wrappedLabel(quoted))))
+			}
+		default:
+			objects = append(objects, wrappedLabel(email.Body))
+		}
+	}
+
+	if blocked > 0 {
+		ev.imagesNote.SetText(fmt.Sprintf("%d remote images blocked", blocked))
+		ev.imagesNote.Show()
+	} else {
+		ev.imagesNote.Hide()
+	}
+
+	ev.body.Objects = objects
+	ev.body.Refresh()
+}
+
+// splitQuoted splits a plain text reply into the new text and the quoted
+// message below it: the first run of "> " lines and everything after,
+// including an "On ... wrote:" line introducing it
+func splitQuoted(body string) (text, quoted string) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		start := i
+		for start > 0 && strings.TrimSpace(lines[start-1]) == "" {
+			start--
+		}
+		if start > 0 && strings.HasSuffix(strings.TrimSpace(lines[start-1]), "wrote:") {
+			start--
+		}
+		return strings.TrimRight(strings.Join(lines[:start], "\n"), "\n"), strings.Join(lines[start:], "\n")
+	}
+	return body, ""
 }
@@ -0,0 +1,510 @@
+package components
+
+import (
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLOptions controls how RenderHTML treats an email body
+type HTMLOptions struct {
+	// LoadImages allows remote images to be fetched. When false they are
+	// replaced by a placeholder, so opening an email cannot report back to
+	// the sender.
+	LoadImages bool
+	// CollapseQuotes hides quoted text behind an expander, for messages
+	// shown as part of a conversation
+	CollapseQuotes bool
+}
+
+// RenderedHTML is an HTML body converted to Fyne objects
+type RenderedHTML struct {
+	Objects []fyne.CanvasObject
+	// BlockedImages is the number of remote images that were not loaded
+	BlockedImages int
+}
+
+// RenderHTML converts an HTML email body into rich text. Nothing in the
+// source is executed: scripts, styles, forms and embedded content are
+// dropped, and only http, https and mailto links are kept.
+func RenderHTML(src string, opts HTMLOptions) RenderedHTML {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return RenderedHTML{Objects: []fyne.CanvasObject{wrappedLabel(src)}}
+	}
+
+	var blocked int
+	r := &htmlRenderer{opts: opts, blocked: &blocked, space: true}
+	r.walkChildren(doc)
+	return RenderedHTML{Objects: r.finish(), BlockedImages: blocked}
+}
+
+// droppedElements are never rendered, nor is anything inside them
+var droppedElements = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Title:    true,
+	atom.Meta:     true,
+	atom.Link:     true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Audio:    true,
+	atom.Video:    true,
+}
+
+// blockElements start and end on a line of their own
+var blockElements = map[atom.Atom]bool{
+	atom.P:          true,
+	atom.Div:        true,
+	atom.Section:    true,
+	atom.Article:    true,
+	atom.Header:     true,
+	atom.Footer:     true,
+	atom.Main:       true,
+	atom.Nav:        true,
+	atom.Aside:      true,
+	atom.Center:     true,
+	atom.Address:    true,
+	atom.Figure:     true,
+	atom.Figcaption: true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+	atom.Tbody:      true,
+	atom.Thead:      true,
+	atom.Tfoot:      true,
+	atom.Tr:         true,
+}
+
+// htmlRenderer collects inline segments into RichText widgets, and adds
+// tables and quotes as containers between them
+type htmlRenderer struct {
+	opts    HTMLOptions
+	blocked *int
+
+	objects  []fyne.CanvasObject
+	segments []widget.RichTextSegment
+
+	bold, italic, mono int
+	heading            fyne.ThemeSizeName
+	link               *url.URL
+	pre                bool
+	// space is true when the last text ended in whitespace, or nothing has
+	// been written on the current line yet
+	space bool
+}
+
+func (r *htmlRenderer) child() *htmlRenderer {
+	return &htmlRenderer{opts: r.opts, blocked: r.blocked, space: true}
+}
+
+// finish flushes pending text and returns everything rendered
+func (r *htmlRenderer) finish() []fyne.CanvasObject {
+	r.flush()
+	return r.objects
+}
+
+// flush turns the pending segments into a RichText widget
+func (r *htmlRenderer) flush() {
+	r.endLine()
+	if len(r.segments) == 0 {
+		return
+	}
+	text := widget.NewRichText(r.segments...)
+	text.Wrapping = fyne.TextWrapWord
+	r.objects = append(r.objects, text)
+	r.segments = nil
+}
+
+// endLine makes the next segment start on a new line
+func (r *htmlRenderer) endLine() {
+	r.space = true
+	if len(r.segments) == 0 {
+		return
+	}
+	if text, ok := r.segments[len(r.segments)-1].(*widget.TextSegment); ok && text.Style.Inline {
+		text.Style.Inline = false
+		return
+	}
+	if _, ok := r.segments[len(r.segments)-1].(*widget.HyperlinkSegment); ok {
+		r.segments = append(r.segments, &widget.TextSegment{Style: widget.RichTextStyleParagraph})
+	}
+}
+
+// lineBreak handles <br>, which unlike endLine also breaks an empty line
+func (r *htmlRenderer) lineBreak() {
+	if n := len(r.segments); n == 0 || !r.segments[n-1].Inline() {
+		r.segments = append(r.segments, &widget.TextSegment{Style: widget.RichTextStyleParagraph})
+	}
+	r.endLine()
+}
+
+func (r *htmlRenderer) style() widget.RichTextStyle {
+	style := widget.RichTextStyleInline
+	style.TextStyle = fyne.TextStyle{
+		Bold:      r.bold > 0 || r.heading != "",
+		Italic:    r.italic > 0,
+		Monospace: r.mono > 0 || r.pre,
+	}
+	if r.heading != "" {
+		style.SizeName = r.heading
+	}
+	return style
+}
+
+// text adds inline text, collapsing whitespace as a browser would
+func (r *htmlRenderer) text(s string) {
+	if r.pre {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				r.lineBreak()
+			}
+			if line != "" {
+				r.segments = append(r.segments, &widget.TextSegment{Text: line, Style: r.style()})
+			}
+		}
+		return
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" && !r.space {
+			r.segments = append(r.segments, &widget.TextSegment{Text: " ", Style: r.style()})
+			r.space = true
+		}
+		return
+	}
+	collapsed := strings.Join(fields, " ")
+	if !r.space && isSpace(s[0]) {
+		collapsed = " " + collapsed
+	}
+	if isSpace(s[len(s)-1]) {
+		collapsed += " "
+	}
+	r.space = isSpace(s[len(s)-1])
+
+	if r.link != nil {
+		r.segments = append(r.segments, &widget.HyperlinkSegment{Text: collapsed, URL: r.link})
+		return
+	}
+	r.segments = append(r.segments, &widget.TextSegment{Text: collapsed, Style: r.style()})
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func (r *htmlRenderer) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+func (r *htmlRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.DocumentNode:
+		r.walkChildren(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.B, atom.Strong:
+		r.bold++
+		r.walkChildren(n)
+		r.bold--
+	case atom.I, atom.Em, atom.Cite:
+		r.italic++
+		r.walkChildren(n)
+		r.italic--
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		r.mono++
+		r.walkChildren(n)
+		r.mono--
+	case atom.Pre:
+		r.endLine()
+		r.pre = true
+		r.walkChildren(n)
+		r.pre = false
+		r.endLine()
+	case atom.A:
+		link := safeURL(attr(n, "href"), "http", "https", "mailto")
+		if link == nil || r.link != nil {
+			r.walkChildren(n)
+			return
+		}
+		r.link = link
+		r.walkChildren(n)
+		r.link = nil
+	case atom.H1, atom.H2:
+		r.headingBlock(n, theme.SizeNameHeadingText)
+	case atom.H3, atom.H4, atom.H5, atom.H6:
+		r.headingBlock(n, theme.SizeNameSubHeadingText)
+	case atom.Br:
+		r.lineBreak()
+	case atom.Hr:
+		r.endLine()
+		r.segments = append(r.segments, &widget.SeparatorSegment{})
+		r.space = true
+	case atom.Ul, atom.Ol:
+		r.list(n)
+	case atom.Blockquote:
+		r.quote(n)
+	case atom.Table:
+		r.table(n)
+	case atom.Img:
+		r.image(n)
+	case atom.Div:
+		if strings.Contains(attr(n, "class"), "gmail_quote") {
+			r.quote(n)
+			return
+		}
+		r.endLine()
+		r.walkChildren(n)
+		r.endLine()
+	default:
+		block := blockElements[n.DataAtom] || n.DataAtom == atom.Li
+		if block {
+			r.endLine()
+		}
+		r.walkChildren(n)
+		if block {
+			r.endLine()
+		}
+	}
+}
+
+func (r *htmlRenderer) headingBlock(n *html.Node, size fyne.ThemeSizeName) {
+	r.endLine()
+	r.heading = size
+	r.walkChildren(n)
+	r.heading = ""
+	r.endLine()
+}
+
+// list renders ul and ol as a ListSegment. Nested lists and other block
+// content inside an item are flattened into the item's paragraph.
+func (r *htmlRenderer) list(n *html.Node) {
+	r.endLine()
+	list := &widget.ListSegment{Ordered: n.DataAtom == atom.Ol}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		item := r.child()
+		item.bold, item.italic, item.mono = r.bold, r.italic, r.mono
+		item.walkChildren(c)
+		for _, seg := range item.segments {
+			if text, ok := seg.(*widget.TextSegment); ok {
+				text.Style.Inline = true
+			}
+		}
+		list.Items = append(list.Items, &widget.ParagraphSegment{Texts: item.segments})
+		for _, obj := range item.objects {
+			r.flushWith(obj)
+		}
+	}
+	if len(list.Items) > 0 {
+		r.segments = append(r.segments, list)
+	}
+	r.space = true
+}
+
+// quote renders a blockquote indented behind a bar
+func (r *htmlRenderer) quote(n *html.Node) {
+	inner := r.child()
+	inner.italic = 1
+	// Quotes nested in a collapsed quote are revealed with it
+	inner.opts.CollapseQuotes = false
+	inner.walkChildren(n)
+	objects := inner.finish()
+	if len(objects) == 0 {
+		return
+	}
+	if r.opts.CollapseQuotes {
+		r.flushWith(collapsedQuote(QuoteBlock(objects...)))
+		return
+	}
+	r.flushWith(QuoteBlock(objects...))
+}
+
+// collapsedQuote hides quoted text until it is expanded
+func collapsedQuote(quote fyne.CanvasObject) fyne.CanvasObject {
+	return widget.NewAccordion(widget.NewAccordionItem("Quoted text", quote))
+}
+
+// QuoteBlock indents objects behind a vertical bar, the way quoted text is
+// shown in mail clients
+func QuoteBlock(objects ...fyne.CanvasObject) fyne.CanvasObject {
+	bar := canvas.NewRectangle(theme.Color(theme.ColorNameSeparator))
+	bar.SetMinSize(fyne.NewSize(3, 0))
+	return container.NewBorder(nil, nil, container.NewPadded(bar), nil, container.NewVBox(objects...))
+}
+
+// table renders each cell as its own block in a grid. Tables with a single
+// column, which emails mostly use for layout, are rendered as plain blocks.
+func (r *htmlRenderer) table(n *html.Node) {
+	var rows [][]*html.Node
+	columns := 0
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []*html.Node
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						cells = append(cells, cell)
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+					columns = max(columns, len(cells))
+				}
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Caption:
+				r.endLine()
+				r.walkChildren(c)
+				r.endLine()
+			}
+		}
+	}
+	collect(n)
+
+	if columns <= 1 {
+		for _, row := range rows {
+			for _, cell := range row {
+				r.endLine()
+				r.walkChildren(cell)
+				r.endLine()
+			}
+		}
+		return
+	}
+
+	grid := container.NewGridWithColumns(columns)
+	for _, row := range rows {
+		for i := 0; i < columns; i++ {
+			if i >= len(row) {
+				grid.Add(widget.NewLabel(""))
+				continue
+			}
+			cell := r.child()
+			if row[i].DataAtom == atom.Th {
+				cell.bold = 1
+			}
+			cell.walkChildren(row[i])
+			grid.Add(container.NewVBox(cell.finish()...))
+		}
+	}
+	r.flushWith(grid)
+}
+
+// image shows an image, or a placeholder with its alt text if it is not
+// loaded
+func (r *htmlRenderer) image(n *html.Node) {
+	alt := strings.TrimSpace(attr(n, "alt"))
+	src := safeURL(attr(n, "src"), "http", "https")
+
+	if src != nil && r.opts.LoadImages {
+		uri, err := storage.ParseURI(src.String())
+		if err == nil {
+			r.endLine()
+			r.segments = append(r.segments, &widget.ImageSegment{Source: uri, Title: alt})
+			r.space = true
+			return
+		}
+	}
+	if src != nil {
+		*r.blocked++
+	}
+
+	if alt == "" {
+		if src == nil {
+			return
+		}
+		alt = "image"
+	}
+	style := widget.RichTextStyleInline
+	style.ColorName = theme.ColorNamePlaceHolder
+	style.TextStyle.Italic = true
+	r.segments = append(r.segments, &widget.TextSegment{Text: "[" + alt + "]", Style: style})
+	r.space = false
+}
+
+// flushWith adds obj as a block after the pending text
+func (r *htmlRenderer) flushWith(obj fyne.CanvasObject) {
+	r.flush()
+	r.objects = append(r.objects, obj)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// safeURL parses raw and returns it only if it uses one of schemes
+func safeURL(raw string, schemes ...string) *url.URL {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return u
+		}
+	}
+	return nil
+}
+
+// wrappedLabel is a word-wrapped label for plain text
+func wrappedLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.Wrapping = fyne.TextWrapWord
+	return label
+}
+
+// monospaceLabel shows text such as raw source or headers as written
+func monospaceLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.TextStyle = fyne.TextStyle{Monospace: true}
+	label.Wrapping = fyne.TextWrapBreak
+	return label
+}
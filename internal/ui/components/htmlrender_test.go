@@ -0,0 +1,180 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+// describe flattens rendered objects into text: blocks end in a newline,
+// links are written [text](url), images <img> and quotes > {...}
+func describe(objects []fyne.CanvasObject) string {
+	var b strings.Builder
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *widget.RichText:
+			describeSegments(&b, o.Segments)
+		case *widget.Accordion:
+			b.WriteString("collapsed ")
+			for _, item := range o.Items {
+				b.WriteString(describe([]fyne.CanvasObject{item.Detail}))
+			}
+		case *widget.Label:
+			b.WriteString(o.Text + "\n")
+		case *fyne.Container:
+			if quoted, ok := quoteContent(o); ok {
+				b.WriteString("> {" + describe(quoted) + "}\n")
+				continue
+			}
+			b.WriteString(describe(o.Objects))
+		}
+	}
+	return b.String()
+}
+
+func describeSegments(b *strings.Builder, segments []widget.RichTextSegment) {
+	for _, seg := range segments {
+		switch s := seg.(type) {
+		case *widget.TextSegment:
+			b.WriteString(s.Text)
+		case *widget.HyperlinkSegment:
+			b.WriteString("[" + s.Text + "](" + s.URL.String() + ")")
+		case *widget.ImageSegment:
+			b.WriteString("<img>")
+		case *widget.ListSegment:
+			for _, item := range s.Items {
+				b.WriteString("- ")
+				describeSegments(b, []widget.RichTextSegment{item})
+			}
+		case *widget.ParagraphSegment:
+			describeSegments(b, s.Texts)
+		}
+		if !seg.Inline() {
+			b.WriteString("\n")
+		}
+	}
+}
+
+// quoteContent returns what a QuoteBlock holds
+func quoteContent(c *fyne.Container) ([]fyne.CanvasObject, bool) {
+	var content []fyne.CanvasObject
+	isQuote := false
+	for _, obj := range c.Objects {
+		inner, ok := obj.(*fyne.Container)
+		if !ok {
+			return nil, false
+		}
+		if len(inner.Objects) == 1 {
+			if _, ok := inner.Objects[0].(*canvas.Rectangle); ok {
+				isQuote = true
+				continue
+			}
+		}
+		content = inner.Objects
+	}
+	return content, isQuote
+}
+
+func TestRenderHTML(t *testing.T) {
+	test.NewTempApp(t)
+
+	tests := []struct {
+		name    string
+		src     string
+		opts    HTMLOptions
+		want    string
+		blocked int
+	}{
+		{
+			name: "plain paragraphs",
+			src:  "<p>Hello\n  there</p><p>Bye</p>",
+			want: "Hello there\nBye\n",
+		},
+		{
+			name: "scripts and styles are dropped",
+			src: `<html><head><title>T</title><style>p { color: red }</style></head>` +
+				`<body><script>alert("hi")</script><p>Shown</p><noscript>No JS</noscript>` +
+				`<form><input value="x"><button>Go</button></form></body></html>`,
+			want: "Shown\n",
+		},
+		{
+			name: "unsafe links are left as text",
+			src:  `<a href="javascript:alert(1)">bad</a> <a href="https://example.com/x">good</a>`,
+			want: "bad [good](https://example.com/x)\n",
+		},
+		{
+			name:    "remote image is blocked",
+			src:     `<p>Logo: <img src="http://tracker.example/p.gif" alt="ACME"></p>`,
+			want:    "Logo: [ACME]\n",
+			blocked: 1,
+		},
+		{
+			name:    "blocked image without alt text",
+			src:     `<img src=https://tracker.example/p.gif>`,
+			want:    "[image]\n",
+			blocked: 1,
+		},
+		{
+			name: "image that is not http is dropped",
+			src:  `<img src="data:image/gif;base64,R0lGOD"><img src="cid:logo">`,
+			want: "",
+		},
+		{
+			name: "remote image is loaded when allowed",
+			src:  `<img src="https://example.com/a.png" alt="A">`,
+			opts: HTMLOptions{LoadImages: true},
+			want: "<img>\n",
+		},
+		{
+			name: "nested blockquotes",
+			src:  "<p>Reply</p><blockquote>Earlier<blockquote>Original</blockquote></blockquote>",
+			want: "Reply\n> {Earlier\n> {Original\n}\n}\n",
+		},
+		{
+			name: "only the outer quote collapses",
+			src:  "<p>Reply</p><blockquote>Earlier<blockquote>Original</blockquote></blockquote>",
+			opts: HTMLOptions{CollapseQuotes: true},
+			want: "Reply\ncollapsed > {Earlier\n> {Original\n}\n}\n",
+		},
+		{
+			name: "Gmail quote",
+			src:  `<div>Thanks</div><div class="gmail_quote">On Monday Alice wrote</div>`,
+			want: "Thanks\n> {On Monday Alice wrote\n}\n",
+		},
+		{
+			name: "unclosed tags",
+			src:  "<p>one <b>two <i>three</p><p>four",
+			want: "one two three\nfour\n",
+		},
+		{
+			name: "stray closing tags",
+			src:  "</div></p>text</b> after<",
+			want: "text after<\n",
+		},
+		{
+			name: "table without closing tags",
+			src:  "<table><tr><td>a<td>b<tr><td>c</table>after",
+			want: "a\nb\nc\n\nafter\n",
+		},
+		{
+			name: "list",
+			src:  "<ul><li>one<li>two</ul>",
+			want: "- one\n- two\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := RenderHTML(tt.src, tt.opts)
+			if got := describe(rendered.Objects); got != tt.want {
+				t.Errorf("RenderHTML(%q) =\n%q\nwant\n%q", tt.src, got, tt.want)
+			}
+			if rendered.BlockedImages != tt.blocked {
+				t.Errorf("blocked %d images, want %d", rendered.BlockedImages, tt.blocked)
+			}
+		})
+	}
+}
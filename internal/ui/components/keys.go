@@ -0,0 +1,74 @@
+package components
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// KeyHandler runs the app's key bindings for keys typed while a list or
+// one of its checkboxes has focus, since the canvas only sees keys when
+// nothing does. Each function reports whether the key was bound; if not,
+// the focused widget handles it as usual. Either may be nil.
+type KeyHandler struct {
+	Rune func(r rune) bool
+	Key  func(e *fyne.KeyEvent) bool
+}
+
+func (h *KeyHandler) typedRune(r rune) bool {
+	return h.Rune != nil && h.Rune(r)
+}
+
+func (h *KeyHandler) typedKey(e *fyne.KeyEvent) bool {
+	return h.Key != nil && h.Key(e)
+}
+
+// keyTree is a tree that passes typed keys to a KeyHandler first
+type keyTree struct {
+	widget.Tree
+	keys *KeyHandler
+}
+
+func newKeyTree(keys *KeyHandler, childUIDs func(widget.TreeNodeID) []widget.TreeNodeID, isBranch func(widget.TreeNodeID) bool,
+	create func(bool) fyne.CanvasObject, update func(widget.TreeNodeID, bool, fyne.CanvasObject)) *keyTree {
+	t := &keyTree{keys: keys}
+	t.ChildUIDs, t.IsBranch, t.CreateNode, t.UpdateNode = childUIDs, isBranch, create, update
+	t.ExtendBaseWidget(t)
+	return t
+}
+
+func (t *keyTree) TypedRune(r rune) {
+	if !t.keys.typedRune(r) {
+		t.Tree.TypedRune(r)
+	}
+}
+
+func (t *keyTree) TypedKey(e *fyne.KeyEvent) {
+	if !t.keys.typedKey(e) {
+		t.Tree.TypedKey(e)
+	}
+}
+
+// keyList is a list that passes typed keys to a KeyHandler first
+type keyList struct {
+	widget.List
+	keys *KeyHandler
+}
+
+func newKeyList(keys *KeyHandler, length func() int, create func() fyne.CanvasObject, update func(widget.ListItemID, fyne.CanvasObject)) *keyList {
+	l := &keyList{keys: keys}
+	l.Length, l.CreateItem, l.UpdateItem = length, create, update
+	l.ExtendBaseWidget(l)
+	return l
+}
+
+func (l *keyList) TypedRune(r rune) {
+	if !l.keys.typedRune(r) {
+		l.List.TypedRune(r)
+	}
+}
+
+func (l *keyList) TypedKey(e *fyne.KeyEvent) {
+	if !l.keys.typedKey(e) {
+		l.List.TypedKey(e)
+	}
+}
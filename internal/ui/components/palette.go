@@ -0,0 +1,151 @@
+package components
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// paletteLimit is how many matches the command palette lists
+const paletteLimit = 50
+
+// Command is an entry of the command palette
+type Command struct {
+	// Title is what is matched against and shown, e.g. "Sync Emails"
+	Title string
+	// Hint is shown next to the title, e.g. the command's shortcut
+	Hint string
+	Run  func()
+}
+
+// CommandPalette lets every command be run from the keyboard: type a few
+// letters of it in any order-preserving subsequence and press Enter
+type CommandPalette struct {
+	window   fyne.Window
+	commands []Command
+	matches  []Command
+
+	dialog *dialog.CustomDialog
+	entry  *widget.Entry
+	list   *widget.List
+}
+
+func NewCommandPalette(window fyne.Window) *CommandPalette {
+	p := &CommandPalette{window: window}
+
+	p.entry = widget.NewEntry()
+	p.entry.SetPlaceHolder("Type a command, view or saved search")
+	p.entry.OnChanged = p.filter
+	p.entry.OnSubmitted = func(string) {
+		if len(p.matches) > 0 {
+			p.run(p.matches[0])
+		}
+	}
+
+	p.list = widget.NewList(
+		func() int { return len(p.matches) },
+		func() fyne.CanvasObject {
+			hint := widget.NewLabel("")
+			hint.Importance = widget.LowImportance
+			title := widget.NewLabel("")
+			title.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, nil, hint, title)
+		},
+		func(i widget.ListItemID, obj fyne.CanvasObject) {
+			c := obj.(*fyne.Container)
+			c.Objects[0].(*widget.Label).SetText(p.matches[i].Title)
+			c.Objects[1].(*widget.Label).SetText(p.matches[i].Hint)
+		},
+	)
+	p.list.OnSelected = func(i widget.ListItemID) {
+		p.run(p.matches[i])
+	}
+
+	content := container.NewBorder(p.entry, nil, nil, nil, p.list)
+	p.dialog = dialog.NewCustom("Command Palette", "Close", content, window)
+	p.dialog.Resize(fyne.NewSize(500, 400))
+	return p
+}
+
+// Show opens the palette offering commands
+func (p *CommandPalette) Show(commands []Command) {
+	p.commands = commands
+	p.entry.SetText("")
+	p.filter("")
+	p.dialog.Show()
+	p.window.Canvas().Focus(p.entry)
+}
+
+func (p *CommandPalette) run(command Command) {
+	p.dialog.Hide()
+	command.Run()
+}
+
+// filter lists the commands matching pattern, best match first
+func (p *CommandPalette) filter(pattern string) {
+	type scored struct {
+		command Command
+		score   int
+	}
+	var matches []scored
+	for _, command := range p.commands {
+		if score, ok := fuzzyScore(pattern, command.Title); ok {
+			matches = append(matches, scored{command, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	p.matches = p.matches[:0]
+	for i, m := range matches {
+		if i == paletteLimit {
+			break
+		}
+		p.matches = append(p.matches, m.command)
+	}
+	p.list.UnselectAll()
+	p.list.Refresh()
+	p.list.ScrollToTop()
+}
+
+// fuzzyScore reports whether every character of pattern appears in text in
+// order, ignoring case and spaces, and scores the match. Consecutive
+// characters, matches at the start of words and shorter texts score
+// higher.
+func fuzzyScore(pattern, text string) (int, bool) {
+	pattern = strings.ToLower(strings.ReplaceAll(pattern, " ", ""))
+	if pattern == "" {
+		return 0, true
+	}
+
+	runes := []rune(strings.ToLower(text))
+	wanted := []rune(pattern)
+	score, w, last := 0, 0, -2
+	for i, r := range runes {
+		if w == len(wanted) {
+			break
+		}
+		if r != wanted[w] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			score += 2
+		}
+		last = i
+		w++
+	}
+	if w < len(wanted) {
+		return 0, false
+	}
+	return score*100 - len(runes), true
+}
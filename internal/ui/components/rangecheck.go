@@ -0,0 +1,42 @@
+package components
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// rangeCheck is a checkbox that remembers whether shift was held when it
+// was clicked. Keyboard state can't be read from the canvas for this since
+// a clicked checkbox takes focus and receives the key events itself, which
+// it passes to keys first.
+type rangeCheck struct {
+	widget.Check
+	shift bool
+	keys  *KeyHandler
+}
+
+func newRangeCheck(keys *KeyHandler) *rangeCheck {
+	c := &rangeCheck{keys: keys}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// MouseDown runs before Tapped, which fires OnChanged
+func (c *rangeCheck) MouseDown(ev *desktop.MouseEvent) {
+	c.shift = ev.Modifier&fyne.KeyModifierShift != 0
+}
+
+func (c *rangeCheck) MouseUp(*desktop.MouseEvent) {}
+
+func (c *rangeCheck) TypedRune(r rune) {
+	if !c.keys.typedRune(r) {
+		c.Check.TypedRune(r)
+	}
+}
+
+func (c *rangeCheck) TypedKey(e *fyne.KeyEvent) {
+	if !c.keys.typedKey(e) {
+		c.Check.TypedKey(e)
+	}
+}
@@ -0,0 +1,90 @@
+package components
+
+import "sort"
+
+// Selection is the set of selected email IDs. It lives outside the list
+// widgets so selections survive row recycling, reloads and rows that were
+// never rendered. It is only used from the Fyne UI goroutine.
+type Selection struct {
+	ids       map[string]struct{}
+	listeners []func()
+}
+
+func NewSelection() *Selection {
+	return &Selection{ids: make(map[string]struct{})}
+}
+
+// OnChanged registers fn to be called whenever the selection changes
+func (s *Selection) OnChanged(fn func()) {
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Selection) notify() {
+	for _, fn := range s.listeners {
+		fn()
+	}
+}
+
+func (s *Selection) Contains(id string) bool {
+	_, ok := s.ids[id]
+	return ok
+}
+
+// ContainsAll reports whether every one of ids is selected. It is false
+// for an empty list.
+func (s *Selection) ContainsAll(ids []string) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	for _, id := range ids {
+		if !s.Contains(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Selection) Set(id string, selected bool) {
+	s.SetAll([]string{id}, selected)
+}
+
+// SetAll selects or deselects every one of ids
+func (s *Selection) SetAll(ids []string, selected bool) {
+	changed := false
+	for _, id := range ids {
+		if selected == s.Contains(id) {
+			continue
+		}
+		if selected {
+			s.ids[id] = struct{}{}
+		} else {
+			delete(s.ids, id)
+		}
+		changed = true
+	}
+	if changed {
+		s.notify()
+	}
+}
+
+func (s *Selection) Clear() {
+	if len(s.ids) == 0 {
+		return
+	}
+	s.ids = make(map[string]struct{})
+	s.notify()
+}
+
+func (s *Selection) Count() int {
+	return len(s.ids)
+}
+
+// IDs returns the selected IDs in sorted order
+func (s *Selection) IDs() []string {
+	ids := make([]string, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
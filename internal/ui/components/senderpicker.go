@@ -0,0 +1,193 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// pickerPageSize is how many senders the sender picker loads at a time
+const pickerPageSize = 100
+
+// SenderPicker is a button that opens a searchable list of senders to
+// choose from. Only the senders matching the search are loaded, a page at
+// a time as the list scrolls, so a mailbox with many senders is never
+// loaded whole. Its fixed options, such as showing every email, are
+// listed before the senders. All fields are only touched on the Fyne UI
+// goroutine.
+type SenderPicker struct {
+	Button   *widget.Button
+	Selected string
+	// OnChanged is called when another entry is chosen
+	OnChanged func(selected string)
+
+	ctx     context.Context
+	db      store.Store
+	window  fyne.Window
+	options []string
+
+	dialog *dialog.CustomDialog
+	entry  *widget.Entry
+	list   *widget.List
+
+	// generation is bumped on every search so late page loads for a
+	// previous one are dropped
+	generation int
+	fixed      []string
+	senders    []models.SenderCount
+	more       bool
+	loading    bool
+}
+
+func NewSenderPicker(ctx context.Context, db store.Store, window fyne.Window, options []string, onChanged func(string)) *SenderPicker {
+	p := &SenderPicker{
+		ctx:       ctx,
+		db:        db,
+		window:    window,
+		options:   options,
+		OnChanged: onChanged,
+	}
+	p.Button = widget.NewButtonWithIcon("", theme.AccountIcon(), p.Show)
+
+	p.entry = widget.NewEntry()
+	p.entry.SetPlaceHolder("Search senders")
+	p.entry.OnChanged = p.search
+	p.entry.OnSubmitted = func(string) {
+		if len(p.fixed)+len(p.senders) > 0 {
+			p.choose(p.entryAt(0))
+		}
+	}
+
+	p.list = widget.NewList(p.length, p.createRow, p.updateRow)
+	p.list.OnSelected = func(i widget.ListItemID) {
+		p.list.Unselect(i)
+		if i < len(p.fixed)+len(p.senders) {
+			p.choose(p.entryAt(i))
+		}
+	}
+
+	content := container.NewBorder(p.entry, nil, nil, nil, p.list)
+	p.dialog = dialog.NewCustom("Choose Sender", "Close", content, window)
+	p.dialog.Resize(fyne.NewSize(500, 400))
+	return p
+}
+
+// SetSelected shows selected on the button and calls OnChanged if it
+// differs from the current choice
+func (p *SenderPicker) SetSelected(selected string) {
+	old := p.Selected
+	p.Selected = selected
+	p.Button.SetText(selected)
+	if p.OnChanged != nil && selected != old {
+		p.OnChanged(selected)
+	}
+}
+
+// Show opens the list with every sender
+func (p *SenderPicker) Show() {
+	p.entry.SetText("")
+	p.search("")
+	p.dialog.Show()
+	p.window.Canvas().Focus(p.entry)
+}
+
+func (p *SenderPicker) choose(selected string) {
+	p.dialog.Hide()
+	p.SetSelected(selected)
+}
+
+// search lists the fixed options and senders containing query, and loads
+// the first page of senders
+func (p *SenderPicker) search(query string) {
+	p.generation++
+	query = strings.TrimSpace(query)
+	p.fixed = p.fixed[:0]
+	for _, option := range p.options {
+		if strings.Contains(strings.ToLower(option), strings.ToLower(query)) {
+			p.fixed = append(p.fixed, option)
+		}
+	}
+	p.senders = nil
+	p.more = true
+	p.loading = false
+	p.list.Refresh()
+	p.list.ScrollToTop()
+	p.loadMore(query)
+}
+
+// loadMore fetches the next page of senders containing query in the
+// background
+func (p *SenderPicker) loadMore(query string) {
+	if p.loading || !p.more {
+		return
+	}
+	p.loading = true
+
+	generation := p.generation
+	var after string
+	if len(p.senders) > 0 {
+		after = p.senders[len(p.senders)-1].Address
+	}
+	go func() {
+		filter := models.EmailFilter{SenderContains: query}
+		senders, err := p.db.ListSenders(p.ctx, filter, false, after, pickerPageSize)
+		fyne.Do(func() {
+			if generation != p.generation {
+				return
+			}
+			p.loading = false
+			if err != nil {
+				logger.Error("Unable to load senders", "err", err)
+				p.more = false
+			} else {
+				p.senders = append(p.senders, senders...)
+				p.more = len(senders) == pickerPageSize
+			}
+			p.list.Refresh()
+		})
+	}()
+}
+
+func (p *SenderPicker) length() int {
+	n := len(p.fixed) + len(p.senders)
+	if p.more {
+		n++
+	}
+	return n
+}
+
+// entryAt returns the option or sender address of row i
+func (p *SenderPicker) entryAt(i int) string {
+	if i < len(p.fixed) {
+		return p.fixed[i]
+	}
+	return p.senders[i-len(p.fixed)].Address
+}
+
+func (p *SenderPicker) createRow() fyne.CanvasObject {
+	label := widget.NewLabel("Sender")
+	label.Truncation = fyne.TextTruncateEllipsis
+	return label
+}
+
+func (p *SenderPicker) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	label := obj.(*widget.Label)
+	switch {
+	case i < len(p.fixed):
+		label.SetText(p.fixed[i])
+	case i < len(p.fixed)+len(p.senders):
+		sender := p.senders[i-len(p.fixed)]
+		label.SetText(fmt.Sprintf("%s (%d emails)", sender.Address, sender.Count))
+	default:
+		label.SetText("Loading more senders...")
+		p.loadMore(strings.TrimSpace(p.entry.Text))
+	}
+}
@@ -0,0 +1,70 @@
+package components
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// snackbarTimeout is how long an undo offer stays up
+const snackbarTimeout = 10 * time.Second
+
+// Snackbar is a bar that briefly reports the last bulk action and offers
+// to undo it. Only the last action can be undone; showing a new one
+// replaces the offer.
+type Snackbar struct {
+	Container *fyne.Container
+	label     *widget.Label
+	undoBtn   *widget.Button
+
+	undo func()
+	// shown counts messages so an old timeout does not hide a newer one
+	shown int
+}
+
+func NewSnackbar() *Snackbar {
+	sb := &Snackbar{label: widget.NewLabel("")}
+	sb.undoBtn = widget.NewButton("Undo", func() {
+		undo := sb.undo
+		sb.Hide()
+		if undo != nil {
+			undo()
+		}
+	})
+	closeBtn := widget.NewButton("Dismiss", sb.Hide)
+
+	sb.Container = container.NewHBox(sb.label, sb.undoBtn, closeBtn)
+	sb.Container.Hide()
+	return sb
+}
+
+// Show displays message with an Undo button running undo, or no button if
+// undo is nil. Must be called on the UI goroutine.
+func (sb *Snackbar) Show(message string, undo func()) {
+	sb.shown++
+	shown := sb.shown
+	sb.undo = undo
+	sb.label.SetText(message)
+	if undo != nil {
+		sb.undoBtn.Show()
+	} else {
+		sb.undoBtn.Hide()
+	}
+	sb.Container.Show()
+
+	time.AfterFunc(snackbarTimeout, func() {
+		fyne.Do(func() {
+			if sb.shown == shown {
+				sb.Hide()
+			}
+		})
+	})
+}
+
+// Hide removes the bar and drops the undo offer
+func (sb *Snackbar) Hide() {
+	sb.undo = nil
+	sb.Container.Hide()
+}
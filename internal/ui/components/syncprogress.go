@@ -0,0 +1,88 @@
+package components
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// SyncProgressDialog shows the counts and ETA of a running sync with a
+// Cancel button. Update may be called from any goroutine.
+type SyncProgressDialog struct {
+	dialog    *dialog.CustomDialog
+	bar       *widget.ProgressBar
+	counts    *widget.Label
+	eta       *widget.Label
+	cancelBtn *widget.Button
+	started   time.Time
+}
+
+func NewSyncProgressDialog(window fyne.Window, onCancel func()) *SyncProgressDialog {
+	pd := &SyncProgressDialog{
+		bar:     widget.NewProgressBar(),
+		counts:  widget.NewLabel("Listed 0 · Fetched 0 · Saved 0"),
+		eta:     widget.NewLabel("Estimating time remaining..."),
+		started: time.Now(),
+	}
+
+	pd.cancelBtn = widget.NewButton("Cancel", func() {
+		pd.cancelBtn.Disable()
+		pd.cancelBtn.SetText("Cancelling...")
+		onCancel()
+	})
+
+	content := container.NewVBox(
+		widget.NewLabel("Fetching emails from Gmail..."),
+		pd.bar,
+		pd.counts,
+		pd.eta,
+		container.NewCenter(pd.cancelBtn),
+	)
+
+	pd.dialog = dialog.NewCustomWithoutButtons("Syncing", content, window)
+	pd.dialog.Resize(fyne.NewSize(400, 0))
+	return pd
+}
+
+func (pd *SyncProgressDialog) Show() {
+	pd.dialog.Show()
+}
+
+func (pd *SyncProgressDialog) Hide() {
+	fyne.Do(pd.dialog.Hide)
+}
+
+func (pd *SyncProgressDialog) Update(p handlers.SyncProgress) {
+	counts := fmt.Sprintf("Listed %d · Fetched %d · Saved %d", p.Listed, p.Fetched, p.Saved)
+	if p.Total > 0 {
+		counts += fmt.Sprintf(" of %d", p.Total)
+	}
+	eta := pd.estimate(p)
+
+	fyne.Do(func() {
+		if p.Total > 0 {
+			pd.bar.SetValue(float64(p.Fetched) / float64(p.Total))
+		}
+		pd.counts.SetText(counts)
+		pd.eta.SetText(eta)
+	})
+}
+
+// estimate extrapolates the remaining time from the fetch rate so far
+func (pd *SyncProgressDialog) estimate(p handlers.SyncProgress) string {
+	if p.Total == 0 || p.Fetched == 0 {
+		return "Estimating time remaining..."
+	}
+	if p.Fetched >= p.Total {
+		return "Saving..."
+	}
+	elapsed := time.Since(pd.started)
+	perEmail := elapsed / time.Duration(p.Fetched)
+	remaining := perEmail * time.Duration(p.Total-p.Fetched)
+	return fmt.Sprintf("About %s remaining", remaining.Round(time.Second))
+}
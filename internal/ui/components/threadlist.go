@@ -0,0 +1,192 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// threadsPageSize is how many threads are loaded at a time
+const threadsPageSize = 100
+
+// ThreadList shows conversations, most recently active first, and opens
+// the selected one in the email view. Threads are loaded a page at a time
+// as the list scrolls. All fields are only touched on the Fyne UI
+// goroutine.
+type ThreadList struct {
+	Container *fyne.Container
+	ctx       context.Context
+	db        store.Store
+	emailView *EmailView
+	list      *keyList
+
+	// Keys runs the app's shortcuts while the list has focus
+	Keys KeyHandler
+
+	filter models.EmailFilter
+
+	// generation is bumped on every reload so late page loads from a
+	// previous query are dropped
+	generation int
+	threads    []models.Thread
+	next       *models.ThreadCursor
+	more       bool
+	loading    bool
+	current    widget.ListItemID
+}
+
+func NewThreadList(ctx context.Context, db store.Store, emailView *EmailView) *ThreadList {
+	tl := &ThreadList{
+		ctx:       ctx,
+		db:        db,
+		emailView: emailView,
+	}
+
+	tl.list = newKeyList(&tl.Keys, tl.length, tl.createRow, tl.updateRow)
+	tl.list.OnSelected = tl.threadSelected
+	tl.current = -1
+
+	tl.Container = container.NewStack(tl.list)
+	return tl
+}
+
+// LoadThreads shows the threads with an email matching filter
+func (tl *ThreadList) LoadThreads(filter models.EmailFilter) {
+	tl.reload(filter)
+}
+
+// reload drops everything loaded and starts again from the first page
+func (tl *ThreadList) reload(filter models.EmailFilter) {
+	tl.generation++
+	tl.filter = filter
+	tl.threads = nil
+	tl.next = nil
+	tl.more = true
+	tl.loading = false
+	tl.current = -1
+
+	tl.list.UnselectAll()
+	tl.list.ScrollToTop()
+	tl.loadMore()
+}
+
+// loadMore fetches the next page of threads in the background
+func (tl *ThreadList) loadMore() {
+	if tl.loading || !tl.more {
+		return
+	}
+	tl.loading = true
+
+	generation := tl.generation
+	filter, after := tl.filter, tl.next
+
+	go func() {
+		threads, next, err := tl.db.ListThreads(tl.ctx, filter, after, threadsPageSize)
+		fyne.Do(func() {
+			if generation != tl.generation {
+				return
+			}
+			tl.loading = false
+			if err != nil {
+				logger.Error("Unable to load threads", "err", err)
+				tl.more = false
+				tl.list.Refresh()
+				return
+			}
+
+			tl.threads = append(tl.threads, threads...)
+			tl.next = next
+			tl.more = next != nil
+			tl.list.Refresh()
+		})
+	}()
+}
+
+func (tl *ThreadList) length() int {
+	if tl.more {
+		return len(tl.threads) + 1
+	}
+	return len(tl.threads)
+}
+
+func (tl *ThreadList) createRow() fyne.CanvasObject {
+	subject := widget.NewLabel("Subject")
+	subject.TextStyle = fyne.TextStyle{Bold: true}
+	subject.Truncation = fyne.TextTruncateEllipsis
+	participants := widget.NewLabel("Participants")
+	participants.Truncation = fyne.TextTruncateEllipsis
+	return container.NewVBox(subject, participants)
+}
+
+func (tl *ThreadList) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	subject := c.Objects[0].(*widget.Label)
+	participants := c.Objects[1].(*widget.Label)
+
+	if i >= len(tl.threads) {
+		subject.SetText("Loading more threads...")
+		participants.SetText("")
+		tl.loadMore()
+		return
+	}
+
+	thread := tl.threads[i]
+	subject.SetText(fmt.Sprintf("%s (%d)", thread.Subject, thread.Count))
+	participants.SetText(fmt.Sprintf("%s · %s",
+		strings.Join(thread.Participants, ", "), formatActivity(thread.LastActivity)))
+}
+
+func (tl *ThreadList) threadSelected(i widget.ListItemID) {
+	if i >= len(tl.threads) {
+		tl.list.Unselect(i)
+		return
+	}
+	thread := tl.threads[i]
+	tl.current = i
+	trash := tl.filter.Trash
+
+	go func() {
+		emails, err := tl.db.GetThread(tl.ctx, thread.ID, trash)
+		fyne.Do(func() {
+			if err != nil {
+				logger.Error("Unable to load thread", "thread", thread.ID, "err", err)
+				return
+			}
+			tl.emailView.ShowThread(thread, emails)
+		})
+	}()
+}
+
+// Move moves the keyboard cursor delta threads down, or up if negative,
+// and opens the thread it lands on
+func (tl *ThreadList) Move(delta int) {
+	if len(tl.threads) == 0 {
+		return
+	}
+	i := tl.current + delta
+	if tl.current < 0 && delta < 0 {
+		i = len(tl.threads) + delta
+	}
+	i = max(0, min(i, len(tl.threads)-1))
+	if i == tl.current {
+		return
+	}
+	tl.list.Select(i)
+	tl.list.ScrollTo(i)
+}
+
+// formatActivity shows a thread's last activity, which is unknown for
+// emails saved before receive times were recorded
+func formatActivity(t time.Time) string {
+	if t.IsZero() {
+		return "unknown date"
+	}
+	return t.Local().Format("Jan 2, 2006 15:04")
+}
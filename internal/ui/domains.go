@@ -0,0 +1,190 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// showDomainActions opens the menu of bulk actions for a whole sender
+// domain next to its actions button
+func (a *App) showDomainActions(domain models.DomainCount, button fyne.CanvasObject) {
+	var items []*fyne.MenuItem
+	if a.viewMode == "trash" {
+		items = []*fyne.MenuItem{
+			fyne.NewMenuItem("Restore All", func() { a.restoreDomain(domain) }),
+			fyne.NewMenuItem("Delete All Forever...", func() { a.purgeDomain(domain) }),
+		}
+	} else {
+		items = []*fyne.MenuItem{
+			fyne.NewMenuItem("Move All to Trash...", func() { a.trashDomain(domain) }),
+			fyne.NewMenuItem("Label All...", func() { a.labelDomain(domain) }),
+			fyne.NewMenuItem("Unsubscribe...", func() { a.unsubscribeDomain(domain) }),
+		}
+	}
+
+	menu := fyne.NewMenu(domain.Domain, items...)
+	widget.ShowPopUpMenuAtRelativePosition(menu, a.mainWindow.Canvas(),
+		fyne.NewPos(0, button.Size().Height), button)
+}
+
+// domainIDs loads the IDs of every email of domain in the current view
+// and passes them to apply on the UI goroutine. The count shown when
+// confirming comes from here rather than the possibly stale tree row.
+func (a *App) domainIDs(domain models.DomainCount, apply func([]string)) {
+	filter := a.emailList.DomainFilter(domain.Domain)
+	go func() {
+		ids, err := a.db.ListEmailIDs(a.ctx, filter)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to load emails from %s: %v", domain.Domain, err), a.mainWindow)
+				return
+			}
+			if len(ids) == 0 {
+				dialog.ShowInformation("Nothing to Do", "No emails from "+domain.Domain+" are shown", a.mainWindow)
+				return
+			}
+			apply(ids)
+		})
+	}()
+}
+
+func (a *App) trashDomain(domain models.DomainCount) {
+	a.domainIDs(domain, func(ids []string) {
+		a.confirmTrash(a.mainWindow, ids, fmt.Sprintf("Move %d email(s) from %d address(es) at %s to the trash?",
+			len(ids), domain.Senders, domain.Domain), nil)
+	})
+}
+
+func (a *App) purgeDomain(domain models.DomainCount) {
+	a.domainIDs(domain, func(ids []string) {
+		a.confirmPurge(ids, fmt.Sprintf("Permanently delete %d email(s) from %s? This cannot be undone.",
+			len(ids), domain.Domain))
+	})
+}
+
+func (a *App) restoreDomain(domain models.DomainCount) {
+	a.domainIDs(domain, a.restoreEmails)
+}
+
+// labelDomain applies a label chosen from the saved labels to every email
+// of the domain, in Gmail and locally
+func (a *App) labelDomain(domain models.DomainCount) {
+	labels := a.labels
+	if len(labels) == 0 {
+		dialog.ShowInformation("No Labels", "Sync first to load your Gmail labels", a.mainWindow)
+		return
+	}
+
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+
+	a.domainIDs(domain, func(ids []string) {
+		msg := widget.NewLabel(fmt.Sprintf("Label %d email(s) from %d address(es) at %s:",
+			len(ids), domain.Senders, domain.Domain))
+		choice := widget.NewSelect(names, nil)
+		choice.SetSelectedIndex(0)
+
+		dialog.ShowCustomConfirm("Label Emails", "Label", "Cancel", container.NewVBox(msg, choice), func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			label := labels[choice.SelectedIndex()]
+			go func() {
+				err := handlers.LabelEmails(a.ctx, a.db, ids, label.ID)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, a.mainWindow)
+						return
+					}
+					a.snackbar.Show(fmt.Sprintf("Labeled %d emails %s", len(ids), label.Name), nil)
+				})
+			}()
+		}, a.mainWindow)
+	})
+}
+
+// unsubscribeDomain unsubscribes from every address of the domain with a
+// List-Unsubscribe link after showing which senders have one. One-click
+// links are queued if that is turned on; the others are opened.
+func (a *App) unsubscribeDomain(domain models.DomainCount) {
+	filter := a.emailList.DomainFilter(domain.Domain)
+	go func() {
+		links, missing, err := handlers.UnsubscribeLinks(a.ctx, a.db, filter)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.mainWindow)
+				return
+			}
+			if len(links) == 0 {
+				dialog.ShowInformation("Unsubscribe", "None of the emails from "+domain.Domain+" have an unsubscribe link", a.mainWindow)
+				return
+			}
+
+			oneClick := 0
+			for _, link := range links {
+				if link.OneClick && a.cfg.UI.OneClickUnsubscribe {
+					oneClick++
+				}
+			}
+			msg := fmt.Sprintf("Unsubscribe from %d address(es) at %s?", len(links), domain.Domain)
+			if oneClick > 0 {
+				msg += fmt.Sprintf("\n%d take a one-click request, sent in the background.", oneClick)
+			}
+			if oneClick < len(links) {
+				msg += fmt.Sprintf("\n%d link(s) open in your browser or mail client.", len(links)-oneClick)
+			}
+			if len(missing) > 0 {
+				msg += fmt.Sprintf("\n%d address(es) have no link: %s", len(missing), strings.Join(missing, ", "))
+			}
+			label := widget.NewLabel(msg)
+			label.Wrapping = fyne.TextWrapWord
+
+			d := dialog.NewCustomConfirm("Unsubscribe", "Unsubscribe", "Cancel", label, func(confirmed bool) {
+				if confirmed {
+					a.unsubscribe(links)
+				}
+			}, a.mainWindow)
+			d.Resize(fyne.NewSize(480, 0))
+			d.Show()
+		})
+	}()
+}
+
+// unsubscribe queues the one-click unsubscribes among links, if that is
+// turned on, and opens the rest
+func (a *App) unsubscribe(links []handlers.UnsubscribeLink) {
+	go func() {
+		toOpen, err := handlers.Unsubscribe(a.ctx, a.db, links, a.cfg.UI.OneClickUnsubscribe)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.mainWindow)
+			}
+			a.openLinks(toOpen)
+			a.refreshOutbox()
+		})
+	}()
+}
+
+// openLinks opens unsubscribe links in the browser or mail client
+func (a *App) openLinks(links []handlers.UnsubscribeLink) {
+	for _, link := range links {
+		u, err := url.Parse(link.URL)
+		if err != nil {
+			logger.Warn("Skipping unsubscribe link", "sender", link.Sender, "err", err)
+			continue
+		}
+		if err := a.fyneApp.OpenURL(u); err != nil {
+			logger.Error("Unable to open unsubscribe link", "sender", link.Sender, "err", err)
+		}
+	}
+}
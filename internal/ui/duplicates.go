@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/components"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// duplicateClusterLimit is how many duplicate clusters are listed
+const duplicateClusterLimit = 1000
+
+// duplicatesView is a window listing duplicate clusters. The copies of
+// the selected cluster are shown beside the list, and checked clusters
+// can be cut down to one copy. All fields are only touched on the Fyne UI
+// goroutine.
+type duplicatesView struct {
+	app    *App
+	window fyne.Window
+
+	clusters []models.DuplicateCluster
+	checked  map[string]bool
+	copies   []models.Email
+
+	total       *widget.Label
+	clusterList *widget.List
+	copyList    *widget.List
+}
+
+// showDuplicatesView opens the duplicates window
+func (a *App) showDuplicatesView() {
+	v := &duplicatesView{
+		app:     a,
+		window:  a.fyneApp.NewWindow("Duplicates"),
+		checked: make(map[string]bool),
+	}
+
+	v.total = widget.NewLabel("Loading...")
+	v.total.TextStyle = fyne.TextStyle{Bold: true}
+
+	v.clusterList = widget.NewList(
+		func() int { return len(v.clusters) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("Subject")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil), widget.NewLabel("0 B"), label)
+		},
+		v.updateClusterRow,
+	)
+	v.clusterList.OnSelected = v.showCopies
+
+	v.copyList = widget.NewList(
+		func() int { return len(v.copies) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabel("Copy")
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("Details")
+			details.Truncation = fyne.TextTruncateEllipsis
+			details.Importance = widget.LowImportance
+			return container.NewVBox(title, details)
+		},
+		v.updateCopyRow,
+	)
+
+	checkAll := widget.NewButton("Check All", func() {
+		for _, c := range v.clusters {
+			v.checked[c.Fingerprint] = true
+		}
+		v.clusterList.Refresh()
+	})
+	localBtn := widget.NewButton("Keep One Copy Locally", func() { v.removeChecked(false) })
+	remoteBtn := widget.NewButton("Keep One Copy, Trash Rest in Gmail", func() { v.removeChecked(true) })
+	remoteBtn.Importance = widget.HighImportance
+
+	split := container.NewHSplit(v.clusterList, v.copyList)
+	split.SetOffset(0.55)
+
+	v.window.SetContent(container.NewBorder(
+		v.total,
+		container.NewHBox(checkAll, localBtn, remoteBtn),
+		nil, nil,
+		split,
+	))
+	v.window.Resize(fyne.NewSize(900, 600))
+	v.window.Show()
+
+	v.load()
+}
+
+func (v *duplicatesView) updateClusterRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	label := c.Objects[0].(*widget.Label)
+	check := c.Objects[1].(*widget.Check)
+	size := c.Objects[2].(*widget.Label)
+
+	cluster := v.clusters[i]
+	label.SetText(fmt.Sprintf("%s · %s (%d copies)", cluster.Subject, cluster.From, cluster.Count))
+	size.SetText(components.FormatSize(cluster.Size))
+
+	// Rows are recycled, so the check always reflects v.checked
+	check.OnChanged = nil
+	check.SetChecked(v.checked[cluster.Fingerprint])
+	check.OnChanged = func(checked bool) {
+		v.checked[cluster.Fingerprint] = checked
+	}
+}
+
+func (v *duplicatesView) updateCopyRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	title := c.Objects[0].(*widget.Label)
+	details := c.Objects[1].(*widget.Label)
+
+	email := v.copies[i]
+	status := "Duplicate"
+	if i == 0 {
+		status = "Kept"
+	}
+	title.SetText(fmt.Sprintf("%s: %s", status, email.ID))
+	details.SetText(fmt.Sprintf("%s · %s · labels: %s",
+		email.Date, components.FormatSize(email.SizeEstimate), strings.Join(email.Labels, ", ")))
+}
+
+// load fetches the clusters in the background
+func (v *duplicatesView) load() {
+	a := v.app
+	go func() {
+		clusters, err := a.db.ListDuplicateClusters(a.ctx, duplicateClusterLimit)
+		fyne.Do(func() {
+			if err != nil {
+				v.total.SetText("Unable to load duplicates")
+				dialog.ShowError(err, v.window)
+				return
+			}
+
+			var copies int
+			for _, c := range clusters {
+				copies += c.Count - 1
+			}
+			v.total.SetText(fmt.Sprintf("%d emails have %d extra copies", len(clusters), copies))
+			v.clusters = clusters
+			v.checked = make(map[string]bool)
+			v.copies = nil
+			v.clusterList.UnselectAll()
+			v.clusterList.Refresh()
+			v.copyList.Refresh()
+		})
+	}()
+}
+
+// showCopies lists the copies of the selected cluster
+func (v *duplicatesView) showCopies(i widget.ListItemID) {
+	a := v.app
+	fingerprint := v.clusters[i].Fingerprint
+	go func() {
+		emails, err := a.db.GetDuplicates(a.ctx, fingerprint)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, v.window)
+				return
+			}
+			v.copies = emails
+			v.copyList.Refresh()
+		})
+	}()
+}
+
+// removeChecked keeps the oldest copy of each checked cluster and moves
+// the rest to the trash, the Gmail trash too if remote is set
+func (v *duplicatesView) removeChecked(remote bool) {
+	var fingerprints []string
+	var copies int
+	for _, c := range v.clusters {
+		if v.checked[c.Fingerprint] {
+			fingerprints = append(fingerprints, c.Fingerprint)
+			copies += c.Count - 1
+		}
+	}
+	if len(fingerprints) == 0 {
+		dialog.ShowInformation("No Selection", "Please check duplicates to remove", v.window)
+		return
+	}
+
+	where := "the local trash"
+	if remote {
+		where = "the local and Gmail trash"
+	}
+	msg := fmt.Sprintf("Keep one copy of %d email(s) and move %d extra copies to %s?", len(fingerprints), copies, where)
+	dialog.ShowConfirm("Remove Duplicates", msg, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		a := v.app
+		go func() {
+			batch, removed, err := handlers.RemoveDuplicates(a.ctx, a.db, fingerprints, remote)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, v.window)
+				}
+				if len(batch.IDs) > 0 {
+					a.snackbar.Show(fmt.Sprintf("Moved %d duplicates (%s) to the trash",
+						len(batch.IDs), components.FormatSize(removed)), func() {
+						a.undoTrash(batch)
+					})
+				}
+				a.refreshView()
+				v.load()
+			})
+		}()
+	}, v.window)
+}
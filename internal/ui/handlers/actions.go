@@ -1,26 +1,186 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
-	"github.com/HoustonMiles/gmailScraper/internal/database"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
 	"github.com/HoustonMiles/gmailScraper/internal/gmail"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
 )
 
-func SyncEmails(gmailClient *http.Client, db *pgxpool.Pool, maxResults int64) error {
-	// Fetch emails from Gmail
-	emails, err := gmail.FetchEmails(gmailClient, maxResults)
+const (
+	// saveBatchSize is how many emails are committed per transaction
+	saveBatchSize = 100
+	// saveFlushInterval commits a partial batch when fetching is slow, so
+	// little is lost if the process dies
+	saveFlushInterval = 5 * time.Second
+	// pipelineBuffer is how far fetching may run ahead of saving before
+	// it blocks
+	pipelineBuffer = 2 * saveBatchSize
+)
+
+// SyncProgress reports the state of a running sync
+type SyncProgress struct {
+	gmail.FetchProgress
+	Saved int64
+}
+
+// SyncEmails streams emails from Gmail into the database. Emails are
+// committed in batches as they arrive, each with a checkpoint, and a sync
+// that was interrupted resumes from the last checkpoint. onProgress may be
+// nil and is called from the fetching and saving goroutines. If ctx is
+// cancelled, everything fetched so far is still saved and ctx's error is
+// returned. It returns the IDs of the emails this run saved.
+func SyncEmails(ctx context.Context, gmailClient *http.Client, db store.Store, cfg config.GmailConfig, maxResults int64, onProgress func(SyncProgress)) (map[string]bool, error) {
+	if err := syncLabels(ctx, gmailClient, db); err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := db.GetSyncCheckpoint(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync checkpoint: %v", err)
+	}
+	if !checkpoint.IsZero() {
+		syncLog.Info("Resuming sync", "after", checkpoint.LastMessageID)
+	}
+
+	p := &syncPipeline{onProgress: onProgress, savedIDs: make(map[string]bool)}
+	emails := make(chan gmail.FetchedEmail, pipelineBuffer)
+	fetchErr := make(chan error, 1)
+
+	// Fetch stage, stopped early if saving fails
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	defer stopFetch()
+	go func() {
+		defer close(emails)
+		for fetched, err := range gmail.FetchEmails(fetchCtx, gmailClient, cfg, maxResults, checkpoint, p.fetched) {
+			if err != nil {
+				fetchErr <- err
+				return
+			}
+			select {
+			case emails <- fetched:
+			case <-fetchCtx.Done():
+				fetchErr <- fetchCtx.Err()
+				return
+			}
+		}
+		fetchErr <- nil
+	}()
+
+	// Save stage. A cancelled sync still keeps what it fetched, so saving
+	// does not use ctx.
+	saveCtx := context.WithoutCancel(ctx)
+	if err := p.save(saveCtx, db, emails, stopFetch); err != nil {
+		return p.savedIDs, fmt.Errorf("failed to save emails: %v", err)
+	}
+
+	if err := <-fetchErr; err != nil {
+		if ctx.Err() != nil {
+			return p.savedIDs, ctx.Err()
+		}
+		return p.savedIDs, fmt.Errorf("failed to fetch emails: %v", err)
+	}
+
+	// A complete sync starts from the top next time
+	return p.savedIDs, db.ClearSyncCheckpoint(saveCtx)
+}
+
+// syncLabels refreshes the label definitions
+func syncLabels(ctx context.Context, gmailClient *http.Client, db store.Store) error {
+	labels, err := gmail.FetchLabels(ctx, gmailClient)
 	if err != nil {
-		return fmt.Errorf("failed to fetch emails: %v", err)
+		return fmt.Errorf("failed to fetch labels: %v", err)
 	}
-	
-	// Save to database
-	err = database.SaveEmails(db, emails)
+	err = db.SaveLabels(ctx, labels)
 	if err != nil {
-		return fmt.Errorf("failed to save emails: %v", err)
+		return fmt.Errorf("failed to save labels: %v", err)
 	}
-	
 	return nil
 }
+
+type syncPipeline struct {
+	onProgress func(SyncProgress)
+
+	mu       sync.Mutex
+	progress SyncProgress
+
+	// savedIDs is only touched by the saving goroutine
+	savedIDs map[string]bool
+}
+
+func (p *syncPipeline) fetched(fp gmail.FetchProgress) {
+	p.mu.Lock()
+	p.progress.FetchProgress = fp
+	p.mu.Unlock()
+	p.report()
+}
+
+func (p *syncPipeline) saved(n int) {
+	p.mu.Lock()
+	p.progress.Saved += int64(n)
+	p.mu.Unlock()
+	p.report()
+}
+
+func (p *syncPipeline) report() {
+	if p.onProgress == nil {
+		return
+	}
+	p.mu.Lock()
+	progress := p.progress
+	p.mu.Unlock()
+	p.onProgress(progress)
+}
+
+// save commits emails in batches until the channel is closed. If saving
+// fails it stops the fetch stage and drains the channel so it can finish.
+func (p *syncPipeline) save(ctx context.Context, db store.Store, emails <-chan gmail.FetchedEmail, stopFetch func()) error {
+	batch := make([]models.Email, 0, saveBatchSize)
+	var checkpoint models.SyncCheckpoint
+	var saveErr error
+
+	flush := func() {
+		if len(batch) == 0 || saveErr != nil {
+			return
+		}
+		saveErr = db.SaveEmailBatch(ctx, batch, checkpoint)
+		if saveErr != nil {
+			stopFetch()
+		} else {
+			for _, email := range batch {
+				p.savedIDs[email.ID] = true
+			}
+			p.saved(len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(saveFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case fetched, ok := <-emails:
+			if !ok {
+				flush()
+				return saveErr
+			}
+			if saveErr != nil {
+				continue
+			}
+			batch = append(batch, fetched.Email)
+			checkpoint = fetched.Checkpoint()
+			if len(batch) >= saveBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
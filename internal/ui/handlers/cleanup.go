@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// Actions a cleanup step can take on the emails it found
+const (
+	CleanupTrash       = "trash"
+	CleanupArchive     = "archive"
+	CleanupUnsubscribe = "unsubscribe"
+)
+
+const (
+	// cleanupSenderLimit is how many senders a step lists for review
+	cleanupSenderLimit = 100
+	// cleanupEmailLimit is how many emails a per-email step lists
+	cleanupEmailLimit = 200
+)
+
+// CleanupStep is one category of the cleanup wizard
+type CleanupStep struct {
+	Title       string
+	Description string
+	// Action is one of CleanupTrash, CleanupArchive or CleanupUnsubscribe
+	Action string
+	Filter models.EmailFilter
+	// PerEmail lists single emails for review instead of senders
+	PerEmail bool
+	// Duplicates lists duplicate clusters instead and ignores Filter
+	Duplicates bool
+}
+
+// CleanupItem is a reviewable row of a step: a sender and their emails
+// in the step's category, or a single email
+type CleanupItem struct {
+	Title  string
+	Count  int
+	Size   int64
+	Filter models.EmailFilter
+	// IDs is set for single emails and the extra copies of a duplicate
+	// cluster, otherwise Filter selects the emails
+	IDs []string
+}
+
+// CleanupResult is what running a step's action did
+type CleanupResult struct {
+	Step   string
+	Action string
+	Emails int
+	// Reclaimed is the size of the emails moved to the Gmail trash
+	Reclaimed int64
+	// Senders is how many senders were unsubscribed from
+	Senders int
+}
+
+// CleanupSteps returns the wizard's categories, oldest cut-offs counted
+// back from now
+func CleanupSteps(cfg config.CleanupConfig, now time.Time) []CleanupStep {
+	before := now.AddDate(0, -cfg.OlderThanMonths, 0)
+	return []CleanupStep{
+		{
+			Title:       "Noisy senders",
+			Description: "The mailing lists you get the most mail from. Unsubscribing sends each sender a one-click request where it takes one and opens its unsubscribe link otherwise.",
+			Action:      CleanupUnsubscribe,
+			Filter:      models.EmailFilter{Unsubscribable: true},
+		},
+		{
+			Title:       "Old promotions",
+			Description: fmt.Sprintf("Emails in the Promotions category older than %d months.", cfg.OlderThanMonths),
+			Action:      CleanupTrash,
+			Filter:      models.EmailFilter{Label: "CATEGORY_PROMOTIONS", Before: before},
+		},
+		{
+			Title:       "Large attachments",
+			Description: fmt.Sprintf("Messages of %d MB or more, largest first.", cfg.LargeMessageMB),
+			Action:      CleanupTrash,
+			Filter:      models.EmailFilter{MinSize: int64(cfg.LargeMessageMB) << 20},
+			PerEmail:    true,
+		},
+		{
+			Title:       "Unread newsletters",
+			Description: fmt.Sprintf("Newsletters older than %d months you never opened. Archiving takes them out of the inbox.", cfg.OlderThanMonths),
+			Action:      CleanupArchive,
+			Filter:      models.EmailFilter{Label: "UNREAD", Unsubscribable: true, Before: before},
+		},
+		{
+			Title:       "Duplicates",
+			Description: "Emails saved more than once, e.g. by imports. The oldest copy of each is kept.",
+			Action:      CleanupTrash,
+			Duplicates:  true,
+		},
+	}
+}
+
+// LoadCleanupItems returns the reviewable rows of a step and the count and
+// size of everything in its category
+func LoadCleanupItems(ctx context.Context, db store.Store, step CleanupStep) ([]CleanupItem, models.EmailSummary, error) {
+	if step.Duplicates {
+		return loadDuplicateItems(ctx, db, step)
+	}
+
+	summary, err := db.SummarizeEmails(ctx, step.Filter)
+	if err != nil {
+		return nil, summary, fmt.Errorf("failed to summarize %s: %v", step.Title, err)
+	}
+
+	var items []CleanupItem
+	if step.PerEmail {
+		emails, err := db.LargestEmails(ctx, step.Filter, cleanupEmailLimit)
+		if err != nil {
+			return nil, summary, fmt.Errorf("failed to load %s: %v", step.Title, err)
+		}
+		for _, email := range emails {
+			items = append(items, CleanupItem{
+				Title: fmt.Sprintf("%s · %s", email.Subject, email.From),
+				Count: 1,
+				Size:  email.SizeEstimate,
+				IDs:   []string{email.ID},
+			})
+		}
+		return items, summary, nil
+	}
+
+	senders, err := db.TopSenders(ctx, step.Filter, cleanupSenderLimit)
+	if err != nil {
+		return nil, summary, fmt.Errorf("failed to load %s: %v", step.Title, err)
+	}
+	for _, sender := range senders {
+		filter := step.Filter
+		filter.Sender = sender.Address
+		items = append(items, CleanupItem{
+			Title:  sender.Address,
+			Count:  sender.Count,
+			Size:   sender.Size,
+			Filter: filter,
+		})
+	}
+	return items, summary, nil
+}
+
+// loadDuplicateItems lists the clusters with most copies, counting only
+// the copies that would be removed
+func loadDuplicateItems(ctx context.Context, db store.Store, step CleanupStep) ([]CleanupItem, models.EmailSummary, error) {
+	var summary models.EmailSummary
+	clusters, err := db.ListDuplicateClusters(ctx, cleanupSenderLimit)
+	if err != nil {
+		return nil, summary, fmt.Errorf("failed to load %s: %v", step.Title, err)
+	}
+
+	var items []CleanupItem
+	for _, cluster := range clusters {
+		emails, err := db.GetDuplicates(ctx, cluster.Fingerprint)
+		if err != nil {
+			return nil, summary, fmt.Errorf("failed to load %s: %v", step.Title, err)
+		}
+		item := CleanupItem{Title: fmt.Sprintf("%s · %s", cluster.Subject, cluster.From)}
+		for _, email := range DuplicateCopies(emails) {
+			item.IDs = append(item.IDs, email.ID)
+			item.Size += email.SizeEstimate
+		}
+		if len(item.IDs) == 0 {
+			continue
+		}
+		item.Count = len(item.IDs)
+		items = append(items, item)
+		summary.Count += item.Count
+		summary.Size += item.Size
+	}
+	return items, summary, nil
+}
+
+// RunCleanup takes a step's action on the chosen items. Trashing and
+// archiving are queued for Gmail as well as done locally, since the point
+// is to shrink the mailbox. One-click unsubscribes are queued too if
+// oneClick is set; the other unsubscribe links are returned for the
+// caller to open. On error the result covers the items done before it.
+func RunCleanup(ctx context.Context, db store.Store, step CleanupStep, items []CleanupItem, oneClick bool) (CleanupResult, []UnsubscribeLink, error) {
+	result := CleanupResult{Step: step.Title, Action: step.Action}
+
+	if step.Action == CleanupUnsubscribe {
+		var links []UnsubscribeLink
+		for _, item := range items {
+			found, _, err := UnsubscribeLinks(ctx, db, item.Filter)
+			if err != nil {
+				return result, nil, err
+			}
+			links = append(links, found...)
+		}
+		result.Senders = len(links)
+		toOpen, err := Unsubscribe(ctx, db, links, oneClick)
+		return result, toOpen, err
+	}
+
+	for _, item := range items {
+		ids := item.IDs
+		if ids == nil {
+			var err error
+			ids, err = db.ListEmailIDs(ctx, item.Filter)
+			if err != nil {
+				return result, nil, fmt.Errorf("failed to load emails of %s: %v", item.Title, err)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		switch step.Action {
+		case CleanupTrash:
+			batch, err := TrashEmails(ctx, db, ids, true)
+			result.Emails += len(batch.IDs)
+			if err != nil {
+				return result, nil, err
+			}
+			result.Reclaimed += item.Size
+		case CleanupArchive:
+			if err := ArchiveEmails(ctx, db, ids); err != nil {
+				return result, nil, err
+			}
+			result.Emails += len(ids)
+		}
+	}
+	return result, nil, nil
+}
+
+// ArchiveEmails takes emails out of the inbox locally and queues the
+// same for Gmail
+func ArchiveEmails(ctx context.Context, db store.Store, ids []string) error {
+	if _, err := ModifyEmailLabels(ctx, db, ids, nil, []string{"INBOX"}); err != nil {
+		return fmt.Errorf("failed to archive emails: %v", err)
+	}
+	return nil
+}
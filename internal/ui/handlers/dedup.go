@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// DuplicateCopies returns every email of a cluster except the one kept,
+// which is the oldest. emails must be ordered as store.DedupStore returns
+// them.
+func DuplicateCopies(emails []models.Email) []models.Email {
+	if len(emails) < 2 {
+		return nil
+	}
+	return emails[1:]
+}
+
+// RemoveDuplicates keeps one copy of each cluster and moves the rest to
+// the local trash, queueing them for the Gmail trash too if remote is set.
+// It returns the batch for undoing and the size of the copies removed.
+func RemoveDuplicates(ctx context.Context, db store.Store, fingerprints []string, remote bool) (TrashedBatch, int64, error) {
+	var ids []string
+	sizes := make(map[string]int64)
+	for _, fingerprint := range fingerprints {
+		emails, err := db.GetDuplicates(ctx, fingerprint)
+		if err != nil {
+			return TrashedBatch{}, 0, fmt.Errorf("failed to load duplicates: %v", err)
+		}
+		for _, email := range DuplicateCopies(emails) {
+			ids = append(ids, email.ID)
+			sizes[email.ID] = email.SizeEstimate
+		}
+	}
+	if len(ids) == 0 {
+		return TrashedBatch{}, 0, nil
+	}
+
+	batch, err := TrashEmails(ctx, db, ids, remote)
+	var removed int64
+	for _, id := range batch.IDs {
+		removed += sizes[id]
+	}
+	return batch, removed, err
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// sendersPageSize is how many senders are read at a time when collecting
+// unsubscribe links
+const sendersPageSize = 500
+
+// UnsubscribeLink is where a sender accepts unsubscribe requests
+type UnsubscribeLink struct {
+	Sender string
+	// URL is an https:// or mailto: link from the List-Unsubscribe header
+	URL string
+	// OneClick is set if the sender takes a POST to URL as the request,
+	// as RFC 8058 describes, so no page has to be opened
+	OneClick bool
+}
+
+// UnsubscribeLinks returns the unsubscribe link of every sender matching
+// filter, taken from the newest email of each. Senders without one are
+// returned in missing.
+func UnsubscribeLinks(ctx context.Context, db store.Store, filter models.EmailFilter) (links []UnsubscribeLink, missing []string, err error) {
+	after := ""
+	for {
+		senders, err := db.ListSenders(ctx, filter, false, after, sendersPageSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list senders: %v", err)
+		}
+
+		for _, sender := range senders {
+			senderFilter := filter
+			senderFilter.Sender = sender.Address
+			emails, _, err := db.ListEmails(ctx, senderFilter, config.SortDateNewest, nil, 1)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load emails from %s: %v", sender.Address, err)
+			}
+
+			var link string
+			var oneClick bool
+			if len(emails) > 0 {
+				link, oneClick = unsubscribeURL(emails[0].Headers)
+			}
+			if link == "" {
+				missing = append(missing, sender.Address)
+			} else {
+				links = append(links, UnsubscribeLink{Sender: sender.Address, URL: link, OneClick: oneClick})
+			}
+			after = sender.Address
+		}
+
+		if len(senders) < sendersPageSize {
+			return links, missing, nil
+		}
+	}
+}
+
+// unsubscribeURL picks the link to use from a List-Unsubscribe header,
+// which lists <...> entries in order of preference. A web link is
+// preferred over a mailto: one. oneClick reports whether an https link
+// takes a one-click POST, announced by List-Unsubscribe-Post.
+func unsubscribeURL(headers string) (link string, oneClick bool) {
+	var web, mailto string
+	for _, line := range strings.Split(headers, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "list-unsubscribe":
+			for _, entry := range strings.Split(value, ",") {
+				link := strings.Trim(strings.TrimSpace(entry), "<>")
+				switch {
+				case (strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")) && web == "":
+					web = link
+				case strings.HasPrefix(link, "mailto:") && mailto == "":
+					mailto = link
+				}
+			}
+		case "list-unsubscribe-post":
+			oneClick = strings.EqualFold(strings.TrimSpace(value), oneClickBody)
+		}
+	}
+	if web == "" {
+		return mailto, false
+	}
+	return web, oneClick && strings.HasPrefix(web, "https://")
+}
+
+// oneClickBody is the form body of an RFC 8058 unsubscribe request
+const oneClickBody = "List-Unsubscribe=One-Click"
+
+// Unsubscribe queues the one-click unsubscribes among links if oneClick is
+// set and returns the rest, which must be opened in a browser or mail
+// client
+func Unsubscribe(ctx context.Context, db store.Store, links []UnsubscribeLink, oneClick bool) (toOpen []UnsubscribeLink, err error) {
+	for _, link := range links {
+		if !oneClick || !link.OneClick {
+			toOpen = append(toOpen, link)
+			continue
+		}
+		if err := queueAction(ctx, db, models.PendingAction{Kind: models.ActionUnsubscribe, URL: link.URL}); err != nil {
+			return toOpen, err
+		}
+	}
+	return toOpen, nil
+}
+
+// unsubscribeClient sends one-click unsubscribe requests. It refuses to
+// connect to local and private addresses, so a link cannot reach this
+// machine or its network, including through a redirect or a name that
+// resolves there.
+var unsubscribeClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: refuseLocal}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// errLocalAddress is returned for unsubscribe links that lead to a local
+// or private address
+var errLocalAddress = errors.New("unsubscribe link leads to a local or private address")
+
+// refuseLocal stops a connection to a loopback, private, link-local or
+// unspecified address
+func refuseLocal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errLocalAddress, ip)
+	}
+	return nil
+}
+
+// errTemporary marks a failure worth retrying that did not come from Gmail
+var errTemporary = errors.New("temporary failure")
+
+// postUnsubscribe sends an RFC 8058 one-click unsubscribe request. Network
+// errors, rate limits and server errors wrap errTemporary.
+func postUnsubscribe(ctx context.Context, link string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, link, strings.NewReader(oneClickBody))
+	if err != nil {
+		return fmt.Errorf("invalid unsubscribe link: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := unsubscribeClient.Do(req)
+	if errors.Is(err, errLocalAddress) {
+		// Not temporary, so the action fails rather than being retried
+		return fmt.Errorf("unable to send unsubscribe request: %v", err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: unable to send unsubscribe request: %v", errTemporary, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: unsubscribe request failed: %s", errTemporary, resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("unsubscribe request refused: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRefuseLocal(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{"93.184.215.14:443", false},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", false},
+		{"127.0.0.1:443", true},
+		{"127.8.9.10:80", true},
+		{"[::1]:443", true},
+		{"10.1.2.3:443", true},
+		{"172.16.0.1:443", true},
+		{"192.168.1.1:80", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1]:443", true},
+		{"[fd00::1]:443", true},
+		{"0.0.0.0:443", true},
+		{"[::ffff:127.0.0.1]:443", true},
+		{"[::ffff:10.0.0.1]:443", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := refuseLocal("tcp", tt.address, nil)
+			if refused := errors.Is(err, errLocalAddress); refused != tt.refused {
+				t.Errorf("refuseLocal(%s) = %v, want refused %v", tt.address, err, tt.refused)
+			}
+		})
+	}
+}
+
+func TestPostUnsubscribeRefusesLoopback(t *testing.T) {
+	reached := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer srv.Close()
+
+	err := postUnsubscribe(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("postUnsubscribe to a loopback address succeeded")
+	}
+	if retryable(err) {
+		t.Errorf("postUnsubscribe error %q is retryable, want it to fail the action", err)
+	}
+	if reached {
+		t.Error("the request reached the loopback server")
+	}
+}
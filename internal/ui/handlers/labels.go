@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// LabelEmails applies a label to emails locally and queues it for Gmail
+func LabelEmails(ctx context.Context, db store.Store, ids []string, labelID string) error {
+	_, err := ModifyEmailLabels(ctx, db, ids, []string{labelID}, nil)
+	return err
+}
+
+// ModifyEmailLabels queues adding and removing labels on emails for Gmail
+// and records the change locally, so the list reflects it right away.
+// Only the emails whose labels change, going by the store, are touched;
+// they are returned so that undoing the change leaves the rest alone.
+func ModifyEmailLabels(ctx context.Context, db store.Store, ids []string, add, remove []string) ([]string, error) {
+	if len(ids) == 0 || len(add)+len(remove) == 0 {
+		return nil, nil
+	}
+	changed, err := labelsChange(ctx, db, ids, add, remove)
+	if err != nil || len(changed) == 0 {
+		return nil, err
+	}
+	err = queueAction(ctx, db, models.PendingAction{Kind: models.ActionLabels, EmailIDs: changed, AddLabels: add, RemoveLabels: remove})
+	if err != nil {
+		return nil, err
+	}
+	return changed, saveLabelChange(ctx, db, changed, add, remove)
+}
+
+// labelsChange returns the emails among ids that lack a label of add or
+// carry one of remove, in the order of ids
+func labelsChange(ctx context.Context, db store.Store, ids []string, add, remove []string) ([]string, error) {
+	changes := make(map[string]bool, len(ids))
+	for _, labelID := range add {
+		labeled, err := labeledEmails(ctx, db, ids, labelID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !labeled[id] {
+				changes[id] = true
+			}
+		}
+	}
+	for _, labelID := range remove {
+		labeled, err := labeledEmails(ctx, db, ids, labelID)
+		if err != nil {
+			return nil, err
+		}
+		for id := range labeled {
+			changes[id] = true
+		}
+	}
+
+	var changed []string
+	for _, id := range ids {
+		if changes[id] {
+			changed = append(changed, id)
+		}
+	}
+	return changed, nil
+}
+
+// labeledEmails returns which of ids carry labelID, in or out of the
+// trash
+func labeledEmails(ctx context.Context, db store.Store, ids []string, labelID string) (map[string]bool, error) {
+	labeled := make(map[string]bool)
+	for batch := range slices.Chunk(ids, idBatchSize) {
+		for _, trash := range []bool{false, true} {
+			matched, err := db.ListEmailIDs(ctx, models.EmailFilter{Label: labelID, Trash: trash, IDs: batch})
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up labels: %v", err)
+			}
+			for _, id := range matched {
+				labeled[id] = true
+			}
+		}
+	}
+	return labeled, nil
+}
+
+// saveLabelChange records a label change in the store
+func saveLabelChange(ctx context.Context, db store.Store, ids []string, add, remove []string) error {
+	for _, labelID := range add {
+		if err := db.AddEmailLabel(ctx, ids, labelID); err != nil {
+			return fmt.Errorf("failed to save labels: %v", err)
+		}
+	}
+	for _, labelID := range remove {
+		if err := db.RemoveEmailLabel(ctx, ids, labelID); err != nil {
+			return fmt.Errorf("failed to save labels: %v", err)
+		}
+	}
+	return nil
+}
+
+// CreateLabel adds a user label in Gmail and saves its definition
+func CreateLabel(ctx context.Context, gmailClient *http.Client, db store.Store, name string) (models.Label, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Label{}, errors.New("label name must not be empty")
+	}
+	label, err := gmail.CreateLabel(ctx, gmailClient, name)
+	if err != nil {
+		return models.Label{}, err
+	}
+	if err := db.SaveLabels(ctx, []models.Label{label}); err != nil {
+		return label, fmt.Errorf("failed to save label: %v", err)
+	}
+	return label, nil
+}
+
+// assignableSystemLabels are the system labels Gmail lets a user add or
+// remove by hand
+var assignableSystemLabels = map[string]bool{
+	"INBOX": true, "STARRED": true, "IMPORTANT": true, "UNREAD": true, "SPAM": true,
+}
+
+// Assignable reports whether label can be applied to or removed from
+// emails. Other system labels such as SENT and DRAFT are set by Gmail.
+func Assignable(label models.Label) bool {
+	return label.Type == "user" || assignableSystemLabels[label.ID]
+}
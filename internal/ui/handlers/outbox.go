@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+var outboxLog = logging.For("outbox")
+
+const (
+	// maxActionAttempts is how often an action is tried while its
+	// destination is unreachable before it is given up on
+	maxActionAttempts = 10
+	// retryBackoff is the wait after the first failed attempt, doubled
+	// after each further one up to maxRetryBackoff
+	retryBackoff    = 30 * time.Second
+	maxRetryBackoff = time.Hour
+	// actionLeaseTTL is how long a worker holds an action it is sending
+	// before another may take it over
+	actionLeaseTTL = 5 * time.Minute
+)
+
+// outboxWake nudges RunOutbox to send newly queued actions right away
+// rather than at its next tick
+var outboxWake = make(chan struct{}, 1)
+
+// queueAction adds an action to the outbox and wakes the worker
+func queueAction(ctx context.Context, db store.Store, action models.PendingAction) error {
+	action.CreatedAt = time.Now()
+	if err := db.QueueAction(ctx, &action); err != nil {
+		return fmt.Errorf("failed to queue %s: %v", action.Kind, err)
+	}
+	wakeOutbox()
+	return nil
+}
+
+func wakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// RetryAction makes a failed or conflicting action pending again
+func RetryAction(ctx context.Context, db store.Store, id int64) error {
+	if err := db.RetryAction(ctx, id); err != nil {
+		return err
+	}
+	wakeOutbox()
+	return nil
+}
+
+// DiscardAction drops an action that failed or conflicts with Gmail. A
+// failed action never reached Gmail, so its local change is undone to
+// match Gmail again; after a conflict the sync already did that.
+func DiscardAction(ctx context.Context, db store.Store, action models.PendingAction) error {
+	if action.Status == models.ActionFailed {
+		if err := applyLocally(ctx, db, inverse(action)); err != nil {
+			return err
+		}
+	}
+	return db.DeleteAction(ctx, action.ID)
+}
+
+// SendPendingActions sends the pending actions that are due, oldest
+// first, and returns how many were accepted. Gmail actions are sent in
+// the order they were made, so they stop at the first one that is not
+// due, is held by another worker or cannot reach Gmail.
+func SendPendingActions(ctx context.Context, gmailClient *http.Client, db store.Store) (int, error) {
+	actions, err := db.ListActions(ctx, models.ActionPending)
+	if err != nil {
+		return 0, err
+	}
+
+	// Outcomes are recorded even if ctx is cancelled
+	saveCtx := context.WithoutCancel(ctx)
+	sent := 0
+	gmailBlocked := false
+	for _, action := range actions {
+		viaGmail := action.Kind != models.ActionUnsubscribe
+		if viaGmail && gmailBlocked {
+			continue
+		}
+		if action.NextAttemptAt.After(time.Now()) {
+			gmailBlocked = gmailBlocked || viaGmail
+			continue
+		}
+		claimed, err := db.ClaimAction(ctx, action.ID, actionLeaseTTL)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			gmailBlocked = gmailBlocked || viaGmail
+			continue
+		}
+
+		err = sendAction(ctx, gmailClient, action)
+		switch {
+		case err == nil:
+			// An unsubscribe leaves nothing for a sync to confirm
+			if action.Kind == models.ActionUnsubscribe {
+				err = db.DeleteAction(saveCtx, action.ID)
+			} else {
+				err = db.MarkActionSent(saveCtx, action.ID, time.Now())
+			}
+			if err != nil {
+				return sent, err
+			}
+			sent++
+		case ctx.Err() != nil:
+			if err := db.SetActionStatus(saveCtx, action.ID, models.ActionPending, action.LastError); err != nil {
+				return sent, err
+			}
+			return sent, ctx.Err()
+		case retryable(err) && action.Attempts+1 < maxActionAttempts:
+			retryAt := time.Now().Add(backoff(action.Attempts + 1))
+			if err := db.RecordActionFailure(saveCtx, action.ID, err.Error(), retryAt); err != nil {
+				return sent, err
+			}
+			outboxLog.Warn("Unable to send action, will retry", "action", action.ID, "kind", action.Kind,
+				"attempts", action.Attempts+1, "retry_at", retryAt.Format(time.TimeOnly), "err", err)
+			gmailBlocked = gmailBlocked || viaGmail
+		default:
+			if err := db.SetActionStatus(saveCtx, action.ID, models.ActionFailed, err.Error()); err != nil {
+				return sent, err
+			}
+			outboxLog.Error("Giving up on action", "action", action.ID, "kind", action.Kind,
+				"attempts", action.Attempts+1, "err", err)
+		}
+	}
+	if sent > 0 {
+		outboxLog.Info("Sent queued actions", "count", sent)
+	}
+	return sent, nil
+}
+
+// sendAction makes the change an action describes in Gmail, or at the
+// sender for an unsubscribe
+func sendAction(ctx context.Context, gmailClient *http.Client, action models.PendingAction) error {
+	switch action.Kind {
+	case models.ActionLabels:
+		return gmail.ModifyLabels(ctx, gmailClient, action.EmailIDs, action.AddLabels, action.RemoveLabels)
+	case models.ActionTrash:
+		_, err := gmail.TrashMessages(ctx, gmailClient, action.EmailIDs)
+		return err
+	case models.ActionUntrash:
+		_, err := gmail.UntrashMessages(ctx, gmailClient, action.EmailIDs)
+		return err
+	case models.ActionUnsubscribe:
+		return postUnsubscribe(ctx, action.URL)
+	default:
+		return fmt.Errorf("unknown action %q", action.Kind)
+	}
+}
+
+// retryable reports whether err may go away by itself
+func retryable(err error) bool {
+	return errors.Is(err, gmail.ErrUnreachable) || errors.Is(err, errTemporary)
+}
+
+// backoff is the wait before the next attempt after attempts failures
+func backoff(attempts int) time.Duration {
+	wait := retryBackoff
+	for i := 1; i < attempts && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetryBackoff)
+}
+
+// RunOutbox sends pending actions every interval, and whenever one is
+// queued in this process, until ctx is cancelled
+func RunOutbox(ctx context.Context, gmailClient *http.Client, db store.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := SendPendingActions(ctx, gmailClient, db); err != nil && ctx.Err() == nil {
+			outboxLog.Error("Unable to send queued actions", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-outboxWake:
+		}
+	}
+}
+
+// reconcileActions checks the outbox against a sync that started at
+// syncStart. Actions Gmail accepted before then are removed if the synced
+// emails agree with them, and marked as conflicts if not. Actions still
+// outstanding are applied locally again, since the sync may have
+// overwritten them with what Gmail has, unless an email they change was
+// deleted in Gmail meanwhile. synced, if not nil, holds the only emails
+// the sync saved; no other email is checked.
+func reconcileActions(ctx context.Context, db store.Store, changes gmail.MailboxChanges, synced map[string]bool, syncStart time.Time) error {
+	actions, err := db.ListActions(ctx, "")
+	if err != nil {
+		return err
+	}
+	deleted := make(map[string]bool, len(changes.Deleted))
+	for _, id := range changes.Deleted {
+		deleted[id] = true
+	}
+
+	// Actions are sent in order, so the ones the sync can have seen come
+	// before those sent while it ran, which come before the pending ones
+	var seen, outstanding []models.PendingAction
+	for _, action := range actions {
+		if action.Kind == models.ActionUnsubscribe {
+			continue
+		}
+		switch {
+		case action.Status == models.ActionSent && action.SentAt.Before(syncStart):
+			seen = append(seen, action)
+		case action.Status == models.ActionSent || action.Status == models.ActionPending:
+			outstanding = append(outstanding, action)
+		}
+	}
+
+	conflicts, err := checkActions(ctx, db, seen, outstanding, deleted, synced)
+	if err != nil {
+		return err
+	}
+	var confirmed int
+	for _, action := range seen {
+		if conflict, ok := conflicts[action.ID]; ok {
+			if err := markConflict(ctx, db, action, conflict); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := db.DeleteAction(ctx, action.ID); err != nil {
+			return err
+		}
+		confirmed++
+	}
+
+	for _, action := range outstanding {
+		if action.Status == models.ActionPending {
+			if id := firstDeleted(action, deleted); id != "" {
+				conflict := fmt.Sprintf("email %s was deleted in Gmail", id)
+				if err := markConflict(ctx, db, action, conflict); err != nil {
+					return err
+				}
+				conflicts[action.ID] = conflict
+				continue
+			}
+		}
+		if err := applyLocally(ctx, db, action); err != nil {
+			return err
+		}
+	}
+	if confirmed+len(conflicts) > 0 {
+		outboxLog.Info("Reconciled outbox with Gmail", "confirmed", confirmed, "conflicts", len(conflicts))
+	}
+	return nil
+}
+
+// labelKey is one label of one email
+type labelKey struct {
+	email, label string
+}
+
+// labelEffect is whether an action leaves an email with a label. Being in
+// the trash counts as the TRASH label.
+type labelEffect struct {
+	label string
+	has   bool
+}
+
+func labelEffects(action models.PendingAction) []labelEffect {
+	var effects []labelEffect
+	switch action.Kind {
+	case models.ActionLabels:
+		for _, label := range action.AddLabels {
+			effects = append(effects, labelEffect{label, true})
+		}
+		for _, label := range action.RemoveLabels {
+			effects = append(effects, labelEffect{label, false})
+		}
+	case models.ActionTrash:
+		effects = append(effects, labelEffect{"TRASH", true})
+	case models.ActionUntrash:
+		effects = append(effects, labelEffect{"TRASH", false})
+	}
+	return effects
+}
+
+// checkActions compares what the seen actions, taken in order, leave each
+// email and label with against what the sync saved. Only the net result
+// is compared, and a disagreement is blamed on the last action that
+// touched the email and label; if that was sent while the sync ran, the
+// sync may not show it yet and nothing is blamed. Emails missing from
+// synced are skipped unless it is nil. It returns the first disagreement
+// found for each action blamed.
+func checkActions(ctx context.Context, db store.Store, seen, outstanding []models.PendingAction, deleted, synced map[string]bool) (map[int64]string, error) {
+	isSeen := make(map[int64]bool, len(seen))
+	for _, action := range seen {
+		isSeen[action.ID] = true
+	}
+
+	want := make(map[labelKey]bool)
+	last := make(map[labelKey]models.PendingAction)
+	lastOnEmail := make(map[string]models.PendingAction)
+	var keys []labelKey
+	for _, action := range slices.Concat(seen, outstanding) {
+		for _, id := range action.EmailIDs {
+			lastOnEmail[id] = action
+			for _, effect := range labelEffects(action) {
+				key := labelKey{id, effect.label}
+				if _, ok := last[key]; !ok {
+					keys = append(keys, key)
+				}
+				want[key] = effect.has
+				last[key] = action
+			}
+		}
+	}
+
+	conflicts := make(map[int64]string)
+	blame := func(action models.PendingAction, conflict string) {
+		if _, ok := conflicts[action.ID]; !ok && isSeen[action.ID] {
+			conflicts[action.ID] = conflict
+		}
+	}
+	emails := make(map[string]*models.Email)
+	for _, key := range keys {
+		if synced != nil && !synced[key.email] {
+			// What Gmail has for it is unknown, so it is taken to agree
+			continue
+		}
+		email, ok := emails[key.email]
+		if !ok {
+			e, err := db.GetEmail(ctx, key.email)
+			switch {
+			case deleted[key.email] || errors.Is(err, models.ErrNotFound):
+				// Deleting an email is as good as trashing it
+				if action := lastOnEmail[key.email]; action.Kind != models.ActionTrash {
+					blame(action, fmt.Sprintf("email %s was deleted in Gmail", key.email))
+				}
+			case err != nil:
+				return nil, err
+			default:
+				email = &e
+			}
+			emails[key.email] = email
+		}
+		if email == nil || email.HasLabel(key.label) == want[key] {
+			continue
+		}
+
+		var conflict string
+		switch {
+		case key.label == "TRASH" && want[key]:
+			conflict = fmt.Sprintf("Gmail shows email %s outside the trash", key.email)
+		case key.label == "TRASH":
+			conflict = fmt.Sprintf("Gmail shows email %s still in the trash", key.email)
+		case want[key]:
+			conflict = fmt.Sprintf("Gmail shows email %s without label %s", key.email, key.label)
+		default:
+			conflict = fmt.Sprintf("Gmail shows email %s still labeled %s", key.email, key.label)
+		}
+		blame(last[key], conflict)
+	}
+	return conflicts, nil
+}
+
+// firstDeleted returns an email of action that was deleted in Gmail, ""
+// if none was or the action trashes them anyway
+func firstDeleted(action models.PendingAction, deleted map[string]bool) string {
+	if action.Kind == models.ActionTrash {
+		return ""
+	}
+	for _, id := range action.EmailIDs {
+		if deleted[id] {
+			return id
+		}
+	}
+	return ""
+}
+
+func markConflict(ctx context.Context, db store.Store, action models.PendingAction, conflict string) error {
+	outboxLog.Warn("Action conflicts with Gmail", "action", action.ID, "kind", action.Kind, "conflict", conflict)
+	return db.SetActionStatus(ctx, action.ID, models.ActionConflict, conflict)
+}
+
+// applyLocally makes the change an action describes in the store
+func applyLocally(ctx context.Context, db store.Store, action models.PendingAction) error {
+	switch action.Kind {
+	case models.ActionLabels:
+		return saveLabelChange(ctx, db, action.EmailIDs, action.AddLabels, action.RemoveLabels)
+	case models.ActionTrash:
+		return db.DeleteEmails(ctx, action.EmailIDs)
+	case models.ActionUntrash:
+		_, err := db.RestoreEmails(ctx, action.EmailIDs)
+		return err
+	}
+	return nil
+}
+
+// inverse returns the action that undoes action
+func inverse(action models.PendingAction) models.PendingAction {
+	undo := action
+	switch action.Kind {
+	case models.ActionLabels:
+		undo.AddLabels, undo.RemoveLabels = action.RemoveLabels, action.AddLabels
+	case models.ActionTrash:
+		undo.Kind = models.ActionUntrash
+	case models.ActionUntrash:
+		undo.Kind = models.ActionTrash
+	}
+	return undo
+}
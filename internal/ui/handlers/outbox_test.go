@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+// fakeStore keeps the emails and outbox reconcileActions works on in
+// memory. Calling any other store method panics.
+type fakeStore struct {
+	store.Store
+	emails  map[string]*models.Email
+	trashed map[string]bool
+	actions []models.PendingAction
+}
+
+func (f *fakeStore) GetEmail(ctx context.Context, id string) (models.Email, error) {
+	email, ok := f.emails[id]
+	if !ok {
+		return models.Email{}, models.ErrNotFound
+	}
+	return *email, nil
+}
+
+func (f *fakeStore) AddEmailLabel(ctx context.Context, ids []string, labelID string) error {
+	for _, id := range ids {
+		if email, ok := f.emails[id]; ok && !email.HasLabel(labelID) {
+			email.Labels = append(email.Labels, labelID)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) RemoveEmailLabel(ctx context.Context, ids []string, labelID string) error {
+	for _, id := range ids {
+		if email, ok := f.emails[id]; ok {
+			email.Labels = slices.DeleteFunc(email.Labels, func(l string) bool { return l == labelID })
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) DeleteEmails(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		f.trashed[id] = true
+	}
+	return nil
+}
+
+func (f *fakeStore) RestoreEmails(ctx context.Context, ids []string) (int64, error) {
+	var n int64
+	for _, id := range ids {
+		if f.trashed[id] {
+			delete(f.trashed, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) ListActions(ctx context.Context, status string) ([]models.PendingAction, error) {
+	var actions []models.PendingAction
+	for _, action := range f.actions {
+		if status == "" || action.Status == status {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+func (f *fakeStore) SetActionStatus(ctx context.Context, id int64, status, reason string) error {
+	for i := range f.actions {
+		if f.actions[i].ID == id {
+			f.actions[i].Status, f.actions[i].LastError = status, reason
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) DeleteAction(ctx context.Context, id int64) error {
+	f.actions = slices.DeleteFunc(f.actions, func(a models.PendingAction) bool { return a.ID == id })
+	return nil
+}
+
+func TestReconcileActions(t *testing.T) {
+	syncStart := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	before, during := syncStart.Add(-time.Minute), syncStart.Add(time.Minute)
+
+	sent := func(at time.Time, kind string, ids []string, add, remove []string) models.PendingAction {
+		return models.PendingAction{Kind: kind, EmailIDs: ids, AddLabels: add, RemoveLabels: remove,
+			Status: models.ActionSent, SentAt: at}
+	}
+	pending := func(kind string, ids []string, add, remove []string) models.PendingAction {
+		return models.PendingAction{Kind: kind, EmailIDs: ids, AddLabels: add, RemoveLabels: remove,
+			Status: models.ActionPending}
+	}
+	a := []string{"a"}
+	star := []string{"STARRED"}
+
+	// confirmed marks an action reconcileActions should remove
+	const confirmed = "confirmed"
+	tests := []struct {
+		name string
+		// synced are the labels the sync saved for each email; an email
+		// missing from it is not in the store
+		synced  map[string][]string
+		deleted []string
+		// full marks a full sync, which saved only the emails in saved
+		full    bool
+		saved   []string
+		actions []models.PendingAction
+		// want is the outcome of each action: confirmed or the status
+		// left, and for a conflict a part of its reason
+		want     []string
+		conflict []string
+		// labels, if set, are the labels of email a afterwards
+		labels []string
+	}{
+		{
+			name:    "agreeing action",
+			synced:  map[string][]string{"a": {"INBOX", "STARRED"}},
+			actions: []models.PendingAction{sent(before, models.ActionLabels, a, star, nil)},
+			want:    []string{confirmed},
+		},
+		{
+			name:     "disagreeing action",
+			synced:   map[string][]string{"a": {"INBOX"}},
+			actions:  []models.PendingAction{sent(before, models.ActionLabels, a, star, nil)},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"without label STARRED"},
+		},
+		{
+			name:   "star then unstar agree on the net result",
+			synced: map[string][]string{"a": {"INBOX"}},
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, a, star, nil),
+				sent(before, models.ActionLabels, a, nil, star),
+			},
+			want: []string{confirmed, confirmed},
+		},
+		{
+			name:   "only the last action on a label conflicts",
+			synced: map[string][]string{"a": {"INBOX", "STARRED"}},
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, a, star, nil),
+				sent(before, models.ActionLabels, a, nil, star),
+			},
+			want:     []string{confirmed, models.ActionConflict},
+			conflict: []string{"", "still labeled STARRED"},
+		},
+		{
+			name:   "conflicts are per email",
+			synced: map[string][]string{"a": {"STARRED"}, "b": {}},
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, []string{"a", "b"}, star, nil),
+			},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"email b without label STARRED"},
+		},
+		{
+			name:     "trash disagreeing",
+			synced:   map[string][]string{"a": {"INBOX"}},
+			actions:  []models.PendingAction{sent(before, models.ActionTrash, a, nil, nil)},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"outside the trash"},
+		},
+		{
+			name:   "trash then untrash",
+			synced: map[string][]string{"a": {"INBOX"}},
+			actions: []models.PendingAction{
+				sent(before, models.ActionTrash, a, nil, nil),
+				sent(before, models.ActionUntrash, a, nil, nil),
+			},
+			want: []string{confirmed, confirmed},
+		},
+		{
+			name:    "trashed email deleted in Gmail",
+			synced:  map[string][]string{"a": {"INBOX"}},
+			deleted: a,
+			actions: []models.PendingAction{sent(before, models.ActionTrash, a, nil, nil)},
+			want:    []string{confirmed},
+		},
+		{
+			name:     "labeled email deleted in Gmail",
+			synced:   map[string][]string{},
+			actions:  []models.PendingAction{sent(before, models.ActionLabels, a, star, nil)},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"email a was deleted in Gmail"},
+		},
+		{
+			name:    "labeled then trashed email deleted in Gmail",
+			synced:  map[string][]string{"a": {"INBOX"}},
+			deleted: a,
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, a, star, nil),
+				sent(before, models.ActionTrash, a, nil, nil),
+			},
+			want: []string{confirmed, confirmed},
+		},
+		{
+			name:   "action sent during the sync is applied, not checked",
+			synced: map[string][]string{"a": {"INBOX", "STARRED"}},
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, a, star, nil),
+				sent(during, models.ActionLabels, a, nil, star),
+			},
+			want:   []string{confirmed, models.ActionSent},
+			labels: []string{"INBOX"},
+		},
+		{
+			name:    "full sync does not list a trashed email",
+			synced:  map[string][]string{"a": {"INBOX"}},
+			full:    true,
+			actions: []models.PendingAction{sent(before, models.ActionTrash, a, nil, nil)},
+			want:    []string{confirmed},
+		},
+		{
+			name:     "full sync checks the emails it saved",
+			synced:   map[string][]string{"a": {"INBOX"}},
+			full:     true,
+			saved:    a,
+			actions:  []models.PendingAction{sent(before, models.ActionTrash, a, nil, nil)},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"outside the trash"},
+		},
+		{
+			name:   "resumed full sync skips emails saved before",
+			synced: map[string][]string{"a": {"INBOX"}, "b": {"INBOX"}},
+			full:   true,
+			saved:  []string{"b"},
+			actions: []models.PendingAction{
+				sent(before, models.ActionLabels, []string{"a", "b"}, star, nil),
+			},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"email b without label STARRED"},
+		},
+		{
+			name:    "pending action is applied again",
+			synced:  map[string][]string{"a": {"INBOX", "UNREAD"}},
+			actions: []models.PendingAction{pending(models.ActionLabels, a, nil, []string{"UNREAD"})},
+			want:    []string{models.ActionPending},
+			labels:  []string{"INBOX"},
+		},
+		{
+			name:     "pending action on an email deleted in Gmail",
+			synced:   map[string][]string{"a": {"INBOX"}},
+			deleted:  a,
+			actions:  []models.PendingAction{pending(models.ActionLabels, a, star, nil)},
+			want:     []string{models.ActionConflict},
+			conflict: []string{"email a was deleted in Gmail"},
+			labels:   []string{"INBOX"},
+		},
+		{
+			name:   "failed and unsubscribe actions are left alone",
+			synced: map[string][]string{"a": {"INBOX"}},
+			actions: []models.PendingAction{
+				{Kind: models.ActionLabels, EmailIDs: a, AddLabels: star, Status: models.ActionFailed},
+				{Kind: models.ActionUnsubscribe, URL: "https://example.com/u", Status: models.ActionSent, SentAt: before},
+			},
+			want:   []string{models.ActionFailed, models.ActionSent},
+			labels: []string{"INBOX"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeStore{emails: make(map[string]*models.Email), trashed: make(map[string]bool)}
+			for id, labels := range tt.synced {
+				db.emails[id] = &models.Email{ID: id, Labels: slices.Clone(labels)}
+			}
+			for i, action := range tt.actions {
+				action.ID = int64(i + 1)
+				db.actions = append(db.actions, action)
+			}
+
+			var saved map[string]bool
+			if tt.full {
+				saved = make(map[string]bool)
+				for _, id := range tt.saved {
+					saved[id] = true
+				}
+			}
+			err := reconcileActions(context.Background(), db, gmail.MailboxChanges{Deleted: tt.deleted}, saved, syncStart)
+			if err != nil {
+				t.Fatalf("reconcileActions: %v", err)
+			}
+
+			for i, want := range tt.want {
+				id := int64(i + 1)
+				got := confirmed
+				var reason string
+				for _, action := range db.actions {
+					if action.ID == id {
+						got, reason = action.Status, action.LastError
+					}
+				}
+				if got != want {
+					t.Errorf("action %d is %s, want %s", id, got, want)
+				}
+				if i < len(tt.conflict) && !strings.Contains(reason, tt.conflict[i]) {
+					t.Errorf("action %d conflict = %q, want it to mention %q", id, reason, tt.conflict[i])
+				}
+			}
+			if tt.labels != nil {
+				if got := db.emails["a"].Labels; !slices.Equal(got, tt.labels) {
+					t.Errorf("labels of a = %q, want %q", got, tt.labels)
+				}
+			}
+		})
+	}
+}
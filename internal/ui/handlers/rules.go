@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+var rulesLog = logging.For("rules")
+
+// idBatchSize is how many emails are looked up by ID per query, which
+// keeps each query within the bound-parameter limit
+const idBatchSize = 500
+
+// ApplyRules runs each rule, in order, on the emails among ids that match
+// it and returns how many emails the rules acted on. Trashed emails are
+// not matched by later rules.
+func ApplyRules(ctx context.Context, db store.Store, rules []config.SyncRule, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var total int64
+	for _, rule := range rules {
+		var hits []string
+		for batch := range slices.Chunk(ids, idBatchSize) {
+			filter := ruleFilter(rule)
+			filter.IDs = batch
+			matched, err := db.ListEmailIDs(ctx, filter)
+			if err != nil {
+				return total, fmt.Errorf("rule %s: %v", ruleName(rule), err)
+			}
+			hits = append(hits, matched...)
+		}
+		if len(hits) == 0 {
+			continue
+		}
+
+		if err := applyRule(ctx, db, rule, hits); err != nil {
+			return total, fmt.Errorf("rule %s: %v", ruleName(rule), err)
+		}
+		rulesLog.Info("Rule applied", "rule", ruleName(rule), "action", rule.Action, "count", len(hits))
+		total += int64(len(hits))
+	}
+	return total, nil
+}
+
+func ruleFilter(rule config.SyncRule) models.EmailFilter {
+	return models.EmailFilter{
+		Sender:  rule.Sender,
+		Domain:  rule.Domain,
+		Label:   rule.Label,
+		Query:   rule.Query,
+		MinSize: int64(rule.MinSizeMB) << 20,
+	}
+}
+
+func ruleName(rule config.SyncRule) string {
+	if rule.Name != "" {
+		return fmt.Sprintf("%q", rule.Name)
+	}
+	return rule.Action
+}
+
+func applyRule(ctx context.Context, db store.Store, rule config.SyncRule, ids []string) error {
+	switch rule.Action {
+	case config.RuleTrash:
+		_, err := TrashEmails(ctx, db, ids, rule.Gmail)
+		return err
+	case config.RuleArchive:
+		return ArchiveEmails(ctx, db, ids)
+	case config.RuleLabel:
+		labelID, err := findLabel(ctx, db, rule.AddLabel)
+		if err != nil {
+			return err
+		}
+		return LabelEmails(ctx, db, ids, labelID)
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+}
+
+// findLabel returns the ID of the label with the given ID or name
+func findLabel(ctx context.Context, db store.Store, idOrName string) (string, error) {
+	labels, err := db.GetAllLabels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load labels: %v", err)
+	}
+	for _, label := range labels {
+		if label.ID == idOrName {
+			return label.ID, nil
+		}
+	}
+	for _, label := range labels {
+		if strings.EqualFold(label.Name, idOrName) {
+			return label.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no label %q", idOrName)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+const (
+	// sizeBatchSize is how many emails have their size fetched and saved
+	// per round
+	sizeBatchSize = 100
+	// maxSizesPerSync is how many sizes one sync fills in at most, so a
+	// large mailbox saved before sizes were recorded catches up over
+	// several syncs
+	maxSizesPerSync = 2000
+)
+
+// backfillSizes fetches the size estimate and attachment names of emails
+// saved before they were recorded, and returns how many were filled in
+func backfillSizes(ctx context.Context, gmailClient *http.Client, db store.Store) (int, error) {
+	filled := 0
+	after := ""
+	for filled < maxSizesPerSync {
+		ids, err := db.ListUnsizedEmails(ctx, after, min(sizeBatchSize, maxSizesPerSync-filled))
+		if err != nil {
+			return filled, err
+		}
+		if len(ids) == 0 {
+			return filled, nil
+		}
+		after = ids[len(ids)-1]
+
+		var sizes []models.Email
+		for email, err := range gmail.FetchSizes(ctx, gmailClient, ids) {
+			if err != nil {
+				return filled, err
+			}
+			sizes = append(sizes, email)
+		}
+		if len(sizes) == 0 {
+			continue
+		}
+		if err := db.SaveSizes(context.WithoutCancel(ctx), sizes); err != nil {
+			return filled, err
+		}
+		filled += len(sizes)
+	}
+	return filled, nil
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/metrics"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	syncLog    = logging.For("sync")
+	syncTracer = tracing.Tracer("sync")
+)
+
+// ErrSyncRunning is returned when another sync, possibly in another
+// process, holds the sync lock
+var ErrSyncRunning = errors.New("another sync is already running")
+
+const (
+	// syncLockTTL is how long the sync lock outlives a process that died
+	// holding it
+	syncLockTTL = 2 * time.Minute
+	// syncLockRenewal is how often a running sync renews its lock
+	syncLockRenewal = syncLockTTL / 4
+)
+
+// RunSync brings the store up to date with Gmail and records the run in
+// the sync history. Once a full sync has completed, later runs only fetch
+// what changed since, using the Gmail history. rules run on the emails an
+// incremental sync adds, and the history ID it reached is only recorded
+// once they succeed, so a failed rule is retried by the next sync. Emails
+// saved before sizes were recorded get theirs a batch at a time. The sync lock keeps runs from overlapping; if
+// it is taken ErrSyncRunning is returned and nothing is recorded.
+// onProgress may be nil.
+func RunSync(ctx context.Context, gmailClient *http.Client, db store.Store, cfg config.GmailConfig, trigger string, rules []config.SyncRule, onProgress func(SyncProgress)) (run models.SyncRun, err error) {
+	run = models.SyncRun{Trigger: trigger, StartedAt: time.Now()}
+
+	ctx, span := syncTracer.Start(ctx, "sync", trace.WithAttributes(attribute.String("sync.trigger", trigger)))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int64("sync.run", run.ID),
+			attribute.String("sync.mode", run.Mode),
+			attribute.Int64("sync.fetched", run.Fetched),
+			attribute.Int64("sync.saved", run.Saved),
+			attribute.Int64("sync.deleted", run.Deleted),
+			attribute.Int64("sync.ruled", run.Ruled),
+		)
+		tracing.End(span, err)
+	}()
+
+	owner := lockOwner()
+	locked, err := db.TryLockSync(ctx, owner, syncLockTTL)
+	if err != nil {
+		return run, err
+	}
+	if !locked {
+		return run, ErrSyncRunning
+	}
+	// The lock and run record are cleaned up even if ctx was cancelled
+	cleanupCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if err := db.UnlockSync(cleanupCtx, owner); err != nil {
+			syncLog.Error("Unable to release sync lock", "err", err)
+		}
+	}()
+	stopRenewal := renewLock(ctx, db, owner)
+	defer stopRenewal()
+
+	if err := db.StartSyncRun(ctx, &run); err != nil {
+		return run, err
+	}
+	syncLog.Info("Sync started", "run", run.ID, "trigger", trigger)
+
+	added, historyID, err := syncMailbox(ctx, gmailClient, db, cfg, &run, onProgress)
+	if err == nil && len(rules) > 0 {
+		if run.Mode == models.SyncModeIncremental {
+			run.Ruled, err = ApplyRules(ctx, db, rules, added)
+		} else {
+			syncLog.Info("Skipping sync rules after a full sync")
+		}
+	}
+	if err == nil {
+		err = db.SaveHistoryID(cleanupCtx, historyID)
+	}
+	if err == nil {
+		// Emails saved before sizes were recorded are filled in a few at a
+		// time, which the sync does not fail over
+		filled, berr := backfillSizes(ctx, gmailClient, db)
+		if berr != nil && ctx.Err() == nil {
+			syncLog.Warn("Unable to fill in message sizes", "err", berr)
+		}
+		if filled > 0 {
+			syncLog.Info("Filled in message sizes", "count", filled)
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	result := "ok"
+	if err != nil {
+		run.Error = err.Error()
+		result = "error"
+	} else {
+		metrics.LastSuccessfulSync.SetTime(run.FinishedAt)
+	}
+	metrics.SyncDuration.Observe(run.FinishedAt.Sub(run.StartedAt).Seconds(), trigger, run.Mode, result)
+	syncLog.Info("Sync finished", "run", run.ID, "mode", run.Mode, "result", result,
+		"duration", run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond),
+		"saved", run.Saved, "deleted", run.Deleted, "ruled", run.Ruled)
+	if ferr := db.FinishSyncRun(cleanupCtx, run); ferr != nil {
+		syncLog.Error("Unable to record sync run", "run", run.ID, "err", ferr)
+	}
+	return run, err
+}
+
+// lockOwner names this run in the sync lock
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// renewLock keeps the sync lock from lapsing until the returned function
+// is called
+func renewLock(ctx context.Context, db store.Store, owner string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(syncLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				locked, err := db.TryLockSync(ctx, owner, syncLockTTL)
+				if err != nil && ctx.Err() == nil {
+					syncLog.Error("Unable to renew sync lock", "err", err)
+				} else if err == nil && !locked {
+					syncLog.Warn("Sync lock was taken over by another sync")
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// syncMailbox runs an incremental sync if the Gmail history since the
+// last sync is available and a full sync otherwise. It returns the IDs of
+// the emails an incremental sync added and the history ID for the next
+// sync to start from, which the caller records.
+func syncMailbox(ctx context.Context, gmailClient *http.Client, db store.Store, cfg config.GmailConfig, run *models.SyncRun, onProgress func(SyncProgress)) ([]string, uint64, error) {
+	historyID, err := db.GetHistoryID(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load history ID: %v", err)
+	}
+
+	if historyID != 0 {
+		changes, err := gmail.ListChanges(ctx, gmailClient, historyID)
+		if err == nil {
+			run.Mode = models.SyncModeIncremental
+			return changes.Added, changes.HistoryID, applyChanges(ctx, gmailClient, db, changes, run, onProgress)
+		}
+		if !errors.Is(err, gmail.ErrHistoryExpired) {
+			return nil, 0, fmt.Errorf("failed to list changes: %v", err)
+		}
+		syncLog.Warn("Gmail history has expired, running a full sync", "history_id", historyID)
+	}
+
+	run.Mode = models.SyncModeFull
+	start, err := fullSync(ctx, gmailClient, db, cfg, run, onProgress)
+	return nil, start, err
+}
+
+// fullSync lists the whole mailbox, reconciles the outbox and returns the
+// history ID it started from, so changes made while it ran are picked up
+// next time
+func fullSync(ctx context.Context, gmailClient *http.Client, db store.Store, cfg config.GmailConfig, run *models.SyncRun, onProgress func(SyncProgress)) (uint64, error) {
+	start, err := gmail.CurrentHistoryID(ctx, gmailClient)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history ID: %v", err)
+	}
+
+	// Progress is reported from two goroutines
+	var fetched, saved atomic.Int64
+	synced, err := SyncEmails(ctx, gmailClient, db, cfg, 0, func(p SyncProgress) {
+		fetched.Store(p.Fetched)
+		saved.Store(p.Saved)
+		if onProgress != nil {
+			onProgress(p)
+		}
+	})
+	run.Fetched, run.Saved = fetched.Load(), saved.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	// A full sync does not learn which emails Gmail deleted, and the list
+	// it fetches leaves out the trash and, after resuming, what an earlier
+	// run saved, so only the emails it saved are checked
+	if err := reconcileActions(context.WithoutCancel(ctx), db, gmail.MailboxChanges{}, synced, run.StartedAt); err != nil {
+		return 0, fmt.Errorf("failed to reconcile queued actions: %v", err)
+	}
+	return start, nil
+}
+
+// applyChanges fetches the added and relabeled messages, moves messages
+// deleted in Gmail to the local trash and reconciles the outbox
+func applyChanges(ctx context.Context, gmailClient *http.Client, db store.Store, changes gmail.MailboxChanges, run *models.SyncRun, onProgress func(SyncProgress)) error {
+	if err := syncLabels(ctx, gmailClient, db); err != nil {
+		return err
+	}
+
+	ids := append(append([]string{}, changes.Added...), changes.Relabeled...)
+	progress := SyncProgress{FetchProgress: gmail.FetchProgress{Total: int64(len(ids)), Listed: int64(len(ids))}}
+	report := func() {
+		run.Fetched, run.Saved = progress.Fetched, progress.Saved
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	report()
+
+	// Whatever was fetched is saved even if ctx is cancelled
+	saveCtx := context.WithoutCancel(ctx)
+	batch := make([]models.Email, 0, saveBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := db.SaveEmails(saveCtx, batch); err != nil {
+			return fmt.Errorf("failed to save emails: %v", err)
+		}
+		progress.Saved += int64(len(batch))
+		batch = batch[:0]
+		report()
+		return nil
+	}
+
+	for email, err := range gmail.FetchMessages(ctx, gmailClient, ids) {
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to fetch emails: %v", err)
+		}
+		batch = append(batch, email)
+		progress.Fetched++
+		report()
+		if len(batch) >= saveBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if len(changes.Deleted) > 0 {
+		if err := db.DeleteEmails(saveCtx, changes.Deleted); err != nil {
+			return fmt.Errorf("failed to trash emails deleted in Gmail: %v", err)
+		}
+		run.Deleted = int64(len(changes.Deleted))
+	}
+
+	if err := reconcileActions(saveCtx, db, changes, nil, run.StartedAt); err != nil {
+		return fmt.Errorf("failed to reconcile queued actions: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+)
+
+var trashLog = logging.For("trash")
+
+// TrashedBatch records a bulk delete so it can be undone
+type TrashedBatch struct {
+	// IDs are the emails moved to the local trash
+	IDs []string
+	// Remote are the emails also queued to be moved to the Gmail trash
+	Remote []string
+}
+
+// TrashEmails moves emails to the local trash, and queues moving them to
+// the Gmail trash too if remote is set
+func TrashEmails(ctx context.Context, db store.Store, ids []string, remote bool) (TrashedBatch, error) {
+	batch := TrashedBatch{IDs: ids}
+	if len(ids) == 0 {
+		return batch, nil
+	}
+
+	if remote {
+		if err := queueAction(ctx, db, models.PendingAction{Kind: models.ActionTrash, EmailIDs: ids}); err != nil {
+			return TrashedBatch{}, err
+		}
+		batch.Remote = ids
+	}
+	// What was queued for Gmail must be mirrored even if ctx was cancelled
+	if err := db.DeleteEmails(context.WithoutCancel(ctx), ids); err != nil {
+		return batch, fmt.Errorf("failed to move emails to trash: %v", err)
+	}
+	return batch, nil
+}
+
+// UndoTrash restores a batch from the local trash and queues restoring it
+// from the Gmail trash
+func UndoTrash(ctx context.Context, db store.Store, batch TrashedBatch) error {
+	if len(batch.Remote) > 0 {
+		if err := queueAction(ctx, db, models.PendingAction{Kind: models.ActionUntrash, EmailIDs: batch.Remote}); err != nil {
+			return err
+		}
+	}
+	if _, err := db.RestoreEmails(ctx, batch.IDs); err != nil {
+		return fmt.Errorf("failed to restore emails: %v", err)
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes emails that have been in the trash for
+// more than retentionDays. A retention of 0 keeps everything.
+func PurgeTrash(ctx context.Context, db store.TrashStore, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	before := time.Now().AddDate(0, 0, -retentionDays)
+	return db.PurgeTrashBefore(ctx, before)
+}
+
+// RunTrashRetention purges the trash now and then every interval until
+// ctx is cancelled
+func RunTrashRetention(ctx context.Context, db store.TrashStore, retentionDays int, interval time.Duration) {
+	if retentionDays <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := PurgeTrash(ctx, db, retentionDays)
+		if err != nil && ctx.Err() == nil {
+			trashLog.Error("Unable to purge trash", "err", err)
+		} else if n > 0 {
+			trashLog.Info("Purged expired trash", "count", n, "retention_days", retentionDays)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+)
+
+var watchLog = logging.For("watch")
+
+// watchRetryDelay is how soon a failed watch renewal is tried again
+const watchRetryDelay = 5 * time.Minute
+
+// RunWatchRenewal has Gmail publish mailbox changes to topic, renewing the
+// watch every interval until ctx is cancelled. Gmail drops a watch after a
+// week, so interval should be well under that.
+func RunWatchRenewal(ctx context.Context, gmailClient *http.Client, topic string, interval time.Duration) {
+	for {
+		wait := interval
+		expires, err := gmail.Watch(ctx, gmailClient, topic)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			watchLog.Error("Unable to renew Gmail watch", "topic", topic, "err", err)
+			wait = watchRetryDelay
+		} else {
+			watchLog.Info("Gmail watch renewed", "topic", topic, "expires", expires)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
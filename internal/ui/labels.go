@@ -0,0 +1,218 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// allLabelsOption is the label dropdown entry that filters nothing
+const allLabelsOption = "All Labels"
+
+// loadLabels fills the label dropdown from the store in the background,
+// keeping the current choice if the label still exists. It does not
+// reload the list.
+func (a *App) loadLabels() {
+	go func() {
+		labels, err := a.db.GetAllLabels(a.ctx)
+		fyne.Do(func() {
+			if err != nil {
+				logger.Error("Unable to load labels", "err", err)
+				return
+			}
+			a.showLabels(labels)
+		})
+	}()
+}
+
+func (a *App) showLabels(labels []models.Label) {
+	a.labels = labels
+
+	options := make([]string, 0, len(labels)+1)
+	options = append(options, allLabelsOption)
+	for _, label := range labels {
+		options = append(options, label.Name)
+	}
+	a.labelSelect.Options = options
+	if a.labelByName(a.labelSelect.Selected) == nil {
+		// Set directly, as SetSelected would reload the list
+		a.labelSelect.Selected = allLabelsOption
+	}
+	a.labelSelect.Refresh()
+}
+
+// labelByName returns the known label called name, nil if there is none
+func (a *App) labelByName(name string) *models.Label {
+	for i := range a.labels {
+		if a.labels[i].Name == name {
+			return &a.labels[i]
+		}
+	}
+	return nil
+}
+
+// showLabelDialog offers to add or remove a label on the selected emails,
+// or to create a new label and add it
+func (a *App) showLabelDialog() {
+	ids := a.selectionFor("label")
+	if ids == nil {
+		return
+	}
+
+	var names []string
+	for _, label := range a.labels {
+		if handlers.Assignable(label) {
+			names = append(names, label.Name)
+		}
+	}
+	choice := widget.NewSelect(names, nil)
+	choice.PlaceHolder = "Choose a label"
+	newName := widget.NewEntry()
+	newName.SetPlaceHolder("New label, e.g. Receipts or Work/Clients")
+
+	var d dialog.Dialog
+	applyBtn := widget.NewButton("Add Label", func() {
+		label := a.labelByName(choice.Selected)
+		if label == nil {
+			return
+		}
+		d.Hide()
+		a.modifyLabels(ids, []string{label.ID}, nil, func(n int) string {
+			return fmt.Sprintf("Labeled %d emails %s", n, label.Name)
+		})
+	})
+	removeBtn := widget.NewButton("Remove Label", func() {
+		label := a.labelByName(choice.Selected)
+		if label == nil {
+			return
+		}
+		d.Hide()
+		a.modifyLabels(ids, nil, []string{label.ID}, func(n int) string {
+			return fmt.Sprintf("Removed %s from %d emails", label.Name, n)
+		})
+	})
+	createBtn := widget.NewButton("Create and Add", func() {
+		name := strings.TrimSpace(newName.Text)
+		if name == "" {
+			return
+		}
+		d.Hide()
+		go func() {
+			label, err := handlers.CreateLabel(a.ctx, a.gmailClient, a.db, name)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, a.mainWindow)
+					return
+				}
+				a.loadLabels()
+				a.modifyLabels(ids, []string{label.ID}, nil, func(n int) string {
+					return fmt.Sprintf("Labeled %d emails %s", n, label.Name)
+				})
+			})
+		}()
+	})
+	newName.OnSubmitted = func(string) { createBtn.OnTapped() }
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("%d email(s) selected", len(ids))),
+		choice,
+		container.NewHBox(applyBtn, removeBtn),
+		widget.NewSeparator(),
+		newName,
+		createBtn,
+	)
+	d = dialog.NewCustom("Labels", "Close", content, a.mainWindow)
+	d.Resize(fyne.NewSize(420, 0))
+	d.Show()
+}
+
+// archiveSelected removes the selected emails from the inbox
+func (a *App) archiveSelected() {
+	if ids := a.selectionFor("archive"); ids != nil {
+		a.modifyLabels(ids, nil, []string{"INBOX"}, countMessage("Archived %d emails"))
+	}
+}
+
+// markRead marks the selected emails read
+func (a *App) markRead() {
+	if ids := a.selectionFor("mark read"); ids != nil {
+		a.modifyLabels(ids, nil, []string{"UNREAD"}, countMessage("Marked %d emails read"))
+	}
+}
+
+// markUnread marks the selected emails unread
+func (a *App) markUnread() {
+	if ids := a.selectionFor("mark unread"); ids != nil {
+		a.modifyLabels(ids, []string{"UNREAD"}, nil, countMessage("Marked %d emails unread"))
+	}
+}
+
+// toggleStar stars the selected emails, or unstars them if all of them
+// are starred already
+func (a *App) toggleStar() {
+	ids := a.selectionFor("star")
+	if ids == nil {
+		return
+	}
+	if a.emailList.AllHaveLabel(ids, "STARRED") {
+		a.modifyLabels(ids, nil, []string{"STARRED"}, countMessage("Unstarred %d emails"))
+	} else {
+		a.modifyLabels(ids, []string{"STARRED"}, nil, countMessage("Starred %d emails"))
+	}
+}
+
+// selectionFor returns the emails to act on, telling the user to select
+// some if there are none
+func (a *App) selectionFor(verb string) []string {
+	ids := a.actionIDs()
+	if len(ids) == 0 {
+		dialog.ShowInformation("No Selection", "Please select emails to "+verb, a.mainWindow)
+		return nil
+	}
+	return ids
+}
+
+// modifyLabels changes labels in the store and queues the change for
+// Gmail in the background, then reports msg of the number of emails that
+// changed with an offer to undo it. Emails that already had the labels
+// asked for are left out, so undoing does not touch them.
+func (a *App) modifyLabels(ids, add, remove []string, msg func(n int) string) {
+	a.changeLabels(ids, add, remove, func(changed []string) {
+		if len(changed) == 0 {
+			a.snackbar.Show("No labels needed changing", nil)
+			return
+		}
+		a.snackbar.Show(msg(len(changed)), func() {
+			a.changeLabels(changed, remove, add, func([]string) {
+				a.snackbar.Show("Label change undone", nil)
+			})
+		})
+	})
+}
+
+// countMessage returns a modifyLabels message filling format's %d in
+func countMessage(format string) func(n int) string {
+	return func(n int) string {
+		return fmt.Sprintf(format, n)
+	}
+}
+
+func (a *App) changeLabels(ids, add, remove []string, done func(changed []string)) {
+	go func() {
+		changed, err := handlers.ModifyEmailLabels(a.ctx, a.db, ids, add, remove)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, a.mainWindow)
+			} else {
+				done(changed)
+			}
+			a.refreshView()
+		})
+	}()
+}
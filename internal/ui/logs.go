@@ -0,0 +1,191 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+)
+
+// logRefreshInterval limits how often the log window redraws while
+// entries stream in
+const logRefreshInterval = 250 * time.Millisecond
+
+const allComponents = "All components"
+
+var logLevels = map[string]slog.Level{
+	"Debug": slog.LevelDebug,
+	"Info":  slog.LevelInfo,
+	"Warn":  slog.LevelWarn,
+	"Error": slog.LevelError,
+}
+
+// logsView is a window tailing the recent log entries. All fields are only
+// touched on the Fyne UI goroutine.
+type logsView struct {
+	window fyne.Window
+
+	// all are the entries kept by the logger, shown the ones that pass the
+	// filters
+	all   []logging.Entry
+	shown []logging.Entry
+
+	minLevel  slog.Level
+	component string
+
+	summary    *widget.Label
+	components *widget.Select
+	follow     *widget.Check
+	list       *widget.List
+}
+
+// showLogs opens the log window
+func (a *App) showLogs() {
+	v := &logsView{
+		window:    a.fyneApp.NewWindow("Logs"),
+		minLevel:  slog.LevelDebug,
+		component: allComponents,
+	}
+
+	v.summary = widget.NewLabel("")
+	v.summary.TextStyle = fyne.TextStyle{Bold: true}
+
+	v.list = widget.NewList(
+		func() int { return len(v.shown) },
+		func() fyne.CanvasObject {
+			line := widget.NewLabel("Entry")
+			line.Truncation = fyne.TextTruncateEllipsis
+			line.TextStyle = fyne.TextStyle{Monospace: true}
+			return line
+		},
+		v.updateRow,
+	)
+
+	v.follow = widget.NewCheck("Follow", func(on bool) {
+		if on {
+			v.list.ScrollToBottom()
+		}
+	})
+	v.follow.SetChecked(true)
+
+	levelSelect := widget.NewSelect([]string{"Debug", "Info", "Warn", "Error"}, func(level string) {
+		v.minLevel = logLevels[level]
+		v.filter()
+	})
+	levelSelect.SetSelected("Debug")
+
+	v.components = widget.NewSelect([]string{allComponents}, func(component string) {
+		v.component = component
+		v.filter()
+	})
+	v.components.SetSelected(allComponents)
+
+	controls := container.NewHBox(
+		widget.NewLabel("Level:"), levelSelect,
+		widget.NewLabel("Component:"), v.components,
+		v.follow,
+	)
+	v.window.SetContent(container.NewBorder(container.NewVBox(v.summary, controls), nil, nil, nil, v.list))
+	v.window.Resize(fyne.NewSize(900, 500))
+
+	done := make(chan struct{})
+	v.window.SetOnClosed(func() { close(done) })
+	v.window.Show()
+
+	entries, changed := logging.Recent()
+	v.update(entries)
+	go v.tail(changed, done)
+}
+
+// tail reloads the entries as new ones arrive until the window closes
+func (v *logsView) tail(changed <-chan struct{}, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-changed:
+		}
+
+		// Let a burst of entries land before redrawing
+		select {
+		case <-done:
+			return
+		case <-time.After(logRefreshInterval):
+		}
+
+		var entries []logging.Entry
+		entries, changed = logging.Recent()
+		fyne.Do(func() { v.update(entries) })
+	}
+}
+
+func (v *logsView) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	line := obj.(*widget.Label)
+	e := v.shown[i]
+	text := fmt.Sprintf("%s %-5s %-8s %s", e.Time.Format(time.TimeOnly), e.Level, e.Component, e.Message)
+	if e.Attrs != "" {
+		text += " " + e.Attrs
+	}
+	line.SetText(text)
+
+	switch {
+	case e.Level >= slog.LevelError:
+		line.Importance = widget.DangerImportance
+	case e.Level >= slog.LevelWarn:
+		line.Importance = widget.WarningImportance
+	case e.Level < slog.LevelInfo:
+		line.Importance = widget.LowImportance
+	default:
+		line.Importance = widget.MediumImportance
+	}
+	line.Refresh()
+}
+
+// update takes a new snapshot of the entries and adds any new component to
+// the filter
+func (v *logsView) update(entries []logging.Entry) {
+	v.all = entries
+
+	seen := make(map[string]bool, len(v.components.Options))
+	for _, c := range v.components.Options {
+		seen[c] = true
+	}
+	added := false
+	for _, e := range entries {
+		if e.Component != "" && !seen[e.Component] {
+			seen[e.Component] = true
+			v.components.Options = append(v.components.Options, e.Component)
+			added = true
+		}
+	}
+	if added {
+		v.components.Refresh()
+	}
+
+	v.filter()
+}
+
+// filter applies the level and component filters and redraws the list
+func (v *logsView) filter() {
+	v.shown = v.shown[:0]
+	for _, e := range v.all {
+		if e.Level < v.minLevel {
+			continue
+		}
+		if v.component != allComponents && !strings.EqualFold(e.Component, v.component) {
+			continue
+		}
+		v.shown = append(v.shown, e)
+	}
+
+	v.summary.SetText(fmt.Sprintf("%d of the last %d entries", len(v.shown), len(v.all)))
+	v.list.Refresh()
+	if v.follow.Checked {
+		v.list.ScrollToBottom()
+	}
+}
@@ -0,0 +1,273 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
+)
+
+// outboxRefreshInterval is how often the outbox counts are reloaded, as
+// the worker changes them in the background
+const outboxRefreshInterval = 5 * time.Second
+
+// refreshOutbox reloads the outbox counts shown in the selection bar
+func (a *App) refreshOutbox() {
+	go func() {
+		counts, err := a.db.CountActions(a.ctx)
+		fyne.Do(func() {
+			if err != nil {
+				logger.Error("Unable to count queued actions", "err", err)
+				return
+			}
+			a.showOutboxCounts(counts)
+		})
+	}()
+}
+
+// watchOutbox refreshes the outbox counts until the app closes
+func (a *App) watchOutbox() {
+	ticker := time.NewTicker(outboxRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.refreshOutbox()
+		}
+	}
+}
+
+func (a *App) showOutboxCounts(counts models.ActionCounts) {
+	// Counts can arrive while the UI is still being built
+	if a.outboxBtn == nil {
+		return
+	}
+
+	var parts []string
+	if counts.Pending > 0 {
+		parts = append(parts, fmt.Sprintf("%d pending", counts.Pending))
+	}
+	if counts.Failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", counts.Failed))
+	}
+	if counts.Conflicts > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicts", counts.Conflicts))
+	}
+	if len(parts) == 0 {
+		a.outboxBtn.Hide()
+		return
+	}
+
+	a.outboxBtn.SetText("Outbox: " + strings.Join(parts, " · "))
+	if counts.Failed+counts.Conflicts > 0 {
+		a.outboxBtn.Importance = widget.DangerImportance
+	} else {
+		a.outboxBtn.Importance = widget.LowImportance
+	}
+	a.outboxBtn.Refresh()
+	a.outboxBtn.Show()
+}
+
+// outboxView is a window listing the actions waiting for Gmail or given
+// up on. All fields are only touched on the Fyne UI goroutine.
+type outboxView struct {
+	app    *App
+	window fyne.Window
+
+	actions []models.PendingAction
+
+	summary *widget.Label
+	list    *widget.List
+}
+
+// showOutbox opens the outbox window
+func (a *App) showOutbox() {
+	v := &outboxView{
+		app:    a,
+		window: a.fyneApp.NewWindow("Outbox"),
+	}
+
+	v.summary = widget.NewLabel("Loading...")
+	v.summary.TextStyle = fyne.TextStyle{Bold: true}
+
+	v.list = widget.NewList(
+		func() int { return len(v.actions) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabel("Action")
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("Details")
+			details.Truncation = fyne.TextTruncateEllipsis
+			retry := widget.NewButton("Retry", nil)
+			discard := widget.NewButton("Discard", nil)
+			return container.NewBorder(nil, nil, nil, container.NewHBox(retry, discard),
+				container.NewVBox(title, details))
+		},
+		v.updateRow,
+	)
+
+	refreshBtn := widget.NewButton("Refresh", v.load)
+
+	v.window.SetContent(container.NewBorder(v.summary, container.NewHBox(refreshBtn), nil, nil, v.list))
+	v.window.Resize(fyne.NewSize(800, 500))
+	v.window.Show()
+
+	v.load()
+}
+
+func (v *outboxView) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	text := c.Objects[0].(*fyne.Container)
+	buttons := c.Objects[1].(*fyne.Container)
+	title := text.Objects[0].(*widget.Label)
+	details := text.Objects[1].(*widget.Label)
+	retry := buttons.Objects[0].(*widget.Button)
+	discard := buttons.Objects[1].(*widget.Button)
+
+	action := v.actions[i]
+	title.SetText(fmt.Sprintf("#%d · %s · %s", action.ID, action.CreatedAt.Format(time.DateTime), v.describe(action)))
+
+	switch action.Status {
+	case models.ActionPending:
+		status := "Waiting to be sent"
+		if action.Attempts > 0 {
+			status = fmt.Sprintf("Failed %d time(s), next try at %s · %s",
+				action.Attempts, action.NextAttemptAt.Format(time.TimeOnly), action.LastError)
+		}
+		details.SetText(status)
+		details.Importance = widget.MediumImportance
+	case models.ActionSent:
+		details.SetText(fmt.Sprintf("Sent at %s, waiting for the next sync to confirm it", action.SentAt.Format(time.TimeOnly)))
+		details.Importance = widget.LowImportance
+	case models.ActionFailed:
+		details.SetText("Failed · " + action.LastError)
+		details.Importance = widget.DangerImportance
+	case models.ActionConflict:
+		details.SetText("Conflict · " + action.LastError)
+		details.Importance = widget.WarningImportance
+	}
+	details.Refresh()
+
+	if action.Status == models.ActionFailed || action.Status == models.ActionConflict {
+		retry.OnTapped = func() { v.retry(action) }
+		discard.OnTapped = func() { v.discard(action) }
+		buttons.Show()
+	} else {
+		buttons.Hide()
+	}
+}
+
+// describe says what an action does, naming labels where they are known
+func (v *outboxView) describe(action models.PendingAction) string {
+	emails := fmt.Sprintf("%d email(s)", len(action.EmailIDs))
+	switch action.Kind {
+	case models.ActionLabels:
+		if len(action.AddLabels) == 0 && len(action.RemoveLabels) == 1 && action.RemoveLabels[0] == "INBOX" {
+			return "Archive " + emails
+		}
+		var parts []string
+		if len(action.AddLabels) > 0 {
+			parts = append(parts, "add "+v.labelNames(action.AddLabels))
+		}
+		if len(action.RemoveLabels) > 0 {
+			parts = append(parts, "remove "+v.labelNames(action.RemoveLabels))
+		}
+		return fmt.Sprintf("Labels on %s: %s", emails, strings.Join(parts, ", "))
+	case models.ActionTrash:
+		return "Move " + emails + " to the Gmail trash"
+	case models.ActionUntrash:
+		return "Restore " + emails + " from the Gmail trash"
+	case models.ActionUnsubscribe:
+		if u, err := url.Parse(action.URL); err == nil && u.Host != "" {
+			return "Unsubscribe at " + u.Host
+		}
+		return "Unsubscribe"
+	}
+	return action.Kind
+}
+
+func (v *outboxView) labelNames(ids []string) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		name := id
+		for _, label := range v.app.labels {
+			if label.ID == id {
+				name = label.Name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// retry queues a failed or conflicting action again
+func (v *outboxView) retry(action models.PendingAction) {
+	a := v.app
+	go func() {
+		err := handlers.RetryAction(a.ctx, a.db, action.ID)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, v.window)
+			}
+			v.load()
+		})
+	}()
+}
+
+// discard drops an action after confirming, undoing its local change if
+// it never reached Gmail
+func (v *outboxView) discard(action models.PendingAction) {
+	a := v.app
+	msg := "Drop this action? The local copy already matches Gmail."
+	if action.Status == models.ActionFailed {
+		msg = "Drop this action? Its change is undone locally so the local copy matches Gmail again."
+	}
+	dialog.ShowConfirm("Discard Action", msg, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		go func() {
+			err := handlers.DiscardAction(a.ctx, a.db, action)
+			fyne.Do(func() {
+				if err != nil {
+					dialog.ShowError(err, v.window)
+				}
+				v.load()
+				a.refreshView()
+			})
+		}()
+	}, v.window)
+}
+
+// load fetches the actions in the background
+func (v *outboxView) load() {
+	a := v.app
+	go func() {
+		actions, err := a.db.ListActions(a.ctx, "")
+		fyne.Do(func() {
+			if err != nil {
+				v.summary.SetText("Unable to load the outbox")
+				dialog.ShowError(err, v.window)
+				return
+			}
+
+			v.actions = actions
+			if len(actions) == 0 {
+				v.summary.SetText("Every change has reached Gmail")
+			} else {
+				v.summary.SetText(fmt.Sprintf("%d action(s) in the outbox", len(actions)))
+			}
+			v.list.Refresh()
+			a.refreshOutbox()
+		})
+	}()
+}
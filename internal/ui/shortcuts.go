@@ -0,0 +1,201 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/components"
+)
+
+// shortcutHandlers returns what each bindable action does
+func (a *App) shortcutHandlers() map[string]func() {
+	return map[string]func(){
+		config.ActionNext:     func() { a.moveCursor(1) },
+		config.ActionPrevious: func() { a.moveCursor(-1) },
+		config.ActionSelect: func() {
+			if a.groupBy == groupBySender {
+				a.emailList.ToggleCurrent()
+			}
+		},
+		config.ActionDelete:  a.deleteSelected,
+		config.ActionArchive: a.archiveSelected,
+		config.ActionRead:    a.markRead,
+		config.ActionUnread:  a.markUnread,
+		config.ActionStar:    a.toggleStar,
+		config.ActionLabel:   a.showLabelDialog,
+		config.ActionSearch:  func() { a.mainWindow.Canvas().Focus(a.searchEntry) },
+		config.ActionRefresh: a.refreshView,
+		config.ActionSync:    a.syncEmails,
+		config.ActionPalette: a.showPalette,
+	}
+}
+
+// bindShortcuts registers the configured key bindings on the main window
+// and the email and thread lists, which get the keys typed while they have
+// focus. Plain characters only fire while no text field has focus, so
+// typing a search does not trigger them.
+func (a *App) bindShortcuts() {
+	handlers := a.shortcutHandlers()
+	typed := make(map[rune]func())
+	named := make(map[fyne.KeyName]func())
+	c := a.mainWindow.Canvas()
+
+	for action, spec := range a.cfg.UI.Shortcuts {
+		run, ok := handlers[action]
+		if !ok || spec == "" {
+			continue
+		}
+		// Validated when the config was loaded
+		shortcut, err := config.ParseShortcut(spec)
+		if err != nil {
+			logger.Warn("Ignoring shortcut", "action", action, "err", err)
+			continue
+		}
+
+		switch {
+		case shortcut.Typed():
+			typed[[]rune(shortcut.Key)[0]] = run
+		case !shortcut.Ctrl && !shortcut.Alt && !shortcut.Shift && !shortcut.Super:
+			named[keyName(shortcut.Key)] = run
+		default:
+			c.AddShortcut(&desktop.CustomShortcut{
+				KeyName:  keyName(shortcut.Key),
+				Modifier: modifiers(shortcut),
+			}, func(fyne.Shortcut) { run() })
+		}
+	}
+
+	keys := components.KeyHandler{
+		Rune: func(r rune) bool {
+			run, ok := typed[r]
+			if ok {
+				run()
+			}
+			return ok
+		},
+		Key: func(e *fyne.KeyEvent) bool {
+			run, ok := named[e.Name]
+			if ok {
+				run()
+			}
+			return ok
+		},
+	}
+	c.SetOnTypedRune(func(r rune) { keys.Rune(r) })
+	c.SetOnTypedKey(func(e *fyne.KeyEvent) { keys.Key(e) })
+	a.emailList.Keys = keys
+	a.threadList.Keys = keys
+}
+
+// keyName maps a configured key to Fyne's name for it. Letters are upper
+// case and named keys are capitalised, as in "Delete" or "F5".
+func keyName(key string) fyne.KeyName {
+	if len(key) == 1 {
+		return fyne.KeyName(strings.ToUpper(key))
+	}
+	return fyne.KeyName(strings.ToUpper(key[:1]) + key[1:])
+}
+
+func modifiers(s config.Shortcut) fyne.KeyModifier {
+	var m fyne.KeyModifier
+	if s.Ctrl {
+		m |= fyne.KeyModifierControl
+	}
+	if s.Alt {
+		m |= fyne.KeyModifierAlt
+	}
+	if s.Shift {
+		m |= fyne.KeyModifierShift
+	}
+	if s.Super {
+		m |= fyne.KeyModifierSuper
+	}
+	return m
+}
+
+// moveCursor moves through the emails or threads shown
+func (a *App) moveCursor(delta int) {
+	if a.groupBy == groupByThread {
+		a.threadList.Move(delta)
+	} else {
+		a.emailList.Move(delta)
+	}
+}
+
+// hint returns the key bound to action for display, "" if none
+func (a *App) hint(action string) string {
+	return a.cfg.UI.Shortcuts[action]
+}
+
+// showPalette opens the command palette with every toolbar action, view
+// and saved search
+func (a *App) showPalette() {
+	commands := []components.Command{
+		{Title: "Sync Emails", Hint: a.hint(config.ActionSync), Run: a.syncEmails},
+		{Title: "Delete Selected", Hint: a.hint(config.ActionDelete), Run: a.deleteSelected},
+		{Title: "Archive Selected", Hint: a.hint(config.ActionArchive), Run: a.archiveSelected},
+		{Title: "Mark Read", Hint: a.hint(config.ActionRead), Run: a.markRead},
+		{Title: "Mark Unread", Hint: a.hint(config.ActionUnread), Run: a.markUnread},
+		{Title: "Star / Unstar", Hint: a.hint(config.ActionStar), Run: a.toggleStar},
+		{Title: "Label Selected", Hint: a.hint(config.ActionLabel), Run: a.showLabelDialog},
+		{Title: "Refresh", Hint: a.hint(config.ActionRefresh), Run: a.refreshView},
+		{Title: "Clean Up Mailbox", Run: a.showCleanupWizard},
+		{Title: "Storage Usage", Run: a.showStorageView},
+		{Title: "Find Duplicates", Run: a.showDuplicatesView},
+		{Title: "Sync History", Run: a.showSyncRuns},
+		{Title: "Show Logs", Run: a.showLogs},
+		{Title: "Show Outbox", Run: a.showOutbox},
+		{Title: "Search", Hint: a.hint(config.ActionSearch), Run: func() { a.mainWindow.Canvas().Focus(a.searchEntry) }},
+		{Title: "Clear Search", Run: func() { a.search("") }},
+		{Title: "Select All Matching", Run: a.emailList.SelectAllMatching},
+		{Title: "Clear Selection", Run: a.emailList.ClearSelection},
+		{Title: "Restore Selected", Run: a.restoreSelected},
+		{Title: "Group by Sender", Run: func() { a.groupSelect.SetSelected(groupBySender) }},
+		{Title: "Group by Domain", Run: func() { a.groupSelect.SetSelected(groupByDomain) }},
+		{Title: "Group by Thread", Run: func() { a.groupSelect.SetSelected(groupByThread) }},
+	}
+	for _, s := range sortLabels {
+		commands = append(commands, components.Command{
+			Title: "Sort: " + s.label,
+			Run:   func() { a.sortSelect.SetSelected(s.label) },
+		})
+	}
+	for _, search := range a.cfg.UI.SavedSearches {
+		commands = append(commands, components.Command{
+			Title: "Saved search: " + search.Name,
+			Hint:  search.Query,
+			Run:   func() { a.search(search.Query) },
+		})
+	}
+	for _, label := range a.labelSelect.Options {
+		title := "Label: " + label
+		if label == allLabelsOption {
+			title = allLabelsOption
+		}
+		commands = append(commands, components.Command{
+			Title: title,
+			Run:   func() { a.labelSelect.SetSelected(label) },
+		})
+	}
+	commands = append(commands,
+		components.Command{Title: allEmailsOption, Run: func() { a.senderList.SetSelected(allEmailsOption) }},
+		components.Command{Title: trashOption, Run: func() { a.senderList.SetSelected(trashOption) }},
+		components.Command{Title: "Choose Sender", Run: a.senderList.Show},
+	)
+
+	a.palette.Show(commands)
+}
+
+// shortcutHelp lists the bound keys for the status bar
+func (a *App) shortcutHelp() string {
+	var parts []string
+	for _, action := range []string{config.ActionNext, config.ActionPrevious, config.ActionSelect, config.ActionSearch, config.ActionPalette} {
+		if key := a.hint(action); key != "" {
+			parts = append(parts, fmt.Sprintf("%s %s", key, action))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
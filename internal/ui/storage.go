@@ -0,0 +1,259 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/components"
+)
+
+const (
+	// storageEmailLimit is how many of the largest emails are listed
+	storageEmailLimit = 500
+	// storageSenderLimit is how many of the largest senders are listed
+	storageSenderLimit = 100
+)
+
+// storageView is a window showing where the mailbox's space goes: the
+// largest messages with their attachments, and the size per sender,
+// label and year. All fields are only touched on the Fyne UI goroutine.
+type storageView struct {
+	app    *App
+	window fyne.Window
+
+	emails  []models.Email
+	checked map[string]bool
+	senders []models.SenderCount
+	labels  []models.SizeGroup
+	years   []models.SizeGroup
+
+	total      *widget.Label
+	emailList  *widget.List
+	senderList *widget.List
+	labelList  *widget.List
+	yearList   *widget.List
+}
+
+// showStorageView opens the storage usage window
+func (a *App) showStorageView() {
+	v := &storageView{
+		app:     a,
+		window:  a.fyneApp.NewWindow("Storage Usage"),
+		checked: make(map[string]bool),
+	}
+
+	v.total = widget.NewLabel("Loading...")
+	v.total.TextStyle = fyne.TextStyle{Bold: true}
+
+	v.emailList = widget.NewList(
+		func() int { return len(v.emails) },
+		v.createEmailRow,
+		v.updateEmailRow,
+	)
+	v.senderList = widget.NewList(
+		func() int { return len(v.senders) },
+		createSizeRow,
+		func(i widget.ListItemID, obj fyne.CanvasObject) {
+			s := v.senders[i]
+			updateSizeRow(obj, models.SizeGroup{Name: s.Address, Count: s.Count, Size: s.Size})
+		},
+	)
+	v.labelList = widget.NewList(
+		func() int { return len(v.labels) },
+		createSizeRow,
+		func(i widget.ListItemID, obj fyne.CanvasObject) { updateSizeRow(obj, v.labels[i]) },
+	)
+	v.yearList = widget.NewList(
+		func() int { return len(v.years) },
+		createSizeRow,
+		func(i widget.ListItemID, obj fyne.CanvasObject) { updateSizeRow(obj, v.years[i]) },
+	)
+
+	trashBtn := widget.NewButton("Move Checked to Trash", v.trashChecked)
+	messages := container.NewBorder(nil, container.NewHBox(trashBtn), nil, nil, v.emailList)
+
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Largest Messages", messages),
+		container.NewTabItem("Senders", v.senderList),
+		container.NewTabItem("Labels", v.labelList),
+		container.NewTabItem("Years", v.yearList),
+	)
+
+	v.window.SetContent(container.NewBorder(v.total, v.createBulkBar(), nil, nil, tabs))
+	v.window.Resize(fyne.NewSize(800, 600))
+	v.window.Show()
+
+	v.load()
+}
+
+func (v *storageView) createEmailRow() fyne.CanvasObject {
+	title := widget.NewLabel("Subject")
+	title.Truncation = fyne.TextTruncateEllipsis
+	attachments := widget.NewLabel("Attachments")
+	attachments.Truncation = fyne.TextTruncateEllipsis
+	attachments.Importance = widget.LowImportance
+	return container.NewBorder(nil, nil, widget.NewCheck("", nil), widget.NewLabel("0 B"),
+		container.NewVBox(title, attachments))
+}
+
+func (v *storageView) updateEmailRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	lines := c.Objects[0].(*fyne.Container)
+	title := lines.Objects[0].(*widget.Label)
+	attachments := lines.Objects[1].(*widget.Label)
+	check := c.Objects[1].(*widget.Check)
+	size := c.Objects[2].(*widget.Label)
+
+	email := v.emails[i]
+	title.SetText(fmt.Sprintf("%s · %s", email.Subject, email.From))
+	if len(email.Attachments) > 0 {
+		attachments.SetText("Attachments: " + strings.Join(email.Attachments, ", "))
+	} else {
+		attachments.SetText("No attachments")
+	}
+	size.SetText(components.FormatSize(email.SizeEstimate))
+
+	// Rows are recycled, so the check always reflects v.checked
+	check.OnChanged = nil
+	check.SetChecked(v.checked[email.ID])
+	check.OnChanged = func(checked bool) {
+		v.checked[email.ID] = checked
+	}
+}
+
+func createSizeRow() fyne.CanvasObject {
+	name := widget.NewLabel("Name")
+	name.Truncation = fyne.TextTruncateEllipsis
+	return container.NewBorder(nil, nil, nil, widget.NewLabel("0 B"), name)
+}
+
+func updateSizeRow(obj fyne.CanvasObject, group models.SizeGroup) {
+	c := obj.(*fyne.Container)
+	c.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s (%d emails)", group.Name, group.Count))
+	c.Objects[1].(*widget.Label).SetText(components.FormatSize(group.Size))
+}
+
+// load fetches every tab's data in the background
+func (v *storageView) load() {
+	a := v.app
+	go func() {
+		total, err := a.db.SummarizeEmails(a.ctx, models.EmailFilter{})
+		var emails []models.Email
+		var senders []models.SenderCount
+		var labels, years []models.SizeGroup
+		if err == nil {
+			emails, err = a.db.LargestEmails(a.ctx, models.EmailFilter{}, storageEmailLimit)
+		}
+		if err == nil {
+			senders, err = a.db.LargestSenders(a.ctx, models.EmailFilter{}, storageSenderLimit)
+		}
+		if err == nil {
+			labels, err = a.db.SizeByLabel(a.ctx)
+		}
+		if err == nil {
+			years, err = a.db.SizeByYear(a.ctx)
+		}
+
+		fyne.Do(func() {
+			if err != nil {
+				v.total.SetText("Unable to load storage usage")
+				dialog.ShowError(err, v.window)
+				return
+			}
+			v.total.SetText(fmt.Sprintf("%d emails using %s", total.Count, components.FormatSize(total.Size)))
+			v.emails, v.senders, v.labels, v.years = emails, senders, labels, years
+			v.checked = make(map[string]bool)
+			for _, list := range []*widget.List{v.emailList, v.senderList, v.labelList, v.yearList} {
+				list.Refresh()
+			}
+		})
+	}()
+}
+
+// trashChecked moves the checked messages to the trash
+func (v *storageView) trashChecked() {
+	var ids []string
+	var size int64
+	for _, email := range v.emails {
+		if v.checked[email.ID] {
+			ids = append(ids, email.ID)
+			size += email.SizeEstimate
+		}
+	}
+	if len(ids) == 0 {
+		dialog.ShowInformation("No Selection", "Please check messages to delete", v.window)
+		return
+	}
+
+	prompt := fmt.Sprintf("Move %d message(s) using %s to the trash?\nOnly the Gmail trash frees Gmail storage.",
+		len(ids), components.FormatSize(size))
+	v.app.confirmTrash(v.window, ids, prompt, v.load)
+}
+
+// createBulkBar builds the "trash messages larger than X older than Y"
+// controls
+func (v *storageView) createBulkBar() *fyne.Container {
+	sizeEntry := widget.NewEntry()
+	sizeEntry.SetText(strconv.Itoa(v.app.cfg.Cleanup.LargeMessageMB))
+	ageEntry := widget.NewEntry()
+	ageEntry.SetText(strconv.Itoa(v.app.cfg.Cleanup.OlderThanMonths))
+
+	previewBtn := widget.NewButton("Trash Matching...", func() {
+		mb, err := strconv.Atoi(strings.TrimSpace(sizeEntry.Text))
+		if err != nil || mb < 1 {
+			dialog.ShowInformation("Invalid Size", "Enter the size as a whole number of MB", v.window)
+			return
+		}
+		months, err := strconv.Atoi(strings.TrimSpace(ageEntry.Text))
+		if err != nil || months < 0 {
+			dialog.ShowInformation("Invalid Age", "Enter the age as a whole number of months", v.window)
+			return
+		}
+		v.trashMatching(models.EmailFilter{
+			MinSize: int64(mb) << 20,
+			Before:  time.Now().AddDate(0, -months, 0),
+		}, fmt.Sprintf("larger than %d MB and older than %d months", mb, months))
+	})
+
+	// Entries in a box shrink to nothing without a fixed width
+	entrySize := fyne.NewSize(70, sizeEntry.MinSize().Height)
+	return container.NewHBox(
+		widget.NewLabel("Trash messages larger than"),
+		container.NewGridWrap(entrySize, sizeEntry), widget.NewLabel("MB, older than"),
+		container.NewGridWrap(entrySize, ageEntry), widget.NewLabel("months"),
+		previewBtn,
+	)
+}
+
+// trashMatching shows how many messages match filter before offering to
+// trash them all
+func (v *storageView) trashMatching(filter models.EmailFilter, description string) {
+	a := v.app
+	go func() {
+		summary, err := a.db.SummarizeEmails(a.ctx, filter)
+		var ids []string
+		if err == nil && summary.Count > 0 {
+			ids, err = a.db.ListEmailIDs(a.ctx, filter)
+		}
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(err, v.window)
+				return
+			}
+			if len(ids) == 0 {
+				dialog.ShowInformation("Nothing to Do", "No messages are "+description, v.window)
+				return
+			}
+			prompt := fmt.Sprintf("Move %d message(s) %s, using %s, to the trash?\nOnly the Gmail trash frees Gmail storage.",
+				len(ids), description, components.FormatSize(summary.Size))
+			a.confirmTrash(v.window, ids, prompt, v.load)
+		})
+	}()
+}
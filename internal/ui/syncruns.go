@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/HoustonMiles/gmailScraper/internal/models"
+)
+
+// syncRunLimit is how many past syncs the history window lists
+const syncRunLimit = 100
+
+// syncRunsView is a window listing recent syncs from the UI, the API and
+// the daemon. All fields are only touched on the Fyne UI goroutine.
+type syncRunsView struct {
+	app    *App
+	window fyne.Window
+
+	runs []models.SyncRun
+
+	summary *widget.Label
+	list    *widget.List
+}
+
+// showSyncRuns opens the sync history window
+func (a *App) showSyncRuns() {
+	v := &syncRunsView{
+		app:    a,
+		window: a.fyneApp.NewWindow("Sync History"),
+	}
+
+	v.summary = widget.NewLabel("Loading...")
+	v.summary.TextStyle = fyne.TextStyle{Bold: true}
+
+	v.list = widget.NewList(
+		func() int { return len(v.runs) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabel("Run")
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("Details")
+			details.Truncation = fyne.TextTruncateEllipsis
+			details.Importance = widget.LowImportance
+			return container.NewVBox(title, details)
+		},
+		v.updateRow,
+	)
+
+	refreshBtn := widget.NewButton("Refresh", v.load)
+
+	v.window.SetContent(container.NewBorder(v.summary, container.NewHBox(refreshBtn), nil, nil, v.list))
+	v.window.Resize(fyne.NewSize(700, 500))
+	v.window.Show()
+
+	v.load()
+}
+
+func (v *syncRunsView) updateRow(i widget.ListItemID, obj fyne.CanvasObject) {
+	c := obj.(*fyne.Container)
+	title := c.Objects[0].(*widget.Label)
+	details := c.Objects[1].(*widget.Label)
+
+	run := v.runs[i]
+	mode := run.Mode
+	if mode == "" {
+		mode = "sync"
+	}
+	title.SetText(fmt.Sprintf("#%d · %s %s from %s", run.ID, run.StartedAt.Format(time.DateTime), mode, run.Trigger))
+
+	switch {
+	case run.Running():
+		details.SetText(fmt.Sprintf("Running · %d saved so far", run.Saved))
+		details.Importance = widget.MediumImportance
+	case run.Error != "":
+		details.SetText(fmt.Sprintf("Failed after %s · %d saved · %s", runDuration(run), run.Saved, run.Error))
+		details.Importance = widget.DangerImportance
+	default:
+		details.SetText(fmt.Sprintf("Took %s · %d saved · %d deleted in Gmail · %d handled by rules",
+			runDuration(run), run.Saved, run.Deleted, run.Ruled))
+		details.Importance = widget.LowImportance
+	}
+	details.Refresh()
+}
+
+func runDuration(run models.SyncRun) time.Duration {
+	return run.FinishedAt.Sub(run.StartedAt).Round(time.Second)
+}
+
+// load fetches the runs in the background
+func (v *syncRunsView) load() {
+	a := v.app
+	go func() {
+		runs, err := a.db.ListSyncRuns(a.ctx, syncRunLimit)
+		fyne.Do(func() {
+			if err != nil {
+				v.summary.SetText("Unable to load sync history")
+				dialog.ShowError(err, v.window)
+				return
+			}
+
+			v.runs = runs
+			if len(runs) == 0 {
+				v.summary.SetText("No syncs yet")
+			} else {
+				v.summary.SetText(fmt.Sprintf("Last %d syncs", len(runs)))
+			}
+			v.list.Refresh()
+		})
+	}()
+}
@@ -0,0 +1,360 @@
+// Browser UI over the JSON API. It mirrors the desktop app: a filter of
+// senders and the trash, a sort order, emails grouped by sender, a
+// reading pane, deleting the selection and syncing with live progress.
+"use strict";
+
+const API = "/api/v1";
+const PAGE_SIZE = 100;
+
+const state = {
+	filter: "",
+	sort: document.getElementById("sort").value,
+	selected: new Set(),
+	openID: "",
+};
+
+const els = {
+	filter: document.getElementById("filter"),
+	sort: document.getElementById("sort"),
+	list: document.getElementById("list"),
+	reader: document.getElementById("reader"),
+	syncBtn: document.getElementById("sync"),
+	syncStatus: document.getElementById("sync-status"),
+	selectedCount: document.getElementById("selected-count"),
+	deleteBtn: document.getElementById("delete-selected"),
+	confirm: document.getElementById("confirm-delete"),
+	confirmMessage: document.getElementById("confirm-message"),
+	confirmRemote: document.getElementById("confirm-remote"),
+};
+
+// api calls an endpoint with the session cookie and returns the decoded
+// JSON. A lost session goes back to the login form.
+async function api(path, options = {}) {
+	const resp = await fetch(API + path, { credentials: "same-origin", ...options });
+	if (resp.status === 401) {
+		window.location.assign("/");
+		throw new Error("signed out");
+	}
+	if (resp.status === 204) {
+		return null;
+	}
+	const body = await resp.json();
+	if (!resp.ok) {
+		throw new Error(body.error || resp.statusText);
+	}
+	return body;
+}
+
+function el(tag, props = {}, ...children) {
+	const node = document.createElement(tag);
+	Object.assign(node, props);
+	node.append(...children);
+	return node;
+}
+
+function formatSize(bytes) {
+	const units = ["B", "KB", "MB", "GB"];
+	let i = 0;
+	while (bytes >= 1024 && i < units.length - 1) {
+		bytes /= 1024;
+		i++;
+	}
+	return (i === 0 ? bytes : bytes.toFixed(1)) + " " + units[i];
+}
+
+function showError(err) {
+	els.syncStatus.textContent = "Error: " + err.message;
+}
+
+// loadFilterOptions fills the filter dropdown with every sender
+async function loadFilterOptions() {
+	const senders = [];
+	let after = "";
+	do {
+		const page = await api(`/senders?limit=500&after=${encodeURIComponent(after)}`);
+		senders.push(...page.senders);
+		after = page.next_after || "";
+	} while (after);
+
+	const current = els.filter.value;
+	els.filter.querySelectorAll("option[data-sender]").forEach((o) => o.remove());
+	for (const s of senders) {
+		const option = el("option", { value: "sender:" + s.address, textContent: s.address });
+		option.dataset.sender = "";
+		els.filter.append(option);
+	}
+	els.filter.value = current;
+	if (els.filter.value !== current) {
+		els.filter.value = "";
+		state.filter = "";
+	}
+}
+
+// filterParams returns the query parameters of the current filter
+function filterParams() {
+	const params = new URLSearchParams();
+	if (state.filter === "trash") {
+		params.set("trash", "true");
+	} else if (state.filter.startsWith("sender:")) {
+		params.set("sender", state.filter.slice("sender:".length));
+	}
+	return params;
+}
+
+// loadList redraws the sender groups. A single sender filter shows that
+// sender's emails expanded.
+async function loadList() {
+	els.list.replaceChildren();
+	els.deleteBtn.disabled = state.filter === "trash";
+
+	if (state.filter.startsWith("sender:")) {
+		const address = state.filter.slice("sender:".length);
+		const group = senderGroup({ address, count: null, size: null });
+		els.list.append(group);
+		group.querySelector("details").open = true;
+		return;
+	}
+	await loadSenderPage("");
+}
+
+async function loadSenderPage(after) {
+	const params = filterParams();
+	params.set("limit", PAGE_SIZE);
+	params.set("after", after);
+	if (state.sort === "sender_desc") {
+		params.set("desc", "true");
+	}
+	const page = await api("/senders?" + params);
+	for (const sender of page.senders) {
+		els.list.append(senderGroup(sender));
+	}
+	if (page.senders.length === 0 && !after) {
+		els.list.append(el("p", { className: "placeholder", textContent: "No emails" }));
+	}
+	if (page.next_after) {
+		const more = el("button", { type: "button", className: "more", textContent: "More senders" });
+		more.onclick = () => {
+			more.remove();
+			loadSenderPage(page.next_after).catch(showError);
+		};
+		els.list.append(more);
+	}
+}
+
+// senderGroup is a collapsible sender whose emails load when opened
+function senderGroup(sender) {
+	const summary = el("summary", {}, el("span", { className: "sender", textContent: sender.address }));
+	if (sender.count !== null) {
+		summary.append(el("span", { className: "count", textContent: `${sender.count} · ${formatSize(sender.size)}` }));
+	}
+	const emails = el("ul", { className: "emails" });
+	const details = el("details", {}, summary, emails);
+	details.addEventListener("toggle", () => {
+		if (details.open && !emails.dataset.loaded) {
+			emails.dataset.loaded = "true";
+			loadEmailPage(sender.address, emails, "").catch(showError);
+		}
+	});
+	return el("div", { className: "group" }, details);
+}
+
+async function loadEmailPage(address, ul, cursor) {
+	const params = filterParams();
+	params.set("sender", address);
+	params.set("sort", state.sort);
+	params.set("limit", PAGE_SIZE);
+	if (cursor) {
+		params.set("cursor", cursor);
+	}
+	const page = await api("/emails?" + params);
+	for (const email of page.emails) {
+		ul.append(emailRow(email));
+	}
+	if (page.next_cursor) {
+		const more = el("button", { type: "button", className: "more", textContent: "More emails" });
+		const li = el("li", {}, more);
+		more.onclick = () => {
+			li.remove();
+			loadEmailPage(address, ul, page.next_cursor).catch(showError);
+		};
+		ul.append(li);
+	}
+}
+
+function emailRow(email) {
+	const check = el("input", { type: "checkbox", checked: state.selected.has(email.id) });
+	check.onchange = () => {
+		if (check.checked) {
+			state.selected.add(email.id);
+		} else {
+			state.selected.delete(email.id);
+		}
+		updateSelection();
+	};
+	const title = el("button", { type: "button", className: "subject", textContent: email.subject || "(no subject)" });
+	title.onclick = () => openEmail(email.id, li);
+	const date = el("span", { className: "date", textContent: email.date });
+	const li = el("li", {}, check, title, date);
+	li.dataset.id = email.id;
+	if (email.id === state.openID) {
+		li.classList.add("open");
+	}
+	return li;
+}
+
+function updateSelection() {
+	els.selectedCount.textContent = `${state.selected.size} selected`;
+}
+
+// openEmail shows an email in the reading pane. HTML bodies are only
+// loaded when asked for, in a sandboxed frame whose policy blocks scripts
+// and remote content such as tracking pixels.
+async function openEmail(id, row) {
+	state.openID = id;
+	els.list.querySelectorAll("li.open").forEach((li) => li.classList.remove("open"));
+	row.classList.add("open");
+
+	const email = await api("/emails/" + encodeURIComponent(id)).catch((err) => {
+		showError(err);
+		return null;
+	});
+	if (!email || state.openID !== id) {
+		return;
+	}
+
+	const meta = el("dl", {},
+		el("dt", { textContent: "From" }), el("dd", { textContent: email.from }),
+		el("dt", { textContent: "Date" }), el("dd", { textContent: email.date }),
+		el("dt", { textContent: "Labels" }), el("dd", { textContent: email.labels.join(", ") }),
+	);
+	if (email.attachments.length > 0) {
+		meta.append(el("dt", { textContent: "Attachments" }), el("dd", { textContent: email.attachments.join(", ") }));
+	}
+
+	const body = el("pre", { className: "body", textContent: email.body });
+	const children = [el("h2", { textContent: email.subject || "(no subject)" }), meta];
+	if (email.html_body) {
+		const frame = el("iframe", { className: "body", title: "HTML body" });
+		frame.setAttribute("sandbox", "");
+		frame.hidden = true;
+		const toggle = el("button", { type: "button", textContent: "Show HTML" });
+		toggle.onclick = () => {
+			if (!frame.src) {
+				frame.src = API + "/emails/" + encodeURIComponent(id) + "/html";
+			}
+			frame.hidden = !frame.hidden;
+			body.hidden = !frame.hidden;
+			toggle.textContent = frame.hidden ? "Show HTML" : "Show Text";
+		};
+		children.push(toggle, body, frame);
+	} else {
+		children.push(body);
+	}
+	els.reader.replaceChildren(...children);
+}
+
+// deleteSelected asks for confirmation, then moves the selection to the
+// trash
+function deleteSelected() {
+	if (state.selected.size === 0) {
+		els.syncStatus.textContent = "Please select emails to delete";
+		return;
+	}
+	els.confirmMessage.textContent = `Move ${state.selected.size} email(s) to the trash?`;
+	els.confirmRemote.checked = false;
+	els.confirm.returnValue = "";
+	els.confirm.showModal();
+}
+
+els.confirm.addEventListener("close", async () => {
+	if (els.confirm.returnValue !== "delete") {
+		return;
+	}
+	try {
+		const result = await api("/emails/trash", {
+			method: "POST",
+			headers: { "Content-Type": "application/json" },
+			body: JSON.stringify({ ids: [...state.selected], gmail: els.confirmRemote.checked }),
+		});
+		els.syncStatus.textContent = `Moved ${result.trashed.length} emails to the trash`;
+		if (result.trashed.includes(state.openID)) {
+			els.reader.replaceChildren(el("p", { className: "placeholder", textContent: "Select an email to read it" }));
+		}
+		state.selected.clear();
+		updateSelection();
+		await refresh();
+	} catch (err) {
+		showError(err);
+	}
+});
+
+// startSync asks the server to sync, then follows its progress
+async function startSync() {
+	try {
+		await api("/sync", { method: "POST" });
+	} catch (err) {
+		if (!/already running/.test(err.message)) {
+			showError(err);
+			return;
+		}
+	}
+	watchSync(true);
+}
+
+// watchSync follows the sync's progress over server-sent events until it
+// finishes. Opened on page load it only shows a sync already running.
+function watchSync(started) {
+	const events = new EventSource(API + "/sync/events");
+	events.onmessage = (event) => {
+		const status = JSON.parse(event.data);
+		if (status.running) {
+			started = true;
+			els.syncBtn.disabled = true;
+			const total = status.total ? ` of ${status.total}` : "";
+			els.syncStatus.textContent = `Syncing: fetched ${status.fetched}${total}, saved ${status.saved}`;
+			return;
+		}
+		events.close();
+		els.syncBtn.disabled = false;
+		if (!started) {
+			return;
+		}
+		els.syncStatus.textContent = status.error
+			? "Sync failed: " + status.error
+			: `Sync complete: saved ${status.saved} emails`;
+		refresh();
+	};
+	events.onerror = () => {
+		events.close();
+		els.syncBtn.disabled = false;
+	};
+}
+
+async function refresh() {
+	try {
+		await loadFilterOptions();
+		await loadList();
+	} catch (err) {
+		showError(err);
+	}
+}
+
+els.filter.onchange = () => {
+	state.filter = els.filter.value;
+	loadList().catch(showError);
+};
+els.sort.onchange = () => {
+	state.sort = els.sort.value;
+	loadList().catch(showError);
+};
+document.getElementById("refresh").onclick = refresh;
+document.getElementById("clear-selection").onclick = () => {
+	state.selected.clear();
+	els.list.querySelectorAll("input[type=checkbox]").forEach((c) => (c.checked = false));
+	updateSelection();
+};
+els.deleteBtn.onclick = deleteSelected;
+els.syncBtn.onclick = startSync;
+
+refresh();
+watchSync(false);
@@ -0,0 +1,73 @@
+// Package web holds the browser UI served next to the JSON API. The pages
+// are thin html/template shells; static/app.js does the work through the
+// API, authenticated by the session cookie set on login.
+package web
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+)
+
+//go:embed templates static
+var files embed.FS
+
+var templates = template.Must(template.ParseFS(files, "templates/*.html"))
+
+// Static serves the script and stylesheet under /static/
+var Static = http.FileServer(http.FS(mustSub(files, "static")))
+
+// sortOptions are the entries of the sort dropdown, as in the desktop app
+var sortOptions = []SortOption{
+	{config.SortDateNewest, "Newest First"},
+	{config.SortDateOldest, "Oldest First"},
+	{config.SortSenderAsc, "Sender A-Z"},
+	{config.SortSenderDesc, "Sender Z-A"},
+}
+
+// SortOption is a sort order and its dropdown label
+type SortOption struct {
+	Value string
+	Label string
+}
+
+// IndexPage is the data of the main page. RenderIndex fills in
+// SortOptions.
+type IndexPage struct {
+	DefaultSort string
+	SortOptions []SortOption
+}
+
+// LoginPage is the data of the login form
+type LoginPage struct {
+	// Error is shown above the form, empty for none
+	Error string
+}
+
+// RenderIndex writes the main page
+func RenderIndex(w http.ResponseWriter, page IndexPage) error {
+	page.SortOptions = sortOptions
+	return render(w, http.StatusOK, "index.html", page)
+}
+
+// RenderLogin writes the login form with status
+func RenderLogin(w http.ResponseWriter, status int, page LoginPage) error {
+	return render(w, status, "login.html", page)
+}
+
+func render(w http.ResponseWriter, status int, name string, data any) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	return templates.ExecuteTemplate(w, name, data)
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
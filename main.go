@@ -1,41 +1,173 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
-	"github.com/HoustonMiles/gmailScraper/internal/database"
+	"github.com/HoustonMiles/gmailScraper/internal/api"
+	"github.com/HoustonMiles/gmailScraper/internal/config"
+	"github.com/HoustonMiles/gmailScraper/internal/daemon"
 	"github.com/HoustonMiles/gmailScraper/internal/gmail"
+	"github.com/HoustonMiles/gmailScraper/internal/logging"
+	"github.com/HoustonMiles/gmailScraper/internal/store"
+	"github.com/HoustonMiles/gmailScraper/internal/tracing"
 	"github.com/HoustonMiles/gmailScraper/internal/ui"
+	"github.com/HoustonMiles/gmailScraper/internal/ui/handlers"
 )
 
+const (
+	// trashPurgeInterval is how often the serve command purges expired trash
+	trashPurgeInterval = time.Hour
+	// outboxInterval is how often the serve command checks the outbox for
+	// actions due to be sent
+	outboxInterval = time.Minute
+	// traceFlushTimeout bounds sending the last spans on exit
+	traceFlushTimeout = 5 * time.Second
+)
+
+var logger = logging.For("main")
+
+// fatal logs err and exits. Exiting skips deferred calls, so it is only
+// used in main, where none are pending.
+func fatal(err error) {
+	logger.Error(err.Error())
+	os.Exit(1)
+}
+
 func main() {
-	fmt.Println("Starting application...")
-	
-	// Initialize database
-	fmt.Println("Connecting to database...")
-	db, err := database.InitDB()
+	// Load configuration
+	cfg, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+	closeLog, err := logging.Setup(cfg.Log)
+	if err != nil {
+		fatal(err)
+	}
+
+	// The error is logged before the log file is closed so it ends up in
+	// the file too
+	err = run(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	closeLog()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run runs the command cfg names, cleaning up after itself before it
+// returns
+func run(cfg *config.Config) error {
+	ctx := context.Background()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Trace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Unable to flush traces", "err", err)
+		}
+	}()
+	logger.Info("Starting application", "config", cfg.Path, "profile", cfg.Profile, "log_file", cfg.Log.File)
+
+	command := "ui"
+	if len(cfg.Args) > 0 {
+		command = cfg.Args[0]
+	}
+	switch command {
+	case "ui", "serve", "daemon", "runs":
+	default:
+		return fmt.Errorf("unknown command %q (want ui, serve, daemon or runs)", command)
+	}
+	if command == "serve" && cfg.Server.Token == "" {
+		return errors.New("server.token or GMAILSCRAPER_API_TOKEN must be set to serve the API")
 	}
-	defer db.Close()
 
-	// Create tables if they don't exist
-	fmt.Println("Creating tables...")
-	err = database.CreateTables(db)
+	// Open the store, creating tables if they don't exist
+	logger.Info("Connecting to database")
+	db, err := store.Open(ctx, cfg.Database)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer db.Close()
+
+	if command == "runs" {
+		return printSyncRuns(ctx, db)
 	}
 
 	// Get Gmail client
-	fmt.Println("Getting Gmail client...")
-	client, err := gmail.GetClient()
+	logger.Info("Getting Gmail client")
+	client, err := gmail.GetClient(ctx, cfg.Gmail)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	switch command {
+	case "serve":
+		return serve(ctx, db, client, cfg)
+	case "daemon":
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return daemon.Run(ctx, db, client, cfg)
 	}
 
 	// Launch UI
-	fmt.Println("Launching UI...")
-	app := ui.NewApp(db, client)
+	logger.Info("Launching UI")
+	app := ui.NewApp(db, client, cfg)
 	app.Run()
+	return nil
+}
+
+// serve runs the JSON API until interrupted
+func serve(ctx context.Context, db store.Store, client *http.Client, cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go handlers.RunTrashRetention(ctx, db, cfg.Trash.RetentionDays, trashPurgeInterval)
+	go handlers.RunOutbox(ctx, client, db, outboxInterval)
+	return api.New(ctx, db, client, cfg).ListenAndServe(ctx)
+}
+
+// syncRunsShown is how many runs the runs command prints
+const syncRunsShown = 20
+
+// printSyncRuns prints the most recent syncs, newest first
+func printSyncRuns(ctx context.Context, db store.Store) error {
+	runs, err := db.ListSyncRuns(ctx, syncRunsShown)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("No syncs yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tTRIGGER\tMODE\tDURATION\tSAVED\tDELETED\tRULED\tRESULT")
+	for _, run := range runs {
+		duration, result := "-", "running"
+		if !run.Running() {
+			duration = run.FinishedAt.Sub(run.StartedAt).Round(time.Second).String()
+			result = "ok"
+			if run.Error != "" {
+				result = run.Error
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			run.ID, run.StartedAt.Format(time.DateTime), run.Trigger, run.Mode,
+			duration, run.Saved, run.Deleted, run.Ruled, result)
+	}
+	return w.Flush()
 }